@@ -86,6 +86,13 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	forceRm := cmd.Bool([]string{"-force-rm"}, false, "Always remove intermediate containers, even after unsuccessful builds")
 	pull := cmd.Bool([]string{"-pull"}, false, "Always attempt to pull a newer version of the image")
 	dockerfileName := cmd.String([]string{"f", "-file"}, "", "Name of the Dockerfile(Default is 'Dockerfile' at context root)")
+	flBuildArg := opts.NewListOpts(opts.ValidateEnv)
+	cmd.Var(&flBuildArg, []string{"-build-arg"}, "Set build-time variables")
+	flCacheFrom := opts.NewListOpts(nil)
+	cmd.Var(&flCacheFrom, []string{"-cache-from"}, "Images to consider as cache sources")
+	squash := cmd.Bool([]string{"-squash"}, false, "Squash the layers of the build into a single new layer")
+	jsonProgress := cmd.Bool([]string{"-json-progress"}, false, "Print the output as structured JSON messages")
+	check := cmd.Bool([]string{"-check"}, false, "Check the Dockerfile for errors without building the image")
 
 	cmd.Require(flag.Exact, 1)
 
@@ -115,29 +122,44 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 			if *dockerfileName == "" {
 				*dockerfileName = api.DefaultDockerfileName
 			}
+			if *check {
+				return cli.checkDockerfile(*dockerfileName, bytes.NewReader(dockerfile))
+			}
 			context, err = archive.Generate(*dockerfileName, string(dockerfile))
+		} else if *check {
+			return fmt.Errorf("Cannot check the Dockerfile of a context from STDIN, only a Dockerfile")
 		} else {
 			context = ioutil.NopCloser(buf)
 		}
 	} else if urlutil.IsURL(cmd.Arg(0)) && (!urlutil.IsGitURL(cmd.Arg(0)) || !hasGit) {
+		if *check {
+			f, err := utils.Download(cmd.Arg(0))
+			if err != nil {
+				return err
+			}
+			defer f.Body.Close()
+			// Remote contexts may be tarballs rather than Dockerfiles
+			body := bufio.NewReader(f.Body)
+			magic, err := body.Peek(tarHeaderSize)
+			if err != nil && err != io.EOF {
+				return fmt.Errorf("failed to peek context header from %s: %v", cmd.Arg(0), err)
+			}
+			if archive.IsArchive(magic) {
+				return fmt.Errorf("Cannot check the Dockerfile of a remote tarball context, only a Dockerfile")
+			}
+			return cli.checkDockerfile(cmd.Arg(0), body)
+		}
 		isRemote = true
 	} else {
 		root := cmd.Arg(0)
-		if urlutil.IsGitURL(root) {
-			remoteURL := cmd.Arg(0)
-			if !urlutil.IsGitTransport(remoteURL) {
-				remoteURL = "https://" + remoteURL
-			}
-
-			root, err = ioutil.TempDir("", "docker-build-git")
+		isGit := urlutil.IsGitURL(root)
+		if isGit {
+			gitRoot, contextDir, err := utils.GitClone(root)
 			if err != nil {
 				return err
 			}
-			defer os.RemoveAll(root)
-
-			if output, err := exec.Command("git", "clone", "--recursive", remoteURL, root).CombinedOutput(); err != nil {
-				return fmt.Errorf("Error trying to use git: %s (%s)", err, output)
-			}
+			defer os.RemoveAll(gitRoot)
+			root = contextDir
 		}
 		if _, err := os.Stat(root); err != nil {
 			return err
@@ -150,6 +172,11 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 
 		filename := *dockerfileName // path to Dockerfile
 
+		if isGit && *dockerfileName != "" {
+			// The Dockerfile of a repository is relative to its build context
+			filename = path.Join(absRoot, *dockerfileName)
+		}
+
 		if *dockerfileName == "" {
 			// No -f/--file was specified so use the default
 			*dockerfileName = api.DefaultDockerfileName
@@ -177,6 +204,14 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		if _, err = os.Lstat(filename); os.IsNotExist(err) {
 			return fmt.Errorf("Cannot locate Dockerfile: %s", origDockerfile)
 		}
+		if *check {
+			f, err := os.Open(filename)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			return cli.checkDockerfile(origDockerfile, f)
+		}
 		var includes = []string{"."}
 
 		excludes, err := utils.ReadDockerIgnore(path.Join(root, ".dockerignore"))
@@ -257,9 +292,46 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 		v.Set("pull", "1")
 	}
 
+	if *squash {
+		v.Set("squash", "1")
+	}
+
+	if *jsonProgress {
+		v.Set("structured", "1")
+	}
+
 	v.Set("dockerfile", *dockerfileName)
 
+	if buildArgs := flBuildArg.GetAll(); len(buildArgs) > 0 {
+		buildArgsMap := make(map[string]string, len(buildArgs))
+		for _, arg := range buildArgs {
+			parts := strings.SplitN(arg, "=", 2)
+			if len(parts) == 1 {
+				parts = append(parts, "")
+			}
+			buildArgsMap[parts[0]] = parts[1]
+		}
+		buildArgsJson, err := json.Marshal(buildArgsMap)
+		if err != nil {
+			return err
+		}
+		v.Set("buildargs", string(buildArgsJson))
+	}
+
+	if cacheFrom := flCacheFrom.GetAll(); len(cacheFrom) > 0 {
+		cacheFromJson, err := json.Marshal(cacheFrom)
+		if err != nil {
+			return err
+		}
+		v.Set("cachefrom", string(cacheFromJson))
+	}
+
 	cli.LoadConfigFile()
+	// The daemon resolves the credentials of the images the Dockerfile
+	// pulls, which are not known here, so all of them are sent
+	if err := cli.configFile.LoadAllCredentials(); err != nil {
+		fmt.Fprintf(cli.err, "WARNING: %s\n", err)
+	}
 
 	headers := http.Header(make(map[string][]string))
 	buf, err := json.Marshal(cli.configFile)
@@ -271,7 +343,11 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	if context != nil {
 		headers.Set("Content-Type", "application/tar")
 	}
-	err = cli.stream("POST", fmt.Sprintf("/build?%s", v.Encode()), body, cli.out, headers)
+	if *jsonProgress {
+		err = cli.streamJSONMessages("POST", fmt.Sprintf("/build?%s", v.Encode()), body, cli.out, headers)
+	} else {
+		err = cli.stream("POST", fmt.Sprintf("/build?%s", v.Encode()), body, cli.out, headers)
+	}
 	if jerr, ok := err.(*utils.JSONError); ok {
 		// If no error code is set, default to 1
 		if jerr.Code == 0 {
@@ -282,16 +358,54 @@ func (cli *DockerCli) CmdBuild(args ...string) error {
 	return err
 }
 
+// checkDockerfile sends the Dockerfile to the daemon to check it without
+// building it, and prints the problems found. It fails if any is an error.
+func (cli *DockerCli) checkDockerfile(name string, dockerfile io.Reader) error {
+	resp, err := cli.streamRequest("POST", "/build/validate", dockerfile, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	outs := engine.NewTable("", 0)
+	if _, err := outs.ReadListFrom(body); err != nil {
+		return err
+	}
+	numErrors := 0
+	for _, problem := range outs.Data {
+		location := name
+		if line := problem.GetInt("Line"); line > 0 {
+			location = fmt.Sprintf("%s:%d", name, line)
+		}
+		fmt.Fprintf(cli.out, "%s: %s: %s\n", location, problem.Get("Severity"), problem.Get("Message"))
+		if problem.Get("Severity") == "error" {
+			numErrors++
+		}
+	}
+	if numErrors > 0 {
+		return &utils.StatusError{StatusCode: 1}
+	}
+	if len(outs.Data) == 0 {
+		fmt.Fprintf(cli.out, "%s: no problems found\n", name)
+	}
+	return nil
+}
+
 // 'docker login': login / register a user to registry service.
 func (cli *DockerCli) CmdLogin(args ...string) error {
 	cmd := cli.Subcmd("login", "[SERVER]", "Register or log in to a Docker registry server, if no server is specified \""+registry.IndexServerAddress()+"\" is the default.", true)
 	cmd.Require(flag.Max, 1)
 
-	var username, password, email string
+	var username, password, email, credentialHelper string
 
 	cmd.StringVar(&username, []string{"u", "-username"}, "", "Username")
 	cmd.StringVar(&password, []string{"p", "-password"}, "", "Password")
 	cmd.StringVar(&email, []string{"e", "-email"}, "", "Email")
+	cmd.StringVar(&credentialHelper, []string{"-credential-helper"}, "", "Store the credentials with the docker-credential-<name> helper")
 
 	utils.ParseFlags(cmd, args, true)
 
@@ -319,7 +433,7 @@ func (cli *DockerCli) CmdLogin(args ...string) error {
 	}
 
 	cli.LoadConfigFile()
-	authconfig, ok := cli.configFile.Configs[serverAddress]
+	authconfig, ok := cli.configFile.GetAuthConfig(serverAddress)
 	if !ok {
 		authconfig = registry.AuthConfig{}
 	}
@@ -375,10 +489,14 @@ func (cli *DockerCli) CmdLogin(args ...string) error {
 	authconfig.Email = email
 	authconfig.ServerAddress = serverAddress
 	cli.configFile.Configs[serverAddress] = authconfig
+	if credentialHelper != "" {
+		cli.configFile.CredentialHelpers[serverAddress] = credentialHelper
+	}
 
 	stream, statusCode, err := cli.call("POST", "/auth", cli.configFile.Configs[serverAddress], false)
 	if statusCode == 401 {
 		delete(cli.configFile.Configs, serverAddress)
+		cli.configFile.EraseCredentials(serverAddress)
 		registry.SaveConfig(cli.configFile)
 		return err
 	}
@@ -391,7 +509,12 @@ func (cli *DockerCli) CmdLogin(args ...string) error {
 		cli.configFile, _ = registry.LoadConfig(os.Getenv("HOME"))
 		return err
 	}
-	registry.SaveConfig(cli.configFile)
+	if err := cli.configFile.StoreCredentials(serverAddress); err != nil {
+		return err
+	}
+	if err := registry.SaveConfig(cli.configFile); err != nil {
+		return err
+	}
 	if out2.Get("Status") != "" {
 		fmt.Fprintf(cli.out, "%s\n", out2.Get("Status"))
 	}
@@ -410,12 +533,15 @@ func (cli *DockerCli) CmdLogout(args ...string) error {
 	}
 
 	cli.LoadConfigFile()
-	if _, ok := cli.configFile.Configs[serverAddress]; !ok {
+	if _, ok := cli.configFile.GetAuthConfig(serverAddress); !ok {
 		fmt.Fprintf(cli.out, "Not logged in to %s\n", serverAddress)
 	} else {
 		fmt.Fprintf(cli.out, "Remove login credentials for %s\n", serverAddress)
 		delete(cli.configFile.Configs, serverAddress)
 
+		if err := cli.configFile.EraseCredentials(serverAddress); err != nil {
+			return err
+		}
 		if err := registry.SaveConfig(cli.configFile); err != nil {
 			return fmt.Errorf("Failed to save docker config: %v", err)
 		}
@@ -575,7 +701,8 @@ func (cli *DockerCli) CmdInfo(args ...string) error {
 
 	if len(remoteInfo.GetList("IndexServerAddress")) != 0 {
 		cli.LoadConfigFile()
-		u := cli.configFile.Configs[remoteInfo.Get("IndexServerAddress")].Username
+		authConfig, _ := cli.configFile.GetAuthConfig(remoteInfo.Get("IndexServerAddress"))
+		u := authConfig.Username
 		if len(u) > 0 {
 			fmt.Fprintf(cli.out, "Username: %v\n", u)
 			fmt.Fprintf(cli.out, "Registry: %v\n", remoteInfo.GetList("IndexServerAddress"))
@@ -818,6 +945,52 @@ func (cli *DockerCli) CmdPause(args ...string) error {
 	return encounteredError
 }
 
+func (cli *DockerCli) CmdUpdate(args ...string) error {
+	cmd := cli.Subcmd("update", "CONTAINER [CONTAINER...]", "Update the resource limits of one or more containers", true)
+	flMemoryString := cmd.String([]string{"m", "-memory"}, "", "Memory limit (format: <number><optional unit>, where unit = b, k, m or g)")
+	flMemorySwap := cmd.String([]string{"-memory-swap"}, "", "Total memory usage (memory + swap), set '-1' to disable swap (format: <number><optional unit>, where unit = b, k, m or g)")
+	flCpuShares := cmd.Int64([]string{"c", "-cpu-shares"}, 0, "CPU shares (relative weight)")
+	flCpuset := cmd.String([]string{"-cpuset"}, "", "CPUs in which to allow execution (0-3, 0,1)")
+	cmd.Require(flag.Min, 1)
+	utils.ParseFlags(cmd, args, true)
+
+	update := engine.Env{}
+	if *flMemoryString != "" {
+		memory, err := units.RAMInBytes(*flMemoryString)
+		if err != nil {
+			return err
+		}
+		update.SetInt64("Memory", memory)
+	}
+	if *flMemorySwap != "" {
+		memorySwap, err := units.RAMInBytes(*flMemorySwap)
+		if err != nil {
+			return err
+		}
+		update.SetInt64("MemorySwap", memorySwap)
+	}
+	if *flCpuShares != 0 {
+		update.SetInt64("CpuShares", *flCpuShares)
+	}
+	if *flCpuset != "" {
+		update.Set("Cpuset", *flCpuset)
+	}
+	if len(update) == 0 {
+		return fmt.Errorf("You must provide one or more limits to update")
+	}
+
+	var encounteredError error
+	for _, name := range cmd.Args() {
+		if _, _, err := readBody(cli.call("POST", fmt.Sprintf("/containers/%s/update", name), update, false)); err != nil {
+			fmt.Fprintf(cli.err, "%s\n", err)
+			encounteredError = fmt.Errorf("Error: failed to update container named %s", name)
+		} else {
+			fmt.Fprintf(cli.out, "%s\n", name)
+		}
+	}
+	return encounteredError
+}
+
 func (cli *DockerCli) CmdRename(args ...string) error {
 	cmd := cli.Subcmd("rename", "OLD_NAME NEW_NAME", "Rename a container", true)
 	if err := cmd.Parse(args); err != nil {
@@ -841,6 +1014,7 @@ func (cli *DockerCli) CmdRename(args ...string) error {
 func (cli *DockerCli) CmdInspect(args ...string) error {
 	cmd := cli.Subcmd("inspect", "CONTAINER|IMAGE [CONTAINER|IMAGE...]", "Return low-level information on a container or image", true)
 	tmplStr := cmd.String([]string{"f", "#format", "-format"}, "", "Format the output using the given go template.")
+	signatures := cmd.Bool([]string{"-signatures"}, false, "Show the signatures of the manifest of an image tag")
 	cmd.Require(flag.Min, 1)
 
 	utils.ParseFlags(cmd, args, true)
@@ -860,8 +1034,17 @@ func (cli *DockerCli) CmdInspect(args ...string) error {
 	status := 0
 
 	for _, name := range cmd.Args() {
-		obj, _, err := readBody(cli.call("GET", "/containers/"+name+"/json", nil, false))
-		if err != nil {
+		var (
+			obj []byte
+			err error
+		)
+		if *signatures {
+			if obj, _, err = readBody(cli.call("GET", "/images/"+name+"/signatures", nil, false)); err != nil {
+				fmt.Fprintf(cli.err, "%s\n", err)
+				status = 1
+				continue
+			}
+		} else if obj, _, err = readBody(cli.call("GET", "/containers/"+name+"/json", nil, false)); err != nil {
 			obj, _, err = readBody(cli.call("GET", "/images/"+name+"/json", nil, false))
 			if err != nil {
 				if strings.Contains(err.Error(), "No such") {
@@ -1128,7 +1311,7 @@ func (cli *DockerCli) CmdRm(args ...string) error {
 // 'docker kill NAME' kills a running container
 func (cli *DockerCli) CmdKill(args ...string) error {
 	cmd := cli.Subcmd("kill", "CONTAINER [CONTAINER...]", "Kill a running container using SIGKILL or a specified signal", true)
-	signal := cmd.String([]string{"s", "-signal"}, "KILL", "Signal to send to the container")
+	signal := cmd.String([]string{"s", "-signal"}, "", "Signal to send to the container, the STOPSIGNAL of the image or KILL by default")
 	cmd.Require(flag.Min, 1)
 
 	utils.ParseFlags(cmd, args, true)
@@ -1184,6 +1367,9 @@ func (cli *DockerCli) CmdImport(args ...string) error {
 
 func (cli *DockerCli) CmdPush(args ...string) error {
 	cmd := cli.Subcmd("push", "NAME[:TAG]", "Push an image or a repository to the registry", true)
+	flSignKeys := opts.NewListOpts(nil)
+	cmd.Var(&flSignKeys, []string{"-sign-key"}, "Also sign the manifest with the private key in this file on the daemon's host")
+	jsonProgress := cmd.Bool([]string{"-json-progress"}, false, "Print the progress as structured JSON messages")
 	cmd.Require(flag.Exact, 1)
 
 	utils.ParseFlags(cmd, args, true)
@@ -1215,6 +1401,18 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 
 	v := url.Values{}
 	v.Set("tag", tag)
+	if *jsonProgress {
+		v.Set("structured", "1")
+	}
+	// The daemon reads the signing keys itself, so that private keys are
+	// never sent over the API
+	for _, keyPath := range flSignKeys.GetAll() {
+		keyPath, err := filepath.Abs(keyPath)
+		if err != nil {
+			return err
+		}
+		v.Add("signkey", keyPath)
+	}
 
 	push := func(authConfig registry.AuthConfig) error {
 		buf, err := json.Marshal(authConfig)
@@ -1225,9 +1423,15 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 			base64.URLEncoding.EncodeToString(buf),
 		}
 
-		return cli.stream("POST", "/images/"+remote+"/push?"+v.Encode(), nil, cli.out, map[string][]string{
+		headers := map[string][]string{
 			"X-Registry-Auth": registryAuthHeader,
-		})
+		}
+
+		path := "/images/" + remote + "/push?" + v.Encode()
+		if *jsonProgress {
+			return cli.streamJSONMessages("POST", path, nil, cli.out, headers)
+		}
+		return cli.stream("POST", path, nil, cli.out, headers)
 	}
 
 	if err := push(authConfig); err != nil {
@@ -1245,8 +1449,9 @@ func (cli *DockerCli) CmdPush(args ...string) error {
 }
 
 func (cli *DockerCli) CmdPull(args ...string) error {
-	cmd := cli.Subcmd("pull", "NAME[:TAG]", "Pull an image or a repository from the registry", true)
+	cmd := cli.Subcmd("pull", "NAME[:TAG|@DIGEST]", "Pull an image or a repository from the registry", true)
 	allTags := cmd.Bool([]string{"a", "-all-tags"}, false, "Download all tagged images in the repository")
+	jsonProgress := cmd.Bool([]string{"-json-progress"}, false, "Print the progress as structured JSON messages")
 	cmd.Require(flag.Exact, 1)
 
 	utils.ParseFlags(cmd, args, true)
@@ -1265,6 +1470,9 @@ func (cli *DockerCli) CmdPull(args ...string) error {
 	}
 
 	v.Set("fromImage", newRemote)
+	if *jsonProgress {
+		v.Set("structured", "1")
+	}
 
 	// Resolve the Repository name from fqn to RepositoryInfo
 	repoInfo, err := registry.ParseRepositoryInfo(taglessRemote)
@@ -1286,9 +1494,13 @@ func (cli *DockerCli) CmdPull(args ...string) error {
 			base64.URLEncoding.EncodeToString(buf),
 		}
 
-		return cli.stream("POST", "/images/create?"+v.Encode(), nil, cli.out, map[string][]string{
+		headers := map[string][]string{
 			"X-Registry-Auth": registryAuthHeader,
-		})
+		}
+		if *jsonProgress {
+			return cli.streamJSONMessages("POST", "/images/create?"+v.Encode(), nil, cli.out, headers)
+		}
+		return cli.stream("POST", "/images/create?"+v.Encode(), nil, cli.out, headers)
 	}
 
 	if err := pull(authConfig); err != nil {
@@ -1311,6 +1523,7 @@ func (cli *DockerCli) CmdImages(args ...string) error {
 	quiet := cmd.Bool([]string{"q", "-quiet"}, false, "Only show numeric IDs")
 	all := cmd.Bool([]string{"a", "-all"}, false, "Show all images (by default filter out the intermediate image layers)")
 	noTrunc := cmd.Bool([]string{"#notrunc", "-no-trunc"}, false, "Don't truncate output")
+	showDigests := cmd.Bool([]string{"-digests"}, false, "Show digests")
 	// FIXME: --viz and --tree are deprecated. Remove them in a future version.
 	flViz := cmd.Bool([]string{"#v", "#viz", "#-viz"}, false, "Output graph in graphviz format")
 	flTree := cmd.Bool([]string{"#t", "#tree", "#-tree"}, false, "Output graph in tree format")
@@ -1437,22 +1650,57 @@ func (cli *DockerCli) CmdImages(args ...string) error {
 
 		w := tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
 		if !*quiet {
-			fmt.Fprintln(w, "REPOSITORY\tTAG\tIMAGE ID\tCREATED\tVIRTUAL SIZE")
+			if *showDigests {
+				fmt.Fprintln(w, "REPOSITORY\tTAG\tDIGEST\tIMAGE ID\tCREATED\tVIRTUAL SIZE")
+			} else {
+				fmt.Fprintln(w, "REPOSITORY\tTAG\tIMAGE ID\tCREATED\tVIRTUAL SIZE")
+			}
 		}
 
 		for _, out := range outs.Data {
-			for _, repotag := range out.GetList("RepoTags") {
+			outID := out.Get("Id")
+			if !*noTrunc {
+				outID = utils.TruncateID(outID)
+			}
 
+			// rows holds the repository, tag and digest of each line of output
+			var (
+				rows        [][3]string
+				repoDigests = out.GetList("RepoDigests")
+			)
+			for _, repotag := range out.GetList("RepoTags") {
 				repo, tag := parsers.ParseRepositoryTag(repotag)
-				outID := out.Get("Id")
-				if !*noTrunc {
-					outID = utils.TruncateID(outID)
+				if repotag == "<none>:<none>" && len(repoDigests) > 0 && repoDigests[0] != "<none>@<none>" {
+					// The image is only referenced by digest
+					continue
 				}
+				digest := "<none>"
+				for _, repoDigest := range repoDigests {
+					if r, d := parsers.ParseRepositoryTag(repoDigest); r == repo {
+						digest = d
+						break
+					}
+				}
+				rows = append(rows, [3]string{repo, tag, digest})
+			}
+			if len(rows) == 0 {
+				for _, repoDigest := range repoDigests {
+					repo, digest := parsers.ParseRepositoryTag(repoDigest)
+					rows = append(rows, [3]string{repo, "<none>", digest})
+				}
+			}
 
-				if !*quiet {
-					fmt.Fprintf(w, "%s\t%s\t%s\t%s ago\t%s\n", repo, tag, outID, units.HumanDuration(time.Now().UTC().Sub(time.Unix(out.GetInt64("Created"), 0))), units.HumanSize(float64(out.GetInt64("VirtualSize"))))
-				} else {
+			for _, row := range rows {
+				if *quiet {
 					fmt.Fprintln(w, outID)
+					continue
+				}
+				created := units.HumanDuration(time.Now().UTC().Sub(time.Unix(out.GetInt64("Created"), 0)))
+				size := units.HumanSize(float64(out.GetInt64("VirtualSize")))
+				if *showDigests {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s ago\t%s\n", row[0], row[1], row[2], outID, created, size)
+				} else {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s ago\t%s\n", row[0], row[1], outID, created, size)
 				}
 			}
 		}
@@ -1552,7 +1800,7 @@ func (cli *DockerCli) CmdPs(args ...string) error {
 	)
 	cmd.Require(flag.Exact, 0)
 
-	cmd.Var(&flFilter, []string{"f", "-filter"}, "Provide filter values. Valid filters:\nexited=<int> - containers with exit code of <int>\nstatus=(restarting|running|paused|exited)")
+	cmd.Var(&flFilter, []string{"f", "-filter"}, "Provide filter values. Valid filters:\nexited=<int> - containers with exit code of <int>\nstatus=(restarting|running|paused|exited)\nlabel=<key> or label=<key>=<value>")
 
 	utils.ParseFlags(cmd, args, true)
 	if *last == -1 && *nLatest {
@@ -1959,12 +2207,39 @@ func (cli *DockerCli) CmdSearch(args ...string) error {
 	trusted := cmd.Bool([]string{"#t", "#trusted", "#-trusted"}, false, "Only show trusted builds")
 	automated := cmd.Bool([]string{"-automated"}, false, "Only show automated builds")
 	stars := cmd.Int([]string{"s", "#stars", "-stars"}, 0, "Only displays with at least x stars")
+	limit := cmd.Int([]string{"-limit"}, 25, "Max number of search results")
+	flFilter := opts.NewListOpts(nil)
+	cmd.Var(&flFilter, []string{"f", "-filter"}, "Provide filter values. Valid filters:\nstars=<int> - images with at least <int> stars\nis-official=(true|false)\nis-automated=(true|false)")
 	cmd.Require(flag.Exact, 1)
 
 	utils.ParseFlags(cmd, args, true)
 
+	// Consolidate all filter flags, and sanity check them early.
+	// They'll get processed in the daemon/server.
+	searchFilterArgs := filters.Args{}
+	for _, f := range flFilter.GetAll() {
+		var err error
+		if searchFilterArgs, err = filters.ParseFlag(f, searchFilterArgs); err != nil {
+			return err
+		}
+	}
+	if *automated || *trusted {
+		searchFilterArgs["is-automated"] = []string{"true"}
+	}
+	if *stars > 0 {
+		searchFilterArgs["stars"] = append(searchFilterArgs["stars"], strconv.Itoa(*stars))
+	}
+
 	v := url.Values{}
 	v.Set("term", cmd.Arg(0))
+	v.Set("limit", strconv.Itoa(*limit))
+	if len(searchFilterArgs) > 0 {
+		filterJson, err := filters.ToParam(searchFilterArgs)
+		if err != nil {
+			return err
+		}
+		v.Set("filters", filterJson)
+	}
 
 	body, _, err := readBody(cli.call("GET", "/images/search?"+v.Encode(), nil, true))
 
@@ -2136,7 +2411,7 @@ func (cli *DockerCli) createContainer(config *runconfig.Config, hostConfig *runc
 		if tag == "" {
 			tag = graph.DEFAULTTAG
 		}
-		fmt.Fprintf(cli.err, "Unable to find image '%s:%s' locally\n", repo, tag)
+		fmt.Fprintf(cli.err, "Unable to find image '%s' locally\n", utils.ImageReference(repo, tag))
 
 		// we don't want to write to stdout anything apart from container.ID
 		if err = cli.pullImageCustomOut(config.Image, cli.err); err != nil {
@@ -2274,7 +2549,7 @@ func (cli *DockerCli) CmdRun(args ...string) error {
 		}()
 	}
 
-	if *flAutoRemove && (hostConfig.RestartPolicy.Name == "always" || hostConfig.RestartPolicy.Name == "on-failure") {
+	if *flAutoRemove && (hostConfig.RestartPolicy.Name == "always" || hostConfig.RestartPolicy.Name == "on-failure" || hostConfig.RestartPolicy.Name == "on-unhealthy") {
 		return ErrConflictRestartPolicyAndAutoRemove
 	}
 
@@ -2436,6 +2711,7 @@ func (cli *DockerCli) CmdCp(args ...string) error {
 func (cli *DockerCli) CmdSave(args ...string) error {
 	cmd := cli.Subcmd("save", "IMAGE [IMAGE...]", "Save an image(s) to a tar archive (streamed to STDOUT by default)", true)
 	outfile := cmd.String([]string{"o", "-output"}, "", "Write to an file, instead of STDOUT")
+	format := cmd.String([]string{"-format"}, "legacy", "Layout of the archive, 'legacy' or 'v2' to include the signed manifests")
 	cmd.Require(flag.Min, 1)
 
 	utils.ParseFlags(cmd, args, true)
@@ -2453,13 +2729,16 @@ func (cli *DockerCli) CmdSave(args ...string) error {
 		return errors.New("Cowardly refusing to save to a terminal. Use the -o flag or redirect.")
 	}
 
+	v := url.Values{}
+	if *format != "legacy" {
+		v.Set("format", *format)
+	}
 	if len(cmd.Args()) == 1 {
 		image := cmd.Arg(0)
-		if err := cli.stream("GET", "/images/"+image+"/get", nil, output, nil); err != nil {
+		if err := cli.stream("GET", "/images/"+image+"/get?"+v.Encode(), nil, output, nil); err != nil {
 			return err
 		}
 	} else {
-		v := url.Values{}
 		for _, arg := range cmd.Args() {
 			v.Add("names", arg)
 		}
@@ -2611,6 +2890,11 @@ type containerStats struct {
 	MemoryPercentage float64
 	NetworkRx        float64
 	NetworkTx        float64
+	NetworkRxPackets uint64
+	NetworkTxPackets uint64
+	NetworkErrors    uint64
+	NetworkDropped   uint64
+	Networks         map[string]stats.Network
 	mu               sync.RWMutex
 	err              error
 }
@@ -2636,22 +2920,12 @@ func (s *containerStats) Collect(cli *DockerCli) {
 				u <- err
 				return
 			}
-			var (
-				memPercent = float64(v.MemoryStats.Usage) / float64(v.MemoryStats.Limit) * 100.0
-				cpuPercent = 0.0
-			)
+			cpuPercent := 0.0
 			if !start {
 				cpuPercent = calcuateCpuPercent(previousCpu, previousSystem, v)
 			}
 			start = false
-			s.mu.Lock()
-			s.CpuPercentage = cpuPercent
-			s.Memory = float64(v.MemoryStats.Usage)
-			s.MemoryLimit = float64(v.MemoryStats.Limit)
-			s.MemoryPercentage = memPercent
-			s.NetworkRx = float64(v.Network.RxBytes)
-			s.NetworkTx = float64(v.Network.TxBytes)
-			s.mu.Unlock()
+			s.update(v, cpuPercent)
 			previousCpu = v.CpuStats.CpuUsage.TotalUsage
 			previousSystem = v.CpuStats.SystemUsage
 			u <- nil
@@ -2678,23 +2952,80 @@ func (s *containerStats) Collect(cli *DockerCli) {
 	}
 }
 
+// CollectOnce reads a single stats sample for the container.  The cpu usage
+// is computed from the previous reading returned with the sample.
+func (s *containerStats) CollectOnce(cli *DockerCli) {
+	stream, _, err := cli.call("GET", "/containers/"+s.Name+"/stats?stream=0", nil, false)
+	if err != nil {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		return
+	}
+	defer stream.Close()
+	var v *stats.Stats
+	if err := json.NewDecoder(stream).Decode(&v); err != nil {
+		s.mu.Lock()
+		s.err = err
+		s.mu.Unlock()
+		return
+	}
+	s.update(v, calcuateCpuPercent(v.PreCpuStats.CpuUsage.TotalUsage, v.PreCpuStats.SystemUsage, v))
+}
+
+func (s *containerStats) update(v *stats.Stats, cpuPercent float64) {
+	s.mu.Lock()
+	s.CpuPercentage = cpuPercent
+	s.Memory = float64(v.MemoryStats.Usage)
+	s.MemoryLimit = float64(v.MemoryStats.Limit)
+	s.MemoryPercentage = float64(v.MemoryStats.Usage) / float64(v.MemoryStats.Limit) * 100.0
+	net := v.Network
+	s.NetworkRx = float64(net.RxBytes)
+	s.NetworkTx = float64(net.TxBytes)
+	s.NetworkRxPackets = net.RxPackets
+	s.NetworkTxPackets = net.TxPackets
+	s.NetworkErrors = net.RxErrors + net.TxErrors
+	s.NetworkDropped = net.RxDropped + net.TxDropped
+	s.Networks = v.Networks
+	s.mu.Unlock()
+}
+
 func (s *containerStats) Display(w io.Writer) error {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 	if s.err != nil {
 		return s.err
 	}
-	fmt.Fprintf(w, "%s\t%.2f%%\t%s/%s\t%.2f%%\t%s/%s\n",
+	fmt.Fprintf(w, "%s\t%.2f%%\t%s/%s\t%.2f%%\t%s/%s\t%d/%d\t%d/%d\n",
 		s.Name,
 		s.CpuPercentage,
 		units.BytesSize(s.Memory), units.BytesSize(s.MemoryLimit),
 		s.MemoryPercentage,
-		units.BytesSize(s.NetworkRx), units.BytesSize(s.NetworkTx))
+		units.BytesSize(s.NetworkRx), units.BytesSize(s.NetworkTx),
+		s.NetworkRxPackets, s.NetworkTxPackets,
+		s.NetworkErrors, s.NetworkDropped)
+	// break the network columns down by interface when there is more than one
+	if len(s.Networks) > 1 {
+		names := make([]string, 0, len(s.Networks))
+		for name := range s.Networks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			n := s.Networks[name]
+			fmt.Fprintf(w, "  %s\t\t\t\t%s/%s\t%d/%d\t%d/%d\n",
+				name,
+				units.BytesSize(float64(n.RxBytes)), units.BytesSize(float64(n.TxBytes)),
+				n.RxPackets, n.TxPackets,
+				n.RxErrors+n.TxErrors, n.RxDropped+n.TxDropped)
+		}
+	}
 	return nil
 }
 
 func (cli *DockerCli) CmdStats(args ...string) error {
 	cmd := cli.Subcmd("stats", "CONTAINER", "Display a live stream of one or more containers' resource usage statistics", true)
+	noStream := cmd.Bool([]string{"-no-stream"}, false, "Disable streaming stats and only pull the first result")
 	cmd.Require(flag.Min, 1)
 	utils.ParseFlags(cmd, args, true)
 
@@ -2705,9 +3036,36 @@ func (cli *DockerCli) CmdStats(args ...string) error {
 		w      = tabwriter.NewWriter(cli.out, 20, 1, 3, ' ', 0)
 	)
 	printHeader := func() {
-		fmt.Fprint(cli.out, "\033[2J")
-		fmt.Fprint(cli.out, "\033[H")
-		fmt.Fprintln(w, "CONTAINER\tCPU %\tMEM USAGE/LIMIT\tMEM %\tNET I/O")
+		if !*noStream {
+			fmt.Fprint(cli.out, "\033[2J")
+			fmt.Fprint(cli.out, "\033[H")
+		}
+		fmt.Fprintln(w, "CONTAINER\tCPU %\tMEM USAGE/LIMIT\tMEM %\tNET I/O\tNET PACKETS\tNET ERRORS/DROPPED")
+	}
+	if *noStream {
+		var wg sync.WaitGroup
+		for _, n := range names {
+			s := &containerStats{Name: n}
+			cStats = append(cStats, s)
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				s.CollectOnce(cli)
+			}()
+		}
+		wg.Wait()
+		printHeader()
+		var errs []string
+		for _, s := range cStats {
+			if err := s.Display(w); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", s.Name, err.Error()))
+			}
+		}
+		w.Flush()
+		if len(errs) > 0 {
+			return fmt.Errorf("%s", strings.Join(errs, ", "))
+		}
+		return nil
 	}
 	for _, n := range names {
 		s := &containerStats{Name: n}
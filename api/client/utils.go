@@ -66,7 +66,7 @@ func (cli *DockerCli) call(method, path string, data interface{}, passAuthInfo b
 	if passAuthInfo {
 		cli.LoadConfigFile()
 		// Resolve the Auth config relevant for this server
-		authConfig := cli.configFile.Configs[registry.IndexServerAddress()]
+		authConfig, _ := cli.configFile.GetAuthConfig(registry.IndexServerAddress())
 		getHeaders := func(authConfig registry.AuthConfig) (map[string][]string, error) {
 			buf, err := json.Marshal(authConfig)
 			if err != nil {
@@ -123,13 +123,49 @@ func (cli *DockerCli) stream(method, path string, in io.Reader, out io.Writer, h
 }
 
 func (cli *DockerCli) streamHelper(method, path string, setRawTerminal bool, in io.Reader, stdout, stderr io.Writer, headers map[string][]string) error {
+	resp, err := cli.streamRequest(method, path, in, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if api.MatchesContentType(resp.Header.Get("Content-Type"), "application/json") {
+		return utils.DisplayJSONMessagesStream(resp.Body, stdout, cli.outFd, cli.isTerminalOut)
+	}
+	if stdout != nil || stderr != nil {
+		// When TTY is ON, use regular copy
+		if setRawTerminal {
+			_, err = io.Copy(stdout, resp.Body)
+		} else {
+			_, err = stdcopy.StdCopy(stdout, stderr, resp.Body)
+		}
+		log.Debugf("[stream] End of stdout")
+		return err
+	}
+	return nil
+}
+
+// streamJSONMessages is like stream, but prints the JSON messages of the
+// response one per line instead of displaying them.
+func (cli *DockerCli) streamJSONMessages(method, path string, in io.Reader, out io.Writer, headers map[string][]string) error {
+	resp, err := cli.streamRequest(method, path, in, headers)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return utils.CopyJSONMessagesStream(resp.Body, out)
+}
+
+// streamRequest sends a request whose response is streamed, and returns the
+// response if its status is successful.
+func (cli *DockerCli) streamRequest(method, path string, in io.Reader, headers map[string][]string) (*http.Response, error) {
 	if (method == "POST" || method == "PUT") && in == nil {
 		in = bytes.NewReader([]byte{})
 	}
 
 	req, err := http.NewRequest(method, fmt.Sprintf("/v%s%s", api.APIVERSION, path), in)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	req.Header.Set("User-Agent", "Docker-Client/"+dockerversion.VERSION)
 	req.URL.Host = cli.addr
@@ -146,37 +182,23 @@ func (cli *DockerCli) streamHelper(method, path string, setRawTerminal bool, in
 	resp, err := cli.HTTPClient().Do(req)
 	if err != nil {
 		if strings.Contains(err.Error(), "connection refused") {
-			return fmt.Errorf("Cannot connect to the Docker daemon. Is 'docker -d' running on this host?")
+			return nil, fmt.Errorf("Cannot connect to the Docker daemon. Is 'docker -d' running on this host?")
 		}
-		return err
+		return nil, err
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return err
+			return nil, err
 		}
 		if len(body) == 0 {
-			return fmt.Errorf("Error :%s", http.StatusText(resp.StatusCode))
-		}
-		return fmt.Errorf("Error: %s", bytes.TrimSpace(body))
-	}
-
-	if api.MatchesContentType(resp.Header.Get("Content-Type"), "application/json") {
-		return utils.DisplayJSONMessagesStream(resp.Body, stdout, cli.outFd, cli.isTerminalOut)
-	}
-	if stdout != nil || stderr != nil {
-		// When TTY is ON, use regular copy
-		if setRawTerminal {
-			_, err = io.Copy(stdout, resp.Body)
-		} else {
-			_, err = stdcopy.StdCopy(stdout, stderr, resp.Body)
+			return nil, fmt.Errorf("Error :%s", http.StatusText(resp.StatusCode))
 		}
-		log.Debugf("[stream] End of stdout")
-		return err
+		return nil, fmt.Errorf("Error: %s", bytes.TrimSpace(body))
 	}
-	return nil
+	return resp, nil
 }
 
 func (cli *DockerCli) resizeTty(id string, isExec bool) {
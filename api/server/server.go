@@ -228,6 +228,24 @@ func postContainersPause(eng *engine.Engine, version version.Version, w http.Res
 	return nil
 }
 
+func postContainersUpdate(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	if err := checkForJson(r); err != nil {
+		return err
+	}
+	job := eng.Job("container_update", vars["name"])
+	if err := job.DecodeEnv(r.Body); err != nil {
+		return err
+	}
+	if err := job.Run(); err != nil {
+		return err
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return nil
+}
+
 func postContainersUnpause(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
@@ -319,6 +337,13 @@ func getInfo(eng *engine.Engine, version version.Version, w http.ResponseWriter,
 	return nil
 }
 
+func getMetrics(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	job := eng.Job("metrics")
+	job.Stdout.Add(w)
+	return job.Run()
+}
+
 func getEvents(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if err := parseForm(r); err != nil {
 		return err
@@ -346,6 +371,15 @@ func getImagesHistory(eng *engine.Engine, version version.Version, w http.Respon
 	return nil
 }
 
+func getImagesSignatures(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if vars == nil {
+		return fmt.Errorf("Missing parameter")
+	}
+	var job = eng.Job("image_signatures", vars["name"])
+	streamJSON(job, w, false)
+	return job.Run()
+}
+
 func getContainersChanges(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
@@ -418,9 +452,27 @@ func getContainersStats(eng *engine.Engine, version version.Version, w http.Resp
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
 	}
+	stream := true
+	if value := r.Form.Get("stream"); value != "" {
+		var err error
+		if stream, err = getBoolParam(value); err != nil {
+			return err
+		}
+	}
 	name := vars["name"]
 	job := eng.Job("container_stats", name)
-	streamJSON(job, w, true)
+	job.SetenvBool("stream", stream)
+	streamJSON(job, w, stream)
+	return job.Run()
+}
+
+func getContainersStatsAll(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	if err := parseForm(r); err != nil {
+		return err
+	}
+	job := eng.Job("containers_stats")
+	job.Setenv("filters", r.Form.Get("filters"))
+	streamJSON(job, w, false)
 	return job.Run()
 }
 
@@ -564,6 +616,7 @@ func postImagesCreate(eng *engine.Engine, version version.Version, w http.Respon
 		job.SetenvBool("parallel", version.GreaterThan("1.3"))
 		job.SetenvJson("metaHeaders", metaHeaders)
 		job.SetenvJson("authConfig", authConfig)
+		job.Setenv("structured", r.Form.Get("structured"))
 	} else { //import
 		if tag == "" {
 			repo, tag = parsers.ParseRepositoryTag(repo)
@@ -616,6 +669,8 @@ func getImagesSearch(eng *engine.Engine, version version.Version, w http.Respons
 	var job = eng.Job("search", r.Form.Get("term"))
 	job.SetenvJson("metaHeaders", metaHeaders)
 	job.SetenvJson("authConfig", authConfig)
+	job.Setenv("limit", r.Form.Get("limit"))
+	job.Setenv("filters", r.Form.Get("filters"))
 	streamJSON(job, w, false)
 
 	return job.Run()
@@ -655,7 +710,9 @@ func postImagesPush(eng *engine.Engine, version version.Version, w http.Response
 	job := eng.Job("push", vars["name"])
 	job.SetenvJson("metaHeaders", metaHeaders)
 	job.SetenvJson("authConfig", authConfig)
+	job.SetenvList("signKeys", r.Form["signkey"])
 	job.Setenv("tag", r.Form.Get("tag"))
+	job.Setenv("structured", r.Form.Get("structured"))
 	if version.GreaterThan("1.0") {
 		job.SetenvBool("json", true)
 		streamJSON(job, w, true)
@@ -689,6 +746,7 @@ func getImagesGet(eng *engine.Engine, version version.Version, w http.ResponseWr
 	} else {
 		job = eng.Job("image_export", r.Form["names"]...)
 	}
+	job.Setenv("format", r.Form.Get("format"))
 	job.Stdout.Add(w)
 	return job.Run()
 }
@@ -1071,6 +1129,10 @@ func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWrite
 	job.Setenv("q", r.FormValue("q"))
 	job.Setenv("nocache", r.FormValue("nocache"))
 	job.Setenv("forcerm", r.FormValue("forcerm"))
+	job.Setenv("buildargs", r.FormValue("buildargs"))
+	job.Setenv("cachefrom", r.FormValue("cachefrom"))
+	job.Setenv("squash", r.FormValue("squash"))
+	job.Setenv("structured", r.FormValue("structured"))
 	job.SetenvJson("authConfig", authConfig)
 	job.SetenvJson("configFile", configFile)
 
@@ -1084,6 +1146,13 @@ func postBuild(eng *engine.Engine, version version.Version, w http.ResponseWrite
 	return nil
 }
 
+func postBuildValidate(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
+	var job = eng.Job("build_validate")
+	streamJSON(job, w, false)
+	job.Stdin.Add(r.Body)
+	return job.Run()
+}
+
 func postContainersCopy(eng *engine.Engine, version version.Version, w http.ResponseWriter, r *http.Request, vars map[string]string) error {
 	if vars == nil {
 		return fmt.Errorf("Missing parameter")
@@ -1302,6 +1371,7 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion st
 			"/_ping":                          ping,
 			"/events":                         getEvents,
 			"/info":                           getInfo,
+			"/metrics":                        getMetrics,
 			"/version":                        getVersion,
 			"/images/json":                    getImagesJSON,
 			"/images/viz":                     getImagesViz,
@@ -1309,9 +1379,11 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion st
 			"/images/get":                     getImagesGet,
 			"/images/{name:.*}/get":           getImagesGet,
 			"/images/{name:.*}/history":       getImagesHistory,
+			"/images/{name:.*}/signatures":    getImagesSignatures,
 			"/images/{name:.*}/json":          getImagesByName,
 			"/containers/ps":                  getContainersJSON,
 			"/containers/json":                getContainersJSON,
+			"/containers/stats":               getContainersStatsAll,
 			"/containers/{name:.*}/export":    getContainersExport,
 			"/containers/{name:.*}/changes":   getContainersChanges,
 			"/containers/{name:.*}/json":      getContainersByName,
@@ -1325,6 +1397,7 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion st
 			"/auth":                         postAuth,
 			"/commit":                       postCommit,
 			"/build":                        postBuild,
+			"/build/validate":               postBuildValidate,
 			"/images/create":                postImagesCreate,
 			"/images/load":                  postImagesLoad,
 			"/images/{name:.*}/push":        postImagesPush,
@@ -1344,6 +1417,7 @@ func createRouter(eng *engine.Engine, logging, enableCors bool, dockerVersion st
 			"/exec/{name:.*}/start":         postContainerExecStart,
 			"/exec/{name:.*}/resize":        postContainerExecResize,
 			"/containers/{name:.*}/rename":  postContainerRename,
+			"/containers/{name:.*}/update":  postContainersUpdate,
 		},
 		"DELETE": {
 			"/containers/{name:.*}": deleteContainers,
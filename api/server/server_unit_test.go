@@ -349,6 +349,77 @@ func TestLogs(t *testing.T) {
 	}
 }
 
+func TestGetContainersStatsNoStream(t *testing.T) {
+	eng := engine.New()
+	var called bool
+	eng.Register("container_stats", func(job *engine.Job) engine.Status {
+		called = true
+		if len(job.Args) != 1 || job.Args[0] != "test" {
+			t.Fatalf("Expected the stats of container test, got %v", job.Args)
+		}
+		if !job.EnvExists("stream") || job.GetenvBool("stream") {
+			t.Fatalf("stream: %q, must be false", job.Getenv("stream"))
+		}
+		job.Stdout.Write([]byte(`{"read":"2015-01-08T22:57:31.547920715Z"}` + "\n"))
+		return engine.StatusOK
+	})
+	r := serveRequest("GET", "/containers/test/stats?stream=false", nil, eng, t)
+	assertHttpNotError(r, t)
+	if !called {
+		t.Fatal("container_stats job was not called")
+	}
+	assertContentType(r, "application/json", t)
+}
+
+func TestGetContainersStatsAll(t *testing.T) {
+	eng := engine.New()
+	var called bool
+	eng.Register("containers_stats", func(job *engine.Job) engine.Status {
+		called = true
+		if filters := job.Getenv("filters"); filters != `{"status":["running"]}` {
+			t.Fatalf("filters: %q, must be passed through", filters)
+		}
+		job.Stdout.Write([]byte(`[{"id":"a"},{"id":"b"}]` + "\n"))
+		return engine.StatusOK
+	})
+	r := serveRequest("GET", `/containers/stats?filters={"status":["running"]}`, nil, eng, t)
+	assertHttpNotError(r, t)
+	if !called {
+		t.Fatal("containers_stats job was not called")
+	}
+	var all []map[string]interface{}
+	if err := json.Unmarshal(r.Body.Bytes(), &all); err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("Expected the 2 entries of the job, got %s", r.Body.String())
+	}
+}
+
+func TestPostImagesPushSignKeys(t *testing.T) {
+	eng := engine.New()
+	var called bool
+	eng.Register("push", func(job *engine.Job) engine.Status {
+		called = true
+		signKeys := job.GetenvList("signKeys")
+		if len(signKeys) != 2 || signKeys[0] != "/keys/a.json" || signKeys[1] != "/keys/b.pem" {
+			t.Fatalf("signKeys: %v, must be the paths of the request", signKeys)
+		}
+		return engine.StatusOK
+	})
+	req, err := http.NewRequest("POST", "/images/test/push?signkey=/keys/a.json&signkey=/keys/b.pem", strings.NewReader(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("X-Registry-Auth", "e30=")
+	r := httptest.NewRecorder()
+	ServeRequest(eng, api.APIVERSION, r, req)
+	assertHttpNotError(r, t)
+	if !called {
+		t.Fatal("push job was not called")
+	}
+}
+
 func TestLogsNoStreams(t *testing.T) {
 	eng := engine.New()
 	var inspect bool
@@ -79,9 +79,23 @@ type Network struct {
 }
 
 type Stats struct {
-	Read        time.Time   `json:"read"`
-	Network     Network     `json:"network,omitempty"`
-	CpuStats    CpuStats    `json:"cpu_stats,omitempty"`
+	Read time.Time `json:"read"`
+	// aggregated network stats of all the container's interfaces
+	Network Network `json:"network,omitempty"`
+	// network stats per interface, indexed by the interface name inside the container
+	Networks map[string]Network `json:"networks,omitempty"`
+	CpuStats CpuStats           `json:"cpu_stats,omitempty"`
+	// cpu stats of the previous reading, only set for one-shot reads so that
+	// the cpu usage between the two readings can be computed.
+	PreCpuStats CpuStats    `json:"precpu_stats,omitempty"`
 	MemoryStats MemoryStats `json:"memory_stats,omitempty"`
 	BlkioStats  BlkioStats  `json:"blkio_stats,omitempty"`
 }
+
+// ContainerStats is a stats reading for a single container as returned
+// when reading the stats of several containers at once.
+type ContainerStats struct {
+	Id    string `json:"id"`
+	Name  string `json:"name"`
+	Stats *Stats `json:"stats"`
+}
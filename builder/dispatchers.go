@@ -14,10 +14,12 @@ import (
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/nat"
 	flag "github.com/docker/docker/pkg/mflag"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/runconfig"
 )
 
@@ -27,6 +29,9 @@ const (
 	NoBaseImageSpecifier string = "scratch"
 )
 
+// names of build stages, as in FROM image AS name
+var validStageName = regexp.MustCompile(`^[a-z][a-z0-9-_\.]*$`)
+
 // dispatch with no layer / parsing. This is effectively not a command.
 func nullDispatch(b *Builder, args []string, attributes map[string]bool, original string) error {
 	return nil
@@ -73,6 +78,38 @@ func env(b *Builder, args []string, attributes map[string]bool, original string)
 	return b.commit("", b.Config.Cmd, commitStr)
 }
 
+// LABEL some json data describing the image
+//
+// Sets the label foo to bar in the image. Several labels can be set at once
+// with LABEL foo=bar baz=qux.
+//
+func label(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("LABEL requires at least one argument")
+	}
+	if len(args)%2 != 0 {
+		// should never get here, but just in case
+		return fmt.Errorf("Bad input to LABEL, too many args")
+	}
+
+	// The labels may be those of the base image, copy them before changing
+	labels := make(map[string]string, len(b.Config.Labels)+len(args)/2)
+	for k, v := range b.Config.Labels {
+		labels[k] = v
+	}
+
+	commitStr := "LABEL"
+	for j := 0; j < len(args); j += 2 {
+		// name  ==> args[j]
+		// value ==> args[j+1]
+		commitStr += " " + args[j] + "=" + args[j+1]
+		labels[args[j]] = args[j+1]
+	}
+	b.Config.Labels = labels
+
+	return b.commit("", b.Config.Cmd, commitStr)
+}
+
 // MAINTAINER some text <maybe@an.email.address>
 //
 // Sets the maintainer metadata.
@@ -95,30 +132,67 @@ func add(b *Builder, args []string, attributes map[string]bool, original string)
 		return fmt.Errorf("ADD requires at least two arguments")
 	}
 
-	return b.runContextCommand(args, true, true, "ADD")
+	return b.runContextCommand(args, true, true, "ADD", "")
 }
 
 // COPY foo /path
 //
-// Same as 'ADD' but without the tar and remote url handling.
+// Same as 'ADD' but without the tar and remote url handling. With
+// COPY --from=stage foo /path, foo is copied out of the root filesystem of an
+// earlier stage, named or numbered from 0, or of an image.
 //
 func dispatchCopy(b *Builder, args []string, attributes map[string]bool, original string) error {
 	if len(args) < 2 {
 		return fmt.Errorf("COPY requires at least two arguments")
 	}
 
-	return b.runContextCommand(args, false, false, "COPY")
+	from, ok := b.flags["from"]
+	if !ok {
+		return b.runContextCommand(args, false, false, "COPY", "")
+	}
+
+	container, err := b.stageContainer(from)
+	if err != nil {
+		return err
+	}
+	defer container.Unmount()
+
+	return b.runContextCommand(args, false, false, "COPY", container.RootfsPath())
 }
 
 // FROM imagename
+// FROM imagename AS name
 //
-// This sets the image the dockerfile will build on top of.
+// This sets the image the dockerfile will build on top of. Every FROM after
+// the first starts a new stage of the build, which the instructions that
+// follow build from scratch. The name lets COPY --from refer to the stage.
 //
 func from(b *Builder, args []string, attributes map[string]bool, original string) error {
-	if len(args) != 1 {
-		return fmt.Errorf("FROM requires one argument")
+	if len(args) != 1 && (len(args) != 3 || !strings.EqualFold(args[1], "as")) {
+		return fmt.Errorf("FROM requires either one argument, or three: FROM <image> AS <name>")
+	}
+
+	stageName := ""
+	if len(args) == 3 {
+		stageName = strings.ToLower(args[2])
+		if !validStageName.MatchString(stageName) {
+			return fmt.Errorf("Invalid name for build stage: %q, name can't start with a number or contain symbols", args[2])
+		}
+		if stageName == b.stageName {
+			return fmt.Errorf("Duplicate name for build stage: %q", args[2])
+		}
+		for _, stage := range b.stages {
+			if stage.name == stageName {
+				return fmt.Errorf("Duplicate name for build stage: %q", args[2])
+			}
+		}
 	}
 
+	if b.image != "" || b.noBaseImage {
+		b.nextStage()
+	}
+	b.stageName = stageName
+
 	name := args[0]
 
 	if name == NoBaseImageSpecifier {
@@ -226,11 +300,25 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 	// set Cmd manually, this is special case only for Dockerfiles
 	b.Config.Cmd = config.Cmd
 	runconfig.Merge(b.Config, config)
+	env := b.Config.Env
 
 	defer func(cmd []string) { b.Config.Cmd = cmd }(cmd)
+	defer func(env []string) { b.Config.Env = env }(env)
 
 	log.Debugf("[BUILDER] Command to be executed: %v", b.Config.Cmd)
 
+	// Build-time variables are passed to the command, but kept out of the
+	// environment of the image. They are prepended to the command committed
+	// instead, as "|<count> KEY=value...", so that the cache only matches a
+	// RUN with the same variables. No command can start with a "|".
+	buildEnv := b.buildArgsEnv()
+	saveCmd := config.Cmd
+	if len(buildEnv) > 0 {
+		saveCmd = append([]string{fmt.Sprintf("|%d", len(buildEnv))}, buildEnv...)
+		saveCmd = append(saveCmd, config.Cmd...)
+	}
+	b.Config.Cmd = saveCmd
+
 	hit, err := b.probeCache()
 	if err != nil {
 		return err
@@ -239,6 +327,9 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 		return nil
 	}
 
+	b.Config.Cmd = config.Cmd
+	b.Config.Env = append(append([]string{}, env...), buildEnv...)
+
 	c, err := b.create()
 	if err != nil {
 		return err
@@ -253,6 +344,11 @@ func run(b *Builder, args []string, attributes map[string]bool, original string)
 	if err != nil {
 		return err
 	}
+
+	// The container shares the configuration, restore it so that the
+	// variables are not committed and future cache look-ups match
+	b.Config.Env = env
+	b.Config.Cmd = saveCmd
 	if err := b.commit(c.ID, cmd, "run"); err != nil {
 		return err
 	}
@@ -388,6 +484,107 @@ func volume(b *Builder, args []string, attributes map[string]bool, original stri
 	return nil
 }
 
+// ARG name[=default]
+//
+// Declares the build-time variable name, which the client may set with
+// --build-arg. The variable is available for interpolation and in the
+// environment of RUN from the next statement on, but it is not part of the
+// environment of the image.
+//
+func arg(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("ARG requires exactly one argument definition")
+	}
+
+	if err := b.declareArg(args[0]); err != nil {
+		return err
+	}
+
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("ARG %s", args[0]))
+}
+
+// STOPSIGNAL signal
+//
+// Set the signal sent to the container by docker stop, and by docker kill
+// when it is given no signal. The signal is a number or a name such as
+// SIGKILL.
+//
+func stopSignal(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("STOPSIGNAL requires exactly one argument")
+	}
+
+	if _, err := signal.ParseSignal(args[0]); err != nil {
+		return err
+	}
+
+	b.Config.StopSignal = args[0]
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("STOPSIGNAL %v", args))
+}
+
+// HEALTHCHECK [--interval=30s] [--timeout=30s] CMD command
+// HEALTHCHECK NONE
+//
+// Set the command the daemon runs in the container every interval to check
+// that it is still working, or disable the check inherited from the base
+// image. Like RUN, the command is run with "/bin/sh -c" unless it is given
+// as a JSON array.
+//
+func healthcheck(b *Builder, args []string, attributes map[string]bool, original string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("HEALTHCHECK requires an argument")
+	}
+
+	var healthConfig runconfig.HealthConfig
+	switch typ := strings.ToUpper(args[0]); typ {
+	case "NONE":
+		if len(args) != 1 || len(b.flags) != 0 {
+			return fmt.Errorf("HEALTHCHECK NONE takes no other argument")
+		}
+		healthConfig.Test = []string{typ}
+	case "CMD":
+		probe := handleJsonArgs(args[1:], attributes)
+		if len(probe) == 0 || probe[0] == "" {
+			return fmt.Errorf("HEALTHCHECK CMD requires a command")
+		}
+		if attributes["json"] {
+			healthConfig.Test = append([]string{"CMD"}, probe...)
+		} else {
+			healthConfig.Test = []string{"CMD-SHELL", strings.Join(probe, " ")}
+		}
+
+		var err error
+		if healthConfig.Interval, err = parseHealthDuration(b.flags, "interval"); err != nil {
+			return err
+		}
+		if healthConfig.Timeout, err = parseHealthDuration(b.flags, "timeout"); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("Unknown type %q in HEALTHCHECK (try CMD or NONE)", args[0])
+	}
+
+	b.Config.Healthcheck = &healthConfig
+	return b.commit("", b.Config.Cmd, fmt.Sprintf("HEALTHCHECK %v", healthConfig.Test))
+}
+
+// parseHealthDuration parses a duration flag of HEALTHCHECK, which must be
+// positive. It returns 0, the default of the daemon, if the flag is not set.
+func parseHealthDuration(flags map[string]string, name string) (time.Duration, error) {
+	value, ok := flags[name]
+	if !ok {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid duration on flag: --%s: %s", name, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("Duration on flag --%s must be positive, got %s", name, value)
+	}
+	return d, nil
+}
+
 // INSERT is no longer accepted, but we still parse it.
 func insert(b *Builder, args []string, attributes map[string]bool, original string) error {
 	return fmt.Errorf("INSERT has been deprecated. Please use ADD instead")
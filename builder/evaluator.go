@@ -25,12 +25,15 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/builder/parser"
 	"github.com/docker/docker/daemon"
 	"github.com/docker/docker/engine"
+	imagepkg "github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/fileutils"
 	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/pkg/tarsum"
@@ -45,33 +48,46 @@ var (
 
 // Environment variable interpolation will happen on these statements only.
 var replaceEnvAllowed = map[string]struct{}{
-	"env":     {},
-	"add":     {},
-	"copy":    {},
-	"workdir": {},
-	"expose":  {},
-	"volume":  {},
-	"user":    {},
+	"env":        {},
+	"label":      {},
+	"add":        {},
+	"copy":       {},
+	"workdir":    {},
+	"expose":     {},
+	"volume":     {},
+	"user":       {},
+	"stopsignal": {},
+}
+
+// Flags accepted by the instructions, as in COPY --from=builder. Any other
+// flag fails the build.
+var instructionFlags = map[string]map[string]struct{}{
+	"copy":        {"from": {}},
+	"healthcheck": {"interval": {}, "timeout": {}},
 }
 
 var evaluateTable map[string]func(*Builder, []string, map[string]bool, string) error
 
 func init() {
 	evaluateTable = map[string]func(*Builder, []string, map[string]bool, string) error{
-		"env":        env,
-		"maintainer": maintainer,
-		"add":        add,
-		"copy":       dispatchCopy, // copy() is a go builtin
-		"from":       from,
-		"onbuild":    onbuild,
-		"workdir":    workdir,
-		"run":        run,
-		"cmd":        cmd,
-		"entrypoint": entrypoint,
-		"expose":     expose,
-		"volume":     volume,
-		"user":       user,
-		"insert":     insert,
+		"env":         env,
+		"maintainer":  maintainer,
+		"add":         add,
+		"copy":        dispatchCopy, // copy() is a go builtin
+		"from":        from,
+		"onbuild":     onbuild,
+		"workdir":     workdir,
+		"run":         run,
+		"cmd":         cmd,
+		"entrypoint":  entrypoint,
+		"expose":      expose,
+		"volume":      volume,
+		"user":        user,
+		"insert":      insert,
+		"arg":         arg,
+		"label":       label,
+		"stopsignal":  stopSignal,
+		"healthcheck": healthcheck,
 	}
 }
 
@@ -94,6 +110,21 @@ type Builder struct {
 	ForceRemove bool
 	Pull        bool
 
+	// build-time variables set by the client, only available to the
+	// instructions which follow their declaration with ARG
+	BuildArgs map[string]string
+
+	// images, pulled if needed, the history of which is used as a cache too
+	CacheFrom []string
+
+	// squash the layers of the last stage into a single one on top of its
+	// base image
+	Squash bool
+
+	// report every step, and a summary of the build, as structured JSON
+	// messages along with the text output
+	Structured bool
+
 	AuthConfig     *registry.AuthConfig
 	AuthConfigFile *registry.ConfigFile
 
@@ -114,6 +145,34 @@ type Builder struct {
 	context        tarsum.TarSum // the context is a tarball that is uploaded by the client
 	contextPath    string        // the path of the temporary directory the local context is unpacked to (server side)
 	noBaseImage    bool          // indicates that this build does not start from any base image, but is being built from an empty file system.
+
+	allowedBuildArgs map[string]bool   // build-time variables declared with ARG so far, false once out of scope
+	argDefaults      map[string]string // default values of the build-time variables declared in the current stage
+
+	flags     map[string]string // flags of the instruction being dispatched
+	stageName string            // name given to the current stage with FROM ... AS name
+	stages    []buildStage      // the stages before the current one, in order
+	baseImage string            // the image the current stage is built FROM, empty for scratch
+	step      *buildStep        // the step being run, for the structured output
+
+	cacheFrom       map[string][]*imagepkg.Image // images of the CacheFrom histories, by parent
+	instruction     string                       // the instruction being dispatched, once evaluated
+	contextChecksum string                       // checksum of the context files the instruction uses
+}
+
+// buildStep is what the structured output reports about a step, as the
+// instructions and triggers it runs go.
+type buildStep struct {
+	start       time.Time
+	instruction string // the instruction of the step, before any ONBUILD trigger it runs
+	cached      bool   // whether the cache was used
+	container   string // the last container the step ran
+}
+
+// buildStage is a stage of a multi-stage build which a later FROM ended.
+type buildStage struct {
+	name  string // the name of the stage, empty if it has none
+	image string // the last image of the stage
 }
 
 // Run the builder with the context. This is the lynchpin of this package. This
@@ -123,12 +182,29 @@ type Builder struct {
 //   the context into it.
 // * read the dockerfile
 // * parse the dockerfile
+// * pull the CacheFrom images which do not exist locally.
 // * walk the parse tree and execute it by dispatching to handlers. If Remove
 //   or ForceRemove is set, additional cleanup around containers happens after
-//   processing.
+//   processing. Every FROM after the first starts a new stage, only the image
+//   of the last stage is the result of the build.
+// * check that every build-time variable was declared by an ARG.
+// * squash the layers of the last stage into one if Squash is set.
 // * Print a happy message and return the image ID.
 //
-func (b *Builder) Run(context io.Reader) (string, error) {
+// The structured output ends with a summary of the build, whether it
+// succeeded or not.
+func (b *Builder) Run(context io.Reader) (imageID string, err error) {
+	summary := &utils.JSONBuildSummary{}
+	start := time.Now()
+	defer func() {
+		summary.ImageID = imageID
+		summary.Duration = time.Since(start).Seconds()
+		if err != nil {
+			summary.Error = err.Error()
+		}
+		b.reportSummary(summary)
+	}()
+
 	if err := b.readContext(context); err != nil {
 		return "", err
 	}
@@ -146,9 +222,20 @@ func (b *Builder) Run(context io.Reader) (string, error) {
 	// some initializations that would not have been supplied by the caller.
 	b.Config = &runconfig.Config{}
 	b.TmpContainers = map[string]struct{}{}
+	b.allowedBuildArgs = map[string]bool{}
+	b.argDefaults = map[string]string{}
+	b.stages = []buildStage{}
+	if b.BuildArgs == nil {
+		b.BuildArgs = map[string]string{}
+	}
+	if err := b.loadCacheFrom(); err != nil {
+		return "", err
+	}
 
 	for i, n := range b.dockerfile.Children {
+		b.step = &buildStep{start: time.Now()}
 		if err := b.dispatch(i, n); err != nil {
+			b.reportStep(i, err)
 			if b.ForceRemove {
 				b.clearTmp()
 			}
@@ -158,12 +245,38 @@ func (b *Builder) Run(context io.Reader) (string, error) {
 		if b.Remove {
 			b.clearTmp()
 		}
+
+		summary.Steps++
+		if b.step.cached {
+			summary.CachedSteps++
+		}
+		b.reportStep(i, nil)
+	}
+	b.step = nil
+
+	// Build-time variables the Dockerfile does not declare are most likely
+	// mistyped, so they fail the build rather than being silently ignored
+	leftoverArgs := []string{}
+	for name := range b.BuildArgs {
+		if _, declared := b.allowedBuildArgs[name]; !declared {
+			leftoverArgs = append(leftoverArgs, name)
+		}
+	}
+	if len(leftoverArgs) > 0 {
+		sort.Strings(leftoverArgs)
+		return "", fmt.Errorf("One or more build-args %v were not consumed, failing build.", leftoverArgs)
 	}
 
 	if b.image == "" {
 		return "", fmt.Errorf("No image was generated. Is your Dockerfile empty?\n")
 	}
 
+	if b.Squash {
+		if err := b.squash(); err != nil {
+			return "", err
+		}
+	}
+
 	fmt.Fprintf(b.OutStream, "Successfully built %s\n", utils.TruncateID(b.image))
 	return b.image, nil
 }
@@ -236,9 +349,14 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 	cmd := ast.Value
 	attrs := ast.Attributes
 	original := ast.Original
+	flags := ast.Flags
 	strs := []string{}
 	msg := fmt.Sprintf("Step %d : %s", stepN, strings.ToUpper(cmd))
 
+	for _, flag := range flags {
+		msg += " " + flag
+	}
+
 	if cmd == "onbuild" {
 		ast = ast.Next.Children[0]
 		strs = append(strs, ast.Value)
@@ -275,6 +393,16 @@ func (b *Builder) dispatch(stepN int, ast *parser.Node) error {
 	msg += " " + strings.Join(msgList, " ")
 	fmt.Fprintln(b.OutStream, msg)
 
+	var err error
+	if b.flags, err = b.parseFlags(cmd, flags); err != nil {
+		return err
+	}
+	b.instruction = evaluatedInstruction(cmd, flags, strList, attrs)
+	b.contextChecksum = ""
+	if b.step != nil && b.step.instruction == "" {
+		b.step.instruction = b.instruction
+	}
+
 	// XXX yes, we skip any cmds that are not valid; the parser should have
 	// picked these out already.
 	if f, ok := evaluateTable[cmd]; ok {
@@ -6,6 +6,7 @@ package builder
 import (
 	"crypto/sha256"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -15,6 +16,7 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
@@ -22,9 +24,11 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/builder/parser"
 	"github.com/docker/docker/daemon"
+	"github.com/docker/docker/daemon/graphdriver"
 	imagepkg "github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/chrootarchive"
+	"github.com/docker/docker/pkg/fileutils"
 	"github.com/docker/docker/pkg/ioutils"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/symlink"
@@ -32,6 +36,7 @@ import (
 	"github.com/docker/docker/pkg/tarsum"
 	"github.com/docker/docker/pkg/urlutil"
 	"github.com/docker/docker/registry"
+	"github.com/docker/docker/runconfig"
 	"github.com/docker/docker/utils"
 )
 
@@ -55,6 +60,52 @@ func (b *Builder) readContext(context io.Reader) error {
 	}
 
 	b.contextPath = tmpdirPath
+	return b.excludeIgnoredFiles()
+}
+
+// excludeIgnoredFiles removes the files which the .dockerignore file of the
+// context excludes from the context and from the sums the cache is calculated
+// from, since clients of the API may send them. The Dockerfile and the
+// .dockerignore file themselves are left to readDockerfile.
+func (b *Builder) excludeIgnoredFiles() error {
+	excludes, err := utils.ReadDockerIgnore(filepath.Join(b.contextPath, ".dockerignore"))
+	if err != nil || len(excludes) == 0 {
+		return err
+	}
+
+	ignored := []string{}
+	for _, fileInfo := range b.context.GetSums() {
+		name := fileInfo.Name()
+		if name == "" || name == ".dockerignore" || name == filepath.Clean(b.dockerfileName) {
+			continue
+		}
+		rm, err := fileutils.Matches(name, excludes)
+		if err != nil {
+			return err
+		}
+		if rm {
+			ignored = append(ignored, name)
+		}
+	}
+
+	// Directories are removed last, and only once they are empty, as an
+	// exception may keep some of their files
+	dirs := []string{}
+	for _, name := range ignored {
+		b.context.(tarsum.BuilderContext).Remove(name)
+		fullPath := filepath.Join(b.contextPath, name)
+		if fi, err := os.Lstat(fullPath); err == nil && fi.IsDir() {
+			dirs = append(dirs, fullPath)
+			continue
+		}
+		if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dirs)))
+	for _, dir := range dirs {
+		os.Remove(dir)
+	}
 	return nil
 }
 
@@ -101,11 +152,15 @@ func (b *Builder) commit(id string, autoCmd []string, comment string) error {
 	if err != nil {
 		return err
 	}
+	if err := b.Daemon.Graph().SetBuildInfo(image, b.instruction, b.contextChecksum); err != nil {
+		return err
+	}
 	b.image = image.ID
 	return nil
 }
 
 type copyInfo struct {
+	root       string // the directory origPath is relative to
 	origPath   string
 	destPath   string
 	hash       string
@@ -113,8 +168,10 @@ type copyInfo struct {
 	tmpDir     string
 }
 
-func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecompression bool, cmdName string) error {
-	if b.context == nil {
+// runContextCommand copies files into the image for ADD and COPY. The files
+// are taken from the context, or from srcRoot when it is not empty.
+func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecompression bool, cmdName string, srcRoot string) error {
+	if b.context == nil && srcRoot == "" {
 		return fmt.Errorf("No context given. Impossible to use %s", cmdName)
 	}
 
@@ -140,7 +197,7 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecomp
 	// do the copy (e.g. hash value if cached).  Don't actually do
 	// the copy until we've looked at all src files
 	for _, orig := range args[0 : len(args)-1] {
-		err := calcCopyInfo(b, cmdName, &copyInfos, orig, dest, allowRemote, allowDecompression)
+		err := calcCopyInfo(b, cmdName, &copyInfos, orig, dest, allowRemote, allowDecompression, srcRoot)
 		if err != nil {
 			return err
 		}
@@ -175,6 +232,8 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecomp
 		origPaths = strings.Join(origs, " ")
 	}
 
+	b.contextChecksum = srcHash
+
 	cmd := b.Config.Cmd
 	b.Config.Cmd = []string{"/bin/sh", "-c", fmt.Sprintf("#(nop) %s %s in %s", cmdName, srcHash, dest)}
 	defer func(cmd []string) { b.Config.Cmd = cmd }(cmd)
@@ -200,7 +259,7 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecomp
 	defer container.Unmount()
 
 	for _, ci := range copyInfos {
-		if err := b.addContext(container, ci.origPath, ci.destPath, ci.decompress); err != nil {
+		if err := b.addContext(container, ci.root, ci.origPath, ci.destPath, ci.decompress); err != nil {
 			return err
 		}
 	}
@@ -211,7 +270,7 @@ func (b *Builder) runContextCommand(args []string, allowRemote bool, allowDecomp
 	return nil
 }
 
-func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath string, destPath string, allowRemote bool, allowDecompression bool) error {
+func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath string, destPath string, allowRemote bool, allowDecompression bool, srcRoot string) error {
 
 	if origPath != "" && origPath[0] == '/' && len(origPath) > 1 {
 		origPath = origPath[1:]
@@ -230,6 +289,10 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 		}
 	}
 
+	if srcRoot != "" {
+		return calcRootfsCopyInfo(cInfos, srcRoot, origPath, destPath)
+	}
+
 	// In the remote/URL case, download it and gen its hashcode
 	if urlutil.IsURL(origPath) {
 		if !allowRemote {
@@ -237,6 +300,7 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 		}
 
 		ci := copyInfo{}
+		ci.root = b.contextPath
 		ci.origPath = origPath
 		ci.hash = origPath // default to this but can change
 		ci.destPath = destPath
@@ -338,7 +402,7 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 				continue
 			}
 
-			calcCopyInfo(b, cmdName, cInfos, fileInfo.Name(), destPath, allowRemote, allowDecompression)
+			calcCopyInfo(b, cmdName, cInfos, fileInfo.Name(), destPath, allowRemote, allowDecompression, srcRoot)
 		}
 		return nil
 	}
@@ -351,6 +415,7 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 	fi, _ := os.Stat(path.Join(b.contextPath, origPath))
 
 	ci := copyInfo{}
+	ci.root = b.contextPath
 	ci.origPath = origPath
 	ci.hash = origPath
 	ci.destPath = destPath
@@ -403,6 +468,57 @@ func calcCopyInfo(b *Builder, cmdName string, cInfos *[]*copyInfo, origPath stri
 	return nil
 }
 
+// calcRootfsCopyInfo is calcCopyInfo for the files of the root filesystem
+// root, in which symlinks are followed without leaving root. The files are
+// hashed as a whole since there is no context tarsum for them.
+func calcRootfsCopyInfo(cInfos *[]*copyInfo, root, origPath, destPath string) error {
+	if ContainsWildcards(origPath) {
+		matches, err := filepath.Glob(filepath.Join(root, origPath))
+		if err != nil {
+			return err
+		}
+		for _, match := range matches {
+			if err := calcRootfsCopyInfo(cInfos, root, strings.TrimPrefix(match, root), destPath); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	fullPath, err := symlink.FollowSymlinkInScope(filepath.Join(root, origPath), root)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(fullPath); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("%s: no such file or directory", origPath)
+		}
+		return err
+	}
+
+	ci := copyInfo{}
+	ci.root = root
+	ci.origPath = strings.TrimPrefix(fullPath, root)
+	ci.destPath = destPath
+	*cInfos = append(*cInfos, &ci)
+
+	r, err := archive.Tar(fullPath, archive.Uncompressed)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tarSum, err := tarsum.NewTarSum(r, true, tarsum.Version0)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(ioutil.Discard, tarSum); err != nil {
+		return err
+	}
+	ci.hash = "rootfs:" + tarSum.Sum(nil)
+
+	return nil
+}
+
 func ContainsWildcards(name string) bool {
 	for i := 0; i < len(name); i++ {
 		ch := name[i]
@@ -446,8 +562,106 @@ func (b *Builder) pullImage(name string) (*imagepkg.Image, error) {
 	return image, nil
 }
 
+// stageImage returns the ID of the last image of the earlier stage from, by
+// name or number, or else of the image from, which is pulled if needed.
+func (b *Builder) stageImage(from string) (string, error) {
+	var stage *buildStage
+	if n, err := strconv.Atoi(from); err == nil {
+		if n < 0 || n >= len(b.stages) {
+			return "", fmt.Errorf("Build stage %d does not exist, there are %d stages before this one", n, len(b.stages))
+		}
+		stage = &b.stages[n]
+	} else {
+		for i := range b.stages {
+			if b.stages[i].name == strings.ToLower(from) {
+				stage = &b.stages[i]
+				break
+			}
+		}
+	}
+	if stage != nil {
+		if stage.image == "" {
+			return "", fmt.Errorf("Build stage %s has no files to copy", from)
+		}
+		return stage.image, nil
+	}
+
+	image, err := b.Daemon.Repositories().LookupImage(from)
+	if err != nil {
+		if !b.Daemon.Graph().IsNotExist(err) {
+			return "", err
+		}
+		if image, err = b.pullImage(from); err != nil {
+			return "", err
+		}
+	}
+	return image.ID, nil
+}
+
+// stageContainer creates and mounts a container of the image stageImage
+// returns for from, out of the root filesystem of which COPY --from copies.
+// The caller unmounts it, it is removed with the other intermediate
+// containers.
+func (b *Builder) stageContainer(from string) (*daemon.Container, error) {
+	image, err := b.stageImage(from)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &runconfig.Config{
+		Image: image,
+		Cmd:   []string{"/bin/sh", "-c", "#(nop) COPY --from=" + from},
+	}
+	container, _, err := b.Daemon.Create(config, nil, "")
+	if err != nil {
+		return nil, err
+	}
+	b.TmpContainers[container.ID] = struct{}{}
+
+	if err := container.Mount(); err != nil {
+		return nil, err
+	}
+	return container, nil
+}
+
+// nextStage ends the current stage of the build when a FROM starts another
+// one. The instructions of the next stage start from a clean state, and the
+// build-time variables declared so far go out of scope.
+func (b *Builder) nextStage() {
+	b.stages = append(b.stages, buildStage{name: b.stageName, image: b.image})
+
+	b.Config = &runconfig.Config{}
+	b.image = ""
+	b.baseImage = ""
+	b.noBaseImage = false
+	b.maintainer = ""
+	b.cmdSet = false
+	for name := range b.allowedBuildArgs {
+		b.allowedBuildArgs[name] = false
+	}
+	b.argDefaults = map[string]string{}
+}
+
+// declareArg declares the build-time variable of the definition
+// name[=default] in the current stage. The default only applies to this
+// stage, and only when the client does not set the variable.
+func (b *Builder) declareArg(definition string) error {
+	parts := strings.SplitN(definition, "=", 2)
+	name := parts[0]
+	if name == "" || strings.ContainsAny(name, " \t") {
+		return fmt.Errorf("ARG requires a variable name, got %q", definition)
+	}
+
+	b.allowedBuildArgs[name] = true
+	if len(parts) == 2 {
+		b.argDefaults[name] = parts[1]
+	}
+	return nil
+}
+
 func (b *Builder) processImageFrom(img *imagepkg.Image) error {
 	b.image = img.ID
+	b.baseImage = img.ID
 
 	if img.Config != nil {
 		b.Config = img.Config
@@ -492,9 +706,60 @@ func (b *Builder) processImageFrom(img *imagepkg.Image) error {
 	return nil
 }
 
+// squash replaces the layers the last stage of the build added on top of its
+// base image with a single layer holding all their changes. The squashed
+// image keeps the config of the last image, and the instructions of the
+// layers it replaces in its comment. The replaced images are left in place
+// for the cache.
+func (b *Builder) squash() error {
+	if b.image == b.baseImage {
+		return nil
+	}
+
+	graph := b.Daemon.Graph()
+	last, err := graph.Get(b.image)
+	if err != nil {
+		return err
+	}
+
+	var (
+		instructions []string
+		layers       int
+	)
+	for img := last; img.ID != b.baseImage; layers++ {
+		if img.BuildInstruction != "" {
+			instructions = append([]string{img.BuildInstruction}, instructions...)
+		}
+		if img.Parent == "" {
+			break
+		}
+		if img, err = graph.Get(img.Parent); err != nil {
+			return err
+		}
+	}
+	fmt.Fprintf(b.OutStream, "Squashing %d layers\n", layers)
+
+	// Drivers like aufs can only diff a layer against its direct parent, the
+	// naive diff compares the file systems of any two layers instead
+	diff, err := graphdriver.NaiveDiffDriver(b.Daemon.GraphDriver()).Diff(b.image, b.baseImage)
+	if err != nil {
+		return err
+	}
+	defer diff.Close()
+
+	image, err := graph.Create(diff, last.Container, b.baseImage, strings.Join(instructions, "\n"), last.Author, &last.ContainerConfig, last.Config)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b.OutStream, " ---> %s\n", utils.TruncateID(image.ID))
+	b.image = image.ID
+	return nil
+}
+
 // probeCache checks to see if image-caching is enabled (`b.UtilizeCache`)
 // and if so attempts to look up the current `b.image` and `b.Config` pair
-// in the current server `b.Daemon`. If an image is found, probeCache returns
+// in the current server `b.Daemon`, and then among the images of the
+// `b.CacheFrom` histories. If an image is found, probeCache returns
 // `(true, nil)`. If no image is found, it returns `(false, nil)`. If there
 // is any error, it returns `(false, err)`.
 func (b *Builder) probeCache() (bool, error) {
@@ -504,7 +769,12 @@ func (b *Builder) probeCache() (bool, error) {
 		} else if cache != nil {
 			fmt.Fprintf(b.OutStream, " ---> Using cache\n")
 			log.Debugf("[BUILDER] Use cached version")
-			b.image = cache.ID
+			b.useCache(cache.ID)
+			return true, nil
+		} else if cache := b.cacheFromImage(); cache != nil {
+			fmt.Fprintf(b.OutStream, " ---> Using cache from %s\n", utils.TruncateID(cache.ID))
+			log.Debugf("[BUILDER] Use cached version from the --cache-from images")
+			b.useCache(cache.ID)
 			return true, nil
 		} else {
 			log.Debugf("[BUILDER] Cache miss")
@@ -513,6 +783,54 @@ func (b *Builder) probeCache() (bool, error) {
 	return false, nil
 }
 
+// useCache makes the cached image id the result of the current instruction.
+func (b *Builder) useCache(id string) {
+	b.image = id
+	if b.step != nil {
+		b.step.cached = true
+	}
+}
+
+// loadCacheFrom pulls the images of `b.CacheFrom` which do not exist locally,
+// and indexes the images of their histories which were built by a
+// Dockerfile by their parent.
+func (b *Builder) loadCacheFrom() error {
+	b.cacheFrom = map[string][]*imagepkg.Image{}
+	for _, name := range b.CacheFrom {
+		img, err := b.Daemon.Repositories().LookupImage(name)
+		if err != nil {
+			if !b.Daemon.Graph().IsNotExist(err) {
+				return err
+			}
+			if img, err = b.pullImage(name); err != nil {
+				return err
+			}
+		}
+		if err := img.WalkHistory(func(img *imagepkg.Image) error {
+			if img.BuildInstruction != "" {
+				b.cacheFrom[img.Parent] = append(b.cacheFrom[img.Parent], img)
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheFromImage returns the image of the `b.CacheFrom` histories built on
+// `b.image` by the same instruction, from the same context files and with the
+// same command as the current one, if there is one.
+func (b *Builder) cacheFromImage() *imagepkg.Image {
+	cmd := strings.Join(b.Config.Cmd, " ")
+	for _, img := range b.cacheFrom[b.image] {
+		if img.BuildInstruction == b.instruction && img.ContextChecksum == b.contextChecksum && strings.Join(img.ContainerConfig.Cmd, " ") == cmd {
+			return img
+		}
+	}
+	return nil
+}
+
 func (b *Builder) create() (*daemon.Container, error) {
 	if b.image == "" && !b.noBaseImage {
 		return nil, fmt.Errorf("Please provide a source image with `from` prior to run")
@@ -532,6 +850,9 @@ func (b *Builder) create() (*daemon.Container, error) {
 
 	b.TmpContainers[c.ID] = struct{}{}
 	fmt.Fprintf(b.OutStream, " ---> Running in %s\n", utils.TruncateID(c.ID))
+	if b.step != nil {
+		b.step.container = c.ID
+	}
 
 	if len(config.Cmd) > 0 {
 		// override the entry point that may have been picked up from the base image
@@ -595,11 +916,11 @@ func (b *Builder) checkPathForAddition(orig string) error {
 	return nil
 }
 
-func (b *Builder) addContext(container *daemon.Container, orig, dest string, decompress bool) error {
+func (b *Builder) addContext(container *daemon.Container, root, orig, dest string, decompress bool) error {
 	var (
 		err        error
 		destExists = true
-		origPath   = path.Join(b.contextPath, orig)
+		origPath   = path.Join(root, orig)
 		destPath   = path.Join(container.RootfsPath(), dest)
 	)
 
@@ -720,3 +1041,52 @@ func (b *Builder) clearTmp() {
 		fmt.Fprintf(b.OutStream, "Removing intermediate container %s\n", utils.TruncateID(c))
 	}
 }
+
+// reportStep writes the structured message about step stepN, which is done,
+// or failed with err, when the client asked for structured output.
+func (b *Builder) reportStep(stepN int, err error) {
+	if !b.Structured || !b.StreamFormatter.Json() {
+		return
+	}
+	duration := time.Since(b.step.start)
+	step := &utils.JSONBuildStep{
+		Step:        stepN,
+		Instruction: b.step.instruction,
+		Cached:      b.step.cached,
+		ContainerID: b.step.container,
+		Duration:    duration.Seconds(),
+	}
+	status := fmt.Sprintf("Step %d done in %s", stepN, duration/time.Millisecond*time.Millisecond)
+	if err != nil {
+		step.Error = err.Error()
+		status = fmt.Sprintf("Step %d failed after %s", stepN, duration/time.Millisecond*time.Millisecond)
+	} else {
+		step.ImageID = b.image
+	}
+	b.writeMessage(&utils.JSONMessage{Status: status, BuildStep: step})
+}
+
+// reportSummary writes the structured message which sums up the build, when
+// the client asked for structured output.
+func (b *Builder) reportSummary(summary *utils.JSONBuildSummary) {
+	if !b.Structured || !b.StreamFormatter.Json() {
+		return
+	}
+	status := fmt.Sprintf("Built %s in %d steps, %d from the cache", utils.TruncateID(summary.ImageID), summary.Steps, summary.CachedSteps)
+	if summary.Error != "" {
+		status = fmt.Sprintf("Build failed after %d steps, %d from the cache", summary.Steps, summary.CachedSteps)
+	}
+	b.writeMessage(&utils.JSONMessage{
+		Status:       status,
+		BuildSummary: summary,
+	})
+}
+
+func (b *Builder) writeMessage(jm *utils.JSONMessage) {
+	buf, err := json.Marshal(jm)
+	if err != nil {
+		log.Debugf("[BUILDER] failed to marshal a structured message: %s", err)
+		return
+	}
+	b.OutOld.Write(append(buf, '\r', '\n'))
+}
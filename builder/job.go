@@ -4,7 +4,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"os/exec"
 
 	"github.com/docker/docker/api"
 	"github.com/docker/docker/daemon"
@@ -24,6 +23,7 @@ type BuilderJob struct {
 
 func (b *BuilderJob) Install() {
 	b.Engine.Register("build", b.CmdBuild)
+	b.Engine.Register("build_validate", b.CmdValidate)
 }
 
 func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
@@ -39,6 +39,10 @@ func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
 		rm             = job.GetenvBool("rm")
 		forceRm        = job.GetenvBool("forcerm")
 		pull           = job.GetenvBool("pull")
+		squash         = job.GetenvBool("squash")
+		structured     = job.GetenvBool("structured")
+		buildArgs      = map[string]string{}
+		cacheFrom      = []string{}
 		authConfig     = &registry.AuthConfig{}
 		configFile     = &registry.ConfigFile{}
 		tag            string
@@ -47,6 +51,12 @@ func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
 
 	job.GetenvJson("authConfig", authConfig)
 	job.GetenvJson("configFile", configFile)
+	if err := job.GetenvJson("buildargs", &buildArgs); err != nil {
+		return job.Errorf("Invalid build args: %s", err)
+	}
+	if err := job.GetenvJson("cachefrom", &cacheFrom); err != nil {
+		return job.Errorf("Invalid cache-from images: %s", err)
+	}
 
 	repoName, tag = parsers.ParseRepositoryTag(repoName)
 	if repoName != "" {
@@ -67,20 +77,13 @@ func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
 	if remoteURL == "" {
 		context = ioutil.NopCloser(job.Stdin)
 	} else if urlutil.IsGitURL(remoteURL) {
-		if !urlutil.IsGitTransport(remoteURL) {
-			remoteURL = "https://" + remoteURL
-		}
-		root, err := ioutil.TempDir("", "docker-build-git")
+		root, contextDir, err := utils.GitClone(remoteURL)
 		if err != nil {
 			return job.Error(err)
 		}
 		defer os.RemoveAll(root)
 
-		if output, err := exec.Command("git", "clone", "--recursive", remoteURL, root).CombinedOutput(); err != nil {
-			return job.Errorf("Error trying to use git: %s (%s)", err, output)
-		}
-
-		c, err := archive.Tar(root, archive.Uncompressed)
+		c, err := archive.Tar(contextDir, archive.Uncompressed)
 		if err != nil {
 			return job.Error(err)
 		}
@@ -121,6 +124,10 @@ func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
 		Remove:          rm,
 		ForceRemove:     forceRm,
 		Pull:            pull,
+		BuildArgs:       buildArgs,
+		CacheFrom:       cacheFrom,
+		Squash:          squash,
+		Structured:      structured,
 		OutOld:          job.Stdout,
 		StreamFormatter: sf,
 		AuthConfig:      authConfig,
@@ -138,3 +145,29 @@ func (b *BuilderJob) CmdBuild(job *engine.Job) engine.Status {
 	}
 	return engine.StatusOK
 }
+
+// CmdValidate checks the Dockerfile read from the standard input of the job
+// with Validate, and writes the problems found as a list.
+func (b *BuilderJob) CmdValidate(job *engine.Job) engine.Status {
+	if len(job.Args) != 0 {
+		return job.Errorf("Usage: %s\n", job.Name)
+	}
+
+	problems, err := Validate(job.Stdin)
+	if err != nil {
+		return job.Error(err)
+	}
+
+	outs := engine.NewTable("", 0)
+	for _, problem := range problems {
+		out := &engine.Env{}
+		if err := out.Import(problem); err != nil {
+			return job.Error(err)
+		}
+		outs.Add(out)
+	}
+	if _, err := outs.WriteListTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
@@ -40,10 +40,10 @@ func parseSubCommand(rest string) (*Node, map[string]bool, error) {
 
 // parse environment like statements. Note that this does *not* handle
 // variable interpolation, which will be handled in the evaluator.
-func parseEnv(rest string) (*Node, map[string]bool, error) {
+func parseNameVal(rest string, key string) (*Node, map[string]bool, error) {
 	// This is kind of tricky because we need to support the old
-	// variant:   ENV name value
-	// as well as the new one:    ENV name=value ...
+	// variant:   KEY name value
+	// as well as the new one:    KEY name=value ...
 	// The trigger to know which one is being used will be whether we hit
 	// a space or = first.  space ==> old, "=" ==> new
 
@@ -133,10 +133,10 @@ func parseEnv(rest string) (*Node, map[string]bool, error) {
 	}
 
 	if len(words) == 0 {
-		return nil, nil, fmt.Errorf("ENV must have some arguments")
+		return nil, nil, fmt.Errorf("%s must have some arguments", key)
 	}
 
-	// Old format (ENV name value)
+	// Old format (KEY name value)
 	var rootnode *Node
 
 	if !strings.Contains(words[0], "=") {
@@ -145,7 +145,7 @@ func parseEnv(rest string) (*Node, map[string]bool, error) {
 		strs := TOKEN_WHITESPACE.Split(rest, 2)
 
 		if len(strs) < 2 {
-			return nil, nil, fmt.Errorf("ENV must have two arguments")
+			return nil, nil, fmt.Errorf("%s must have two arguments", key)
 		}
 
 		node.Value = strs[0]
@@ -178,6 +178,14 @@ func parseEnv(rest string) (*Node, map[string]bool, error) {
 	return rootnode, nil, nil
 }
 
+func parseEnv(rest string) (*Node, map[string]bool, error) {
+	return parseNameVal(rest, "ENV")
+}
+
+func parseLabel(rest string) (*Node, map[string]bool, error) {
+	return parseNameVal(rest, "LABEL")
+}
+
 // parses a whitespace-delimited set of arguments. The result is effectively a
 // linked list of string arguments.
 func parseStringsWhitespaceDelimited(rest string) (*Node, map[string]bool, error) {
@@ -268,3 +276,24 @@ func parseMaybeJSONToList(rest string) (*Node, map[string]bool, error) {
 
 	return parseStringsWhitespaceDelimited(rest)
 }
+
+// parseHealthConfig parses the arguments of HEALTHCHECK, which start with the
+// type of the check. "NONE" takes no argument, while the command of "CMD" may
+// be a JSON array like for RUN.
+//
+// HEALTHCHECK CMD curl -f http://localhost/ -> (healthcheck "CMD" "curl -f http://localhost/")
+//
+func parseHealthConfig(rest string) (*Node, map[string]bool, error) {
+	parts := TOKEN_WHITESPACE.Split(strings.TrimSpace(rest), 2)
+	node := &Node{Value: strings.ToUpper(parts[0])}
+	if len(parts) < 2 {
+		return node, nil, nil
+	}
+
+	cmd, attrs, err := parseMaybeJSON(parts[1])
+	if err != nil {
+		return nil, nil, err
+	}
+	node.Next = cmd
+	return node, attrs, nil
+}
@@ -28,6 +28,20 @@ type Node struct {
 	Children   []*Node         // the children of this sexp
 	Attributes map[string]bool // special attributes for this node
 	Original   string          // original line used before parsing
+	Flags      []string        // leading --name=value flags of the instruction
+	StartLine  int             // the line of the Dockerfile the instruction starts on
+	EndLine    int             // the line it ends on, after any continuation lines
+}
+
+// ParseError is an error in the instruction of a Dockerfile which starts on
+// Line.
+type ParseError struct {
+	Line int
+	Err  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Dockerfile parse error line %d: %s", e.Line, e.Err)
 }
 
 var (
@@ -45,20 +59,24 @@ func init() {
 	// functions. Errors are propogated up by Parse() and the resulting AST can
 	// be incorporated directly into the existing AST as a next.
 	dispatch = map[string]func(string) (*Node, map[string]bool, error){
-		"user":       parseString,
-		"onbuild":    parseSubCommand,
-		"workdir":    parseString,
-		"env":        parseEnv,
-		"maintainer": parseString,
-		"from":       parseString,
-		"add":        parseMaybeJSONToList,
-		"copy":       parseMaybeJSONToList,
-		"run":        parseMaybeJSON,
-		"cmd":        parseMaybeJSON,
-		"entrypoint": parseMaybeJSON,
-		"expose":     parseStringsWhitespaceDelimited,
-		"volume":     parseMaybeJSONToList,
-		"insert":     parseIgnore,
+		"user":        parseString,
+		"onbuild":     parseSubCommand,
+		"workdir":     parseString,
+		"env":         parseEnv,
+		"maintainer":  parseString,
+		"from":        parseStringsWhitespaceDelimited,
+		"add":         parseMaybeJSONToList,
+		"copy":        parseMaybeJSONToList,
+		"run":         parseMaybeJSON,
+		"cmd":         parseMaybeJSON,
+		"entrypoint":  parseMaybeJSON,
+		"expose":      parseStringsWhitespaceDelimited,
+		"volume":      parseMaybeJSONToList,
+		"insert":      parseIgnore,
+		"arg":         parseString,
+		"label":       parseLabel,
+		"stopsignal":  parseString,
+		"healthcheck": parseHealthConfig,
 	}
 }
 
@@ -85,6 +103,8 @@ func parseLine(line string) (string, *Node, error) {
 	node := &Node{}
 	node.Value = cmd
 
+	args, flags := extractBuilderFlags(args)
+
 	sexp, attrs, err := fullDispatch(cmd, args)
 	if err != nil {
 		return "", nil, err
@@ -93,6 +113,7 @@ func parseLine(line string) (string, *Node, error) {
 	node.Next = sexp
 	node.Attributes = attrs
 	node.Original = line
+	node.Flags = flags
 
 	return "", node, nil
 }
@@ -102,16 +123,20 @@ func parseLine(line string) (string, *Node, error) {
 func Parse(rwc io.Reader) (*Node, error) {
 	root := &Node{}
 	scanner := bufio.NewScanner(rwc)
+	currentLine := 0
 
 	for scanner.Scan() {
+		currentLine++
+		startLine := currentLine
 		scannedLine := strings.TrimLeftFunc(scanner.Text(), unicode.IsSpace)
 		line, child, err := parseLine(scannedLine)
 		if err != nil {
-			return nil, err
+			return nil, &ParseError{Line: startLine, Err: err}
 		}
 
 		if line != "" && child == nil {
 			for scanner.Scan() {
+				currentLine++
 				newline := scanner.Text()
 
 				if stripComments(strings.TrimSpace(newline)) == "" {
@@ -120,7 +145,7 @@ func Parse(rwc io.Reader) (*Node, error) {
 
 				line, child, err = parseLine(line + newline)
 				if err != nil {
-					return nil, err
+					return nil, &ParseError{Line: startLine, Err: err}
 				}
 
 				if child != nil {
@@ -130,12 +155,14 @@ func Parse(rwc io.Reader) (*Node, error) {
 			if child == nil && line != "" {
 				line, child, err = parseLine(line)
 				if err != nil {
-					return nil, err
+					return nil, &ParseError{Line: startLine, Err: err}
 				}
 			}
 		}
 
 		if child != nil {
+			child.StartLine = startLine
+			child.EndLine = currentLine
 			root.Children = append(root.Children, child)
 		}
 	}
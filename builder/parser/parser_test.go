@@ -5,6 +5,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -73,3 +74,32 @@ func TestTestData(t *testing.T) {
 		}
 	}
 }
+
+func TestParseLineNumbers(t *testing.T) {
+	dockerfile := `# comment
+FROM busybox
+
+RUN echo hello \
+	&& echo world
+ENV A=b
+`
+	ast, err := Parse(strings.NewReader(dockerfile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := [][2]int{{2, 2}, {4, 5}, {6, 6}}
+	if len(ast.Children) != len(expected) {
+		t.Fatalf("Expected %d instructions, got %d", len(expected), len(ast.Children))
+	}
+	for i, child := range ast.Children {
+		if child.StartLine != expected[i][0] || child.EndLine != expected[i][1] {
+			t.Fatalf("Expected %s to span lines %v, got [%d %d]", child.Value, expected[i], child.StartLine, child.EndLine)
+		}
+	}
+
+	_, err = Parse(strings.NewReader("FROM busybox\nRUN echo hello\nENV\n"))
+	parseErr, ok := err.(*ParseError)
+	if !ok || parseErr.Line != 3 {
+		t.Fatalf("Expected a parse error on line 3, got %v", err)
+	}
+}
@@ -12,6 +12,10 @@ func (node *Node) Dump() string {
 	str := ""
 	str += node.Value
 
+	if len(node.Flags) > 0 {
+		str += fmt.Sprintf(" %q", node.Flags)
+	}
+
 	for _, n := range node.Children {
 		str += "(" + n.Dump() + ")\n"
 	}
@@ -62,6 +66,26 @@ func splitCommand(line string) (string, string, error) {
 	return cmd, strings.TrimSpace(cmdline[1]), nil
 }
 
+// extractBuilderFlags splits the leading --name=value words of the arguments
+// of an instruction, as in COPY --from=builder, off the rest of them. A lone
+// "--" ends the flags so that arguments can start with "--".
+func extractBuilderFlags(args string) (string, []string) {
+	flags := []string{}
+	for strings.HasPrefix(args, "--") {
+		words := TOKEN_WHITESPACE.Split(args, 2)
+		rest := ""
+		if len(words) == 2 {
+			rest = words[1]
+		}
+		if words[0] == "--" {
+			return rest, flags
+		}
+		flags = append(flags, words[0])
+		args = rest
+	}
+	return args, flags
+}
+
 // covers comments and empty lines. Lines should be trimmed before passing to
 // this function.
 func stripComments(line string) string {
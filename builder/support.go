@@ -1,7 +1,10 @@
 package builder
 
 import (
+	"encoding/json"
+	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 )
 
@@ -16,6 +19,7 @@ var (
 
 // handle environment replacement. Used in dispatcher.
 func (b *Builder) replaceEnv(str string) string {
+	envs := append(append([]string{}, b.Config.Env...), b.buildArgsEnv()...)
 	for _, match := range tokenEnvInterpolation.FindAllString(str, -1) {
 		idx := strings.Index(match, "\\$")
 		if idx != -1 {
@@ -33,7 +37,7 @@ func (b *Builder) replaceEnv(str string) string {
 		match = match[strings.Index(match, "$"):]
 		matchKey := strings.Trim(match, "${}")
 
-		for _, keyval := range b.Config.Env {
+		for _, keyval := range envs {
 			tmp := strings.SplitN(keyval, "=", 2)
 			if tmp[0] == matchKey {
 				str = strings.Replace(str, match, tmp[1], -1)
@@ -45,6 +49,66 @@ func (b *Builder) replaceEnv(str string) string {
 	return str
 }
 
+// buildArgsEnv returns the build-time variables declared so far as sorted
+// KEY=value pairs, with the values set by the client or else the defaults of
+// the current stage. The environment of the image takes precedence over them.
+func (b *Builder) buildArgsEnv() []string {
+	configEnv := map[string]struct{}{}
+	for _, keyval := range b.Config.Env {
+		configEnv[strings.SplitN(keyval, "=", 2)[0]] = struct{}{}
+	}
+
+	env := []string{}
+	for name, allowed := range b.allowedBuildArgs {
+		if _, set := configEnv[name]; set || !allowed {
+			continue
+		}
+		value, set := b.BuildArgs[name]
+		if !set {
+			if value, set = b.argDefaults[name]; !set {
+				continue
+			}
+		}
+		env = append(env, name+"="+value)
+	}
+	sort.Strings(env)
+	return env
+}
+
+// parseFlags returns the values of the --name=value flags of the instruction
+// cmd by name. Flags the instruction does not accept are an error.
+func (b *Builder) parseFlags(cmd string, flags []string) (map[string]string, error) {
+	values := map[string]string{}
+	for _, flag := range flags {
+		parts := strings.SplitN(strings.TrimPrefix(flag, "--"), "=", 2)
+		if _, ok := instructionFlags[cmd][parts[0]]; !ok {
+			return nil, fmt.Errorf("Unknown flag for %s: --%s", strings.ToUpper(cmd), parts[0])
+		}
+		if len(parts) != 2 || parts[1] == "" {
+			return nil, fmt.Errorf("Missing a value on flag: --%s", parts[0])
+		}
+		value := parts[1]
+		if _, ok := replaceEnvAllowed[cmd]; ok {
+			value = b.replaceEnv(value)
+		}
+		values[parts[0]] = value
+	}
+	return values, nil
+}
+
+// evaluatedInstruction returns the instruction cmd as it is run, with its
+// arguments after environment replacement, which is recorded in the images
+// the instruction commits.
+func evaluatedInstruction(cmd string, flags, args []string, attributes map[string]bool) string {
+	instruction := append([]string{strings.ToUpper(cmd)}, flags...)
+	if attributes != nil && attributes["json"] {
+		if buf, err := json.Marshal(args); err == nil {
+			return strings.Join(append(instruction, string(buf)), " ")
+		}
+	}
+	return strings.Join(append(instruction, args...), " ")
+}
+
 func handleJsonArgs(args []string, attributes map[string]bool) []string {
 	if len(args) == 0 {
 		return []string{}
@@ -0,0 +1,47 @@
+package builder
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/runconfig"
+)
+
+func TestBuildArgsStages(t *testing.T) {
+	b := &Builder{
+		BuildArgs:        map[string]string{"FOO": "foo"},
+		Config:           &runconfig.Config{},
+		allowedBuildArgs: map[string]bool{},
+		argDefaults:      map[string]string{},
+	}
+
+	for _, definition := range []string{"FOO=default", "BAR=default"} {
+		if err := b.declareArg(definition); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if env, expected := b.buildArgsEnv(), []string{"BAR=default", "FOO=foo"}; !reflect.DeepEqual(env, expected) {
+		t.Fatalf("Expected %v in the first stage, got %v", expected, env)
+	}
+
+	// The defaults of the first stage do not apply to the next one
+	b.nextStage()
+	if env := b.buildArgsEnv(); len(env) != 0 {
+		t.Fatalf("Expected no build-time variables before they are declared again, got %v", env)
+	}
+	for _, definition := range []string{"FOO", "BAR"} {
+		if err := b.declareArg(definition); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if env, expected := b.buildArgsEnv(), []string{"FOO=foo"}; !reflect.DeepEqual(env, expected) {
+		t.Fatalf("Expected %v in the second stage, got %v", expected, env)
+	}
+	if _, set := b.BuildArgs["BAR"]; set {
+		t.Fatal("Expected the default of BAR not to be stored with the build-args of the client")
+	}
+
+	if err := b.declareArg("=value"); err == nil {
+		t.Fatal("Expected an ARG without a name to be refused")
+	}
+}
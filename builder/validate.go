@@ -0,0 +1,180 @@
+package builder
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/builder/parser"
+	"github.com/docker/docker/pkg/urlutil"
+	"github.com/docker/docker/runconfig"
+)
+
+const (
+	// The severity of the problems Validate finds. Errors fail the build, or
+	// make it do something else than what was most likely meant, warnings
+	// point out better ways of writing the Dockerfile.
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Instructions which take a JSON array or, failing to parse one, a plain
+// string, such that a broken JSON array is silently used as a string.
+var maybeJSONInstructions = map[string]struct{}{
+	"run":        {},
+	"cmd":        {},
+	"entrypoint": {},
+	"add":        {},
+	"copy":       {},
+	"volume":     {},
+}
+
+// File extensions of the archives ADD extracts.
+var archiveExtensions = []string{".tar", ".tgz", ".tbz2", ".txz", ".gz", ".bz2", ".xz"}
+
+// Problem is an issue Validate finds in the instruction of a Dockerfile
+// which starts on Line.
+type Problem struct {
+	Line        int
+	Instruction string
+	Severity    string
+	Message     string
+}
+
+// Validate parses the Dockerfile read from r and checks it statically,
+// without running any of its instructions, so it does not need a build
+// context. It returns the problems found, along with their line numbers.
+//
+// Errors reading the Dockerfile are returned as such, while a Dockerfile
+// which fails to parse is a problem like any other.
+func Validate(r io.Reader) ([]*Problem, error) {
+	ast, err := parser.Parse(r)
+	if err != nil {
+		if parseErr, ok := err.(*parser.ParseError); ok {
+			return []*Problem{{
+				Line:     parseErr.Line,
+				Severity: SeverityError,
+				Message:  parseErr.Err.Error(),
+			}}, nil
+		}
+		return nil, err
+	}
+
+	if len(ast.Children) == 0 {
+		return []*Problem{{Severity: SeverityError, Message: ErrDockerfileEmpty.Error()}}, nil
+	}
+
+	problems := []*Problem{}
+	for i, node := range ast.Children {
+		problems = append(problems, validateInstruction(node, i == 0)...)
+	}
+	return problems, nil
+}
+
+// validateInstruction returns the problems of the instruction node, the
+// first of the Dockerfile if first is true.
+func validateInstruction(node *parser.Node, first bool) []*Problem {
+	var (
+		cmd         = node.Value
+		line        = node.StartLine
+		instruction = strings.ToUpper(cmd)
+		problems    = []*Problem{}
+	)
+	report := func(severity, format string, args ...interface{}) {
+		problems = append(problems, &Problem{
+			Line:        line,
+			Instruction: instruction,
+			Severity:    severity,
+			Message:     fmt.Sprintf(format, args...),
+		})
+	}
+
+	if first && cmd != "from" {
+		report(SeverityError, "The first instruction must be FROM, to set the base image of the build")
+	}
+
+	args := []string{}
+	flags := node.Flags
+	attrs := node.Attributes
+	if cmd == "onbuild" && node.Next != nil && len(node.Next.Children) > 0 {
+		trigger := node.Next.Children[0]
+		switch trigger.Value {
+		case "onbuild":
+			report(SeverityError, "Chaining ONBUILD via `ONBUILD ONBUILD` isn't allowed")
+			return problems
+		case "maintainer", "from":
+			report(SeverityError, "%s isn't allowed as an ONBUILD trigger", strings.ToUpper(trigger.Value))
+			return problems
+		}
+		cmd = trigger.Value
+		flags = trigger.Flags
+		attrs = trigger.Attributes
+		node = trigger
+	}
+	for n := node.Next; n != nil; n = n.Next {
+		args = append(args, n.Value)
+	}
+
+	if _, ok := evaluateTable[cmd]; !ok {
+		report(SeverityError, "Unknown instruction: %s", strings.ToUpper(cmd))
+		return problems
+	}
+
+	if _, err := (&Builder{Config: &runconfig.Config{}}).parseFlags(cmd, flags); err != nil {
+		report(SeverityError, "%s", err)
+	}
+
+	if _, ok := maybeJSONInstructions[cmd]; ok && !attrs["json"] && len(args) > 0 && strings.HasPrefix(args[0], "[") {
+		report(SeverityError, "Invalid JSON array, which would be used as a plain string: %s", strings.Join(args, " "))
+		return problems
+	}
+
+	switch cmd {
+	case "from":
+		if len(args) != 1 && (len(args) != 3 || !strings.EqualFold(args[1], "as")) {
+			report(SeverityError, "FROM requires either one argument, or three: FROM <image> AS <name>")
+		}
+	case "add", "copy":
+		if len(args) < 2 {
+			report(SeverityError, "%s requires at least two arguments", strings.ToUpper(cmd))
+			break
+		}
+		validateSources(cmd, args[:len(args)-1], report)
+	}
+	return problems
+}
+
+// validateSources checks the sources of an ADD or COPY instruction: COPY
+// cannot download URLs, and ADD is only needed for URLs and archives.
+func validateSources(cmd string, srcs []string, report func(severity, format string, args ...interface{})) {
+	if cmd == "copy" {
+		for _, src := range srcs {
+			if urlutil.IsURL(src) {
+				report(SeverityError, "COPY cannot download %s, use ADD for URLs", src)
+			}
+		}
+		return
+	}
+
+	for _, src := range srcs {
+		// Remote files, archives, and wildcards which may match archives
+		// all need ADD
+		if urlutil.IsURL(src) || strings.ContainsAny(src, "*?[") || isArchiveName(src) {
+			return
+		}
+	}
+	report(SeverityWarning, "Use COPY to copy local files, ADD also extracts archives and downloads URLs")
+}
+
+// isArchiveName returns true if the file name has the extension of an
+// archive ADD extracts.
+func isArchiveName(name string) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+	for _, archiveExt := range archiveExtensions {
+		if ext == archiveExt {
+			return true
+		}
+	}
+	return false
+}
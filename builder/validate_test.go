@@ -0,0 +1,121 @@
+package builder
+
+import (
+	"strings"
+	"testing"
+)
+
+// expectedProblem matches a Problem whose Message contains message.
+type expectedProblem struct {
+	line        int
+	instruction string
+	severity    string
+	message     string
+}
+
+var validateTests = []struct {
+	name       string
+	dockerfile string
+	expected   []expectedProblem
+}{
+	{
+		name:       "valid",
+		dockerfile: "FROM busybox\nRUN [\"echo\", \"hello\"]\nCOPY . /app\nADD app.tar.gz /app\nADD http://example.com/app /app\n",
+	},
+	{
+		name:       "empty",
+		dockerfile: "# nothing but a comment\n",
+		expected:   []expectedProblem{{0, "", SeverityError, "empty"}},
+	},
+	{
+		name:       "missing FROM",
+		dockerfile: "RUN echo hello\n",
+		expected:   []expectedProblem{{1, "RUN", SeverityError, "The first instruction must be FROM"}},
+	},
+	{
+		name:       "FROM arguments",
+		dockerfile: "FROM busybox latest\n",
+		expected:   []expectedProblem{{1, "FROM", SeverityError, "FROM requires either one argument"}},
+	},
+	{
+		name:       "FROM AS",
+		dockerfile: "FROM busybox AS build\n",
+	},
+	{
+		name:       "unknown instruction",
+		dockerfile: "FROM busybox\nRUNN echo hello\n",
+		expected:   []expectedProblem{{2, "RUNN", SeverityError, "Unknown instruction: RUNN"}},
+	},
+	{
+		name:       "parse error",
+		dockerfile: "FROM busybox\nHEALTHCHECK\n",
+		expected:   []expectedProblem{{2, "", SeverityError, ""}},
+	},
+	{
+		name:       "broken JSON",
+		dockerfile: "FROM busybox\nCMD [\"echo\", 'hello']\n",
+		expected:   []expectedProblem{{2, "CMD", SeverityError, "Invalid JSON array"}},
+	},
+	{
+		name:       "broken JSON on continued lines",
+		dockerfile: "FROM busybox\nRUN echo hello\nENTRYPOINT [\"echo\", \\\n\t\"hello\",]\n",
+		expected:   []expectedProblem{{3, "ENTRYPOINT", SeverityError, "Invalid JSON array"}},
+	},
+	{
+		name:       "COPY of a URL",
+		dockerfile: "FROM busybox\nCOPY http://example.com/app /app\n",
+		expected:   []expectedProblem{{2, "COPY", SeverityError, "COPY cannot download http://example.com/app"}},
+	},
+	{
+		name:       "ADD of a local file",
+		dockerfile: "FROM busybox\nADD app.go /app/\nADD *.tar /app/\n",
+		expected:   []expectedProblem{{2, "ADD", SeverityWarning, "Use COPY"}},
+	},
+	{
+		name:       "ADD arguments",
+		dockerfile: "FROM busybox\nADD app.go\n",
+		expected:   []expectedProblem{{2, "ADD", SeverityError, "ADD requires at least two arguments"}},
+	},
+	{
+		name:       "unknown flag",
+		dockerfile: "FROM busybox\nCOPY --from=build /app /app\nCOPY --chown=root /app /app\n",
+		expected:   []expectedProblem{{3, "COPY", SeverityError, "Unknown flag for COPY: --chown"}},
+	},
+	{
+		name:       "ONBUILD trigger",
+		dockerfile: "FROM busybox\nONBUILD RUN echo hello\nONBUILD COPY http://example.com/app /app\n",
+		expected:   []expectedProblem{{3, "ONBUILD", SeverityError, "COPY cannot download"}},
+	},
+	{
+		name:       "ONBUILD forbidden triggers",
+		dockerfile: "FROM busybox\nONBUILD ONBUILD RUN echo hello\nONBUILD FROM busybox\nONBUILD MAINTAINER me\n",
+		expected: []expectedProblem{
+			{2, "ONBUILD", SeverityError, "Chaining ONBUILD"},
+			{3, "ONBUILD", SeverityError, "FROM isn't allowed as an ONBUILD trigger"},
+			{4, "ONBUILD", SeverityError, "MAINTAINER isn't allowed as an ONBUILD trigger"},
+		},
+	},
+	{
+		name:       "ONBUILD unknown trigger",
+		dockerfile: "FROM busybox\nONBUILD RUNN echo hello\n",
+		expected:   []expectedProblem{{2, "ONBUILD", SeverityError, "Unknown instruction: RUNN"}},
+	},
+}
+
+func TestValidate(t *testing.T) {
+	for _, test := range validateTests {
+		problems, err := Validate(strings.NewReader(test.dockerfile))
+		if err != nil {
+			t.Fatalf("%s: %s", test.name, err)
+		}
+		if len(problems) != len(test.expected) {
+			t.Fatalf("%s: expected %d problems, got %d: %v", test.name, len(test.expected), len(problems), problems)
+		}
+		for i, expected := range test.expected {
+			problem := problems[i]
+			if problem.Line != expected.line || problem.Instruction != expected.instruction || problem.Severity != expected.severity || !strings.Contains(problem.Message, expected.message) {
+				t.Fatalf("%s: expected %+v, got %+v", test.name, expected, *problem)
+			}
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"net"
 
 	"github.com/docker/docker/daemon/networkdriver"
+	"github.com/docker/docker/graph"
 	"github.com/docker/docker/opts"
 	flag "github.com/docker/docker/pkg/mflag"
 )
@@ -42,6 +43,9 @@ type Config struct {
 	Context                     map[string][]string
 	TrustKeyPath                string
 	Labels                      []string
+	RegistryServe               string
+	TrustPolicy                 string
+	MaxConcurrentDownloads      int
 }
 
 // InstallFlags adds command-line options to the top-level flag parser for
@@ -64,6 +68,9 @@ func (config *Config) InstallFlags() {
 	flag.StringVar(&config.GraphDriver, []string{"s", "-storage-driver"}, "", "Force the Docker runtime to use a specific storage driver")
 	flag.StringVar(&config.ExecDriver, []string{"e", "-exec-driver"}, "native", "Force the Docker runtime to use a specific exec driver")
 	flag.BoolVar(&config.EnableSelinuxSupport, []string{"-selinux-enabled"}, false, "Enable selinux support. SELinux does not presently support the BTRFS storage driver")
+	flag.StringVar(&config.RegistryServe, []string{"-registry-serve"}, "", "Serve the local images read-only over the v2 registry API on this address (e.g. 0.0.0.0:5000)")
+	flag.StringVar(&config.TrustPolicy, []string{"-trust-policy"}, "", "Path to a file listing the keys allowed to sign the images of each repository namespace")
+	flag.IntVar(&config.MaxConcurrentDownloads, []string{"-max-concurrent-downloads"}, graph.DefaultMaxConcurrentDownloads, "Set the maximum number of layers downloaded at the same time by the pulls")
 	flag.IntVar(&config.Mtu, []string{"#mtu", "-mtu"}, 0, "Set the containers network MTU\nif no value is provided: default to the default route MTU or 1500 if no default route is available")
 	opts.IPVar(&config.DefaultIp, []string{"#ip", "-ip"}, "0.0.0.0", "Default IP address to use when binding container ports")
 	opts.ListVar(&config.GraphOptions, []string{"-storage-opt"}, "Set storage driver options")
@@ -30,6 +30,7 @@ import (
 	"github.com/docker/docker/pkg/networkfs/etchosts"
 	"github.com/docker/docker/pkg/networkfs/resolvconf"
 	"github.com/docker/docker/pkg/promise"
+	"github.com/docker/docker/pkg/signal"
 	"github.com/docker/docker/pkg/symlink"
 	"github.com/docker/docker/plugins"
 	"github.com/docker/docker/runconfig"
@@ -704,9 +705,10 @@ func (container *Container) Stop(seconds int) error {
 		return nil
 	}
 
-	// 1. Send a SIGTERM
-	if err := container.KillSig(15); err != nil {
-		log.Infof("Failed to send SIGTERM to the process, force killing")
+	// 1. Send the stop signal, SIGTERM unless the image says otherwise
+	stopSignal := container.StopSignal()
+	if err := container.KillSig(int(stopSignal)); err != nil {
+		log.Infof("Failed to send %s to the process, force killing", stopSignal)
 		if err := container.KillSig(9); err != nil {
 			return err
 		}
@@ -714,7 +716,7 @@ func (container *Container) Stop(seconds int) error {
 
 	// 2. Wait for the process to exit on its own
 	if _, err := container.WaitStop(time.Duration(seconds) * time.Second); err != nil {
-		log.Infof("Container %v failed to exit within %d seconds of SIGTERM - using the force", container.ID, seconds)
+		log.Infof("Container %v failed to exit within %d seconds of %s - using the force", container.ID, seconds, stopSignal)
 		// 3. If it doesn't, then send SIGKILL
 		if err := container.Kill(); err != nil {
 			container.WaitStop(-1 * time.Second)
@@ -724,6 +726,18 @@ func (container *Container) Stop(seconds int) error {
 	return nil
 }
 
+// StopSignal returns the signal the container should be stopped with, which
+// is SIGTERM unless one was set with STOPSIGNAL or in its config.
+func (container *Container) StopSignal() syscall.Signal {
+	if container.Config != nil && container.Config.StopSignal != "" {
+		if sig, err := signal.ParseSignal(container.Config.StopSignal); err == nil {
+			return sig
+		}
+		log.Errorf("Invalid stop signal %q for container %s, using SIGTERM", container.Config.StopSignal, utils.TruncateID(container.ID))
+	}
+	return syscall.SIGTERM
+}
+
 func (container *Container) Restart(seconds int) error {
 	// Avoid unnecessarily unmounting and then directly mounting
 	// the container when the container stops and then starts
@@ -85,6 +85,9 @@ func (daemon *Daemon) Create(config *runconfig.Config, hostConfig *runconfig.Hos
 		if err = img.CheckDepth(); err != nil {
 			return nil, nil, err
 		}
+		if err = daemon.repositories.CheckTrustPolicy(daemon.eng, config.Image); err != nil {
+			return nil, nil, err
+		}
 		imgID = img.ID
 	}
 
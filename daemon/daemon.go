@@ -118,13 +118,16 @@ func (daemon *Daemon) Install(eng *engine.Engine) error {
 		"container_rename":  daemon.ContainerRename,
 		"container_inspect": daemon.ContainerInspect,
 		"container_stats":   daemon.ContainerStats,
+		"container_update":  daemon.ContainerUpdate,
 		"containers":        daemon.Containers,
+		"containers_stats":  daemon.ContainersStats,
 		"create":            daemon.ContainerCreate,
 		"rm":                daemon.ContainerRm,
 		"export":            daemon.ContainerExport,
 		"info":              daemon.CmdInfo,
 		"kill":              daemon.ContainerKill,
 		"logs":              daemon.ContainerLogs,
+		"metrics":           daemon.CmdMetrics,
 		"pause":             daemon.ContainerPause,
 		"resize":            daemon.ContainerResize,
 		"restart":           daemon.ContainerRestart,
@@ -396,7 +399,7 @@ func (daemon *Daemon) restore() error {
 
 		for _, container := range registeredContainers {
 			if container.hostConfig.RestartPolicy.Name == "always" ||
-				(container.hostConfig.RestartPolicy.Name == "on-failure" && container.ExitCode != 0) {
+				((container.hostConfig.RestartPolicy.Name == "on-failure" || container.hostConfig.RestartPolicy.Name == "on-unhealthy") && container.ExitCode != 0) {
 				log.Debugf("Starting container %s", container.ID)
 
 				if err := container.Start(); err != nil {
@@ -907,6 +910,9 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	if err != nil {
 		return nil, fmt.Errorf("Couldn't create Tag store: %s", err)
 	}
+	if err := repositories.SetMaxConcurrentDownloads(config.MaxConcurrentDownloads); err != nil {
+		return nil, err
+	}
 
 	trustDir := path.Join(config.Root, "trust")
 	if err := os.MkdirAll(trustDir, 0700); err != nil && !os.IsExist(err) {
@@ -916,6 +922,13 @@ func NewDaemonFromDirectory(config *Config, eng *engine.Engine) (*Daemon, error)
 	if err != nil {
 		return nil, fmt.Errorf("could not create trust store: %s", err)
 	}
+	if config.TrustPolicy != "" {
+		policy, err := trust.LoadPolicy(config.TrustPolicy)
+		if err != nil {
+			return nil, fmt.Errorf("could not load trust policy: %s", err)
+		}
+		t.SetPolicy(policy)
+	}
 
 	if !config.DisableNetwork {
 		job := eng.Job("init_networkdriver")
@@ -9,6 +9,7 @@ import (
 
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/devices"
+	"github.com/docker/libcontainer/network"
 )
 
 // Context is a generic key value pair that allows
@@ -20,6 +21,7 @@ var (
 	ErrWaitTimeoutReached      = errors.New("Wait timeout reached")
 	ErrDriverAlreadyRegistered = errors.New("A driver already registered this docker init function")
 	ErrDriverNotFound          = errors.New("The requested docker init has not been found")
+	ErrUpdateNotSupported      = errors.New("The driver cannot update the resources of a running container")
 )
 
 type StartCallback func(*ProcessConfig, int)
@@ -58,12 +60,13 @@ type Driver interface {
 	Kill(c *Command, sig int) error
 	Pause(c *Command) error
 	Unpause(c *Command) error
-	Name() string                                 // Driver name
-	Info(id string) Info                          // "temporary" hack (until we move state from core to plugins)
-	GetPidsForContainer(id string) ([]int, error) // Returns a list of pids for the given container.
-	Terminate(c *Command) error                   // kill it with fire
-	Clean(id string) error                        // clean all traces of container exec
-	Stats(id string) (*ResourceStats, error)      // Get resource stats for a running container
+	Name() string                                  // Driver name
+	Info(id string) Info                           // "temporary" hack (until we move state from core to plugins)
+	GetPidsForContainer(id string) ([]int, error)  // Returns a list of pids for the given container.
+	Terminate(c *Command) error                    // kill it with fire
+	Clean(id string) error                         // clean all traces of container exec
+	Stats(id string) (*ResourceStats, error)       // Get resource stats for a running container
+	Update(c *Command, resources *Resources) error // Update the resource limits of a running container
 }
 
 // Network settings of the container
@@ -109,6 +112,9 @@ type ResourceStats struct {
 	Read        time.Time `json:"read"`
 	MemoryLimit int64     `json:"memory_limit"`
 	SystemUsage uint64    `json:"system_usage"`
+	// Interfaces holds the network statistics of each of the container's
+	// interfaces, indexed by the interface name inside the container
+	Interfaces map[string]*network.NetworkStats `json:"interfaces,omitempty"`
 }
 
 type Mount struct {
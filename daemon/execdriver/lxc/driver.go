@@ -529,3 +529,7 @@ func (d *driver) Stats(id string) (*execdriver.ResourceStats, error) {
 	return nil, fmt.Errorf("container stats are not supported with LXC")
 
 }
+
+func (d *driver) Update(c *execdriver.Command, resources *execdriver.Resources) error {
+	return execdriver.ErrUpdateNotSupported
+}
@@ -306,10 +306,17 @@ func (d *driver) Stats(id string) (*execdriver.ResourceStats, error) {
 	if memoryLimit == 0 {
 		memoryLimit = d.machineMemory
 	}
+	// libcontainer only reads the host side of the container's veth pair, the
+	// namespace of the container has the counters of all its interfaces
+	interfaces, err := containerInterfaceStats(c.container, state.InitPid)
+	if err != nil {
+		log.Debugf("Error reading the network statistics of container %s: %s", id, err)
+	}
 	return &execdriver.ResourceStats{
 		Read:           now,
 		ContainerStats: stats,
 		MemoryLimit:    memoryLimit,
+		Interfaces:     interfaces,
 	}, nil
 }
 
@@ -0,0 +1,83 @@
+// +build linux
+
+package native
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/network"
+)
+
+// containerInterfaceStats returns the network statistics of the interfaces
+// of the container with the config, whose init process is pid. Containers
+// which share the network namespace of the host have no interfaces of their
+// own, so none are returned for them.
+func containerInterfaceStats(config *libcontainer.Config, pid int) (map[string]*network.NetworkStats, error) {
+	if !config.Namespaces.Contains(libcontainer.NEWNET) {
+		return map[string]*network.NetworkStats{}, nil
+	}
+	return interfaceStats(pid)
+}
+
+// interfaceStats returns the network statistics of every interface in the
+// network namespace of the process pid, but for the loopback, by name.
+func interfaceStats(pid int) (map[string]*network.NetworkStats, error) {
+	f, err := os.Open(filepath.Join("/proc", strconv.Itoa(pid), "net", "dev"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseNetDev(f)
+}
+
+// parseNetDev parses the per-interface counters of the /proc/net/dev format,
+// which follow two header lines:
+//
+//   eth0: rx_bytes rx_packets rx_errs rx_drop fifo frame compressed multicast tx_bytes tx_packets tx_errs tx_drop ...
+func parseNetDev(r io.Reader) (map[string]*network.NetworkStats, error) {
+	interfaces := make(map[string]*network.NetworkStats)
+	scanner := bufio.NewScanner(r)
+	for n := 0; scanner.Scan(); n++ {
+		if n < 2 {
+			continue
+		}
+		parts := strings.SplitN(scanner.Text(), ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("Invalid network statistics line: %q", scanner.Text())
+		}
+		name := strings.TrimSpace(parts[0])
+		if name == "lo" {
+			continue
+		}
+		fields := strings.Fields(parts[1])
+		if len(fields) < 12 {
+			return nil, fmt.Errorf("Invalid network statistics of %s: %q", name, parts[1])
+		}
+		values := make([]uint64, 12)
+		for i := range values {
+			v, err := strconv.ParseUint(fields[i], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid network statistics of %s: %s", name, err)
+			}
+			values[i] = v
+		}
+		interfaces[name] = &network.NetworkStats{
+			RxBytes:   values[0],
+			RxPackets: values[1],
+			RxErrors:  values[2],
+			RxDropped: values[3],
+			TxBytes:   values[8],
+			TxPackets: values[9],
+			TxErrors:  values[10],
+			TxDropped: values[11],
+		}
+	}
+	return interfaces, scanner.Err()
+}
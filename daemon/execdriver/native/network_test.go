@@ -0,0 +1,66 @@
+// +build linux
+
+package native
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/libcontainer"
+)
+
+const netDev = `Inter-|   Receive                                                |  Transmit
+ face |bytes    packets errs drop fifo frame compressed multicast|bytes    packets errs drop fifo colls carrier compressed
+    lo:     120       2    0    0    0     0          0         0      120       2    0    0    0     0       0          0
+  eth0:    1296      16    1    2    0     0          0         0      648       8    3    4    0     0       0          0
+  eth1:     500       5    0    0    0     0          0         0      250       3    0    1    0     0       0          0
+`
+
+func TestParseNetDev(t *testing.T) {
+	interfaces, err := parseNetDev(strings.NewReader(netDev))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interfaces) != 2 {
+		t.Fatalf("Expected eth0 and eth1 without the loopback, got %v", interfaces)
+	}
+	eth0 := interfaces["eth0"]
+	if eth0 == nil || eth0.RxBytes != 1296 || eth0.RxPackets != 16 || eth0.RxErrors != 1 || eth0.RxDropped != 2 ||
+		eth0.TxBytes != 648 || eth0.TxPackets != 8 || eth0.TxErrors != 3 || eth0.TxDropped != 4 {
+		t.Fatalf("Unexpected statistics of eth0: %+v", eth0)
+	}
+	eth1 := interfaces["eth1"]
+	if eth1 == nil || eth1.RxBytes != 500 || eth1.TxBytes != 250 || eth1.TxDropped != 1 {
+		t.Fatalf("Unexpected statistics of eth1: %+v", eth1)
+	}
+}
+
+func TestParseNetDevInvalid(t *testing.T) {
+	header := strings.Join(strings.SplitN(netDev, "\n", 3)[:2], "\n") + "\n"
+	for _, line := range []string{
+		"eth0 1296 16 0 0 0 0 0 0 648 8 0 0 0 0 0 0",
+		"eth0: 1296 16 0 0",
+		"eth0: 1296 16 0 0 0 0 0 0 648 x 0 0 0 0 0 0",
+	} {
+		if _, err := parseNetDev(strings.NewReader(header + line + "\n")); err == nil {
+			t.Fatalf("Expected an error parsing %q", line)
+		}
+	}
+}
+
+func TestContainerInterfaceStatsHostNetwork(t *testing.T) {
+	config := &libcontainer.Config{Namespaces: libcontainer.Namespaces{{Type: libcontainer.NEWPID}}}
+	interfaces, err := containerInterfaceStats(config, os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(interfaces) != 0 {
+		t.Fatalf("Expected no interfaces for a container on the host network, got %v", interfaces)
+	}
+
+	config.Namespaces.Add(libcontainer.NEWNET, "")
+	if _, err := containerInterfaceStats(config, os.Getpid()); err != nil {
+		t.Fatalf("Expected the interfaces of the namespace of the process to be read, got %s", err)
+	}
+}
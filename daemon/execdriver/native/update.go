@@ -0,0 +1,140 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/libcontainer"
+)
+
+// Update writes the resource limits to the cgroups of a running container
+// and records them in the container's libcontainer config.
+func (d *driver) Update(c *execdriver.Command, resources *execdriver.Resources) error {
+	d.Lock()
+	active := d.activeContainers[c.ID]
+	d.Unlock()
+	if active == nil {
+		return execdriver.ErrNotRunning
+	}
+
+	state, err := libcontainer.GetState(filepath.Join(d.root, c.ID))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return execdriver.ErrNotRunning
+		}
+		return err
+	}
+
+	cgroups := active.container.Cgroups
+	if resources.Memory == 0 && cgroups.Memory != 0 {
+		return fmt.Errorf("the memory limit of a running container cannot be removed")
+	}
+	if resources.Memory != 0 {
+		if err := updateMemory(state.CgroupPaths["memory"], resources); err != nil {
+			return err
+		}
+	}
+	if resources.CpuShares != 0 {
+		if err := writeCgroupFile(state.CgroupPaths["cpu"], "cpu.shares", strconv.FormatInt(resources.CpuShares, 10)); err != nil {
+			return err
+		}
+	}
+	if resources.Cpuset != "" {
+		if err := writeCgroupFile(state.CgroupPaths["cpuset"], "cpuset.cpus", resources.Cpuset); err != nil {
+			return err
+		}
+	}
+
+	cgroups.Memory = resources.Memory
+	cgroups.MemoryReservation = resources.Memory
+	cgroups.MemorySwap = resources.MemorySwap
+	cgroups.CpuShares = resources.CpuShares
+	cgroups.CpusetCpus = resources.Cpuset
+
+	return d.writeContainerFile(active.container, c.ID)
+}
+
+// updateMemory sets the memory and memory+swap limits following the same
+// rules as when the container is created.
+func updateMemory(dir string, resources *execdriver.Resources) error {
+	if dir == "" {
+		return fmt.Errorf("the memory cgroup of the container cannot be found")
+	}
+
+	_, err := os.Stat(filepath.Join(dir, "memory.memsw.limit_in_bytes"))
+	swapLimit := err == nil
+
+	current, err := ioutil.ReadFile(filepath.Join(dir, "memory.limit_in_bytes"))
+	if err != nil {
+		return err
+	}
+	currentLimit, err := strconv.ParseInt(strings.TrimSpace(string(current)), 10, 64)
+	if err != nil {
+		// the kernel reports an unlimited cgroup with a value larger than int64
+		currentLimit = -1
+	}
+
+	writes, err := memoryWrites(resources, currentLimit, swapLimit)
+	if err != nil {
+		return err
+	}
+	for _, w := range writes {
+		if err := writeCgroupFile(dir, w[0], w[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// memoryWrites returns the files of the memory cgroup to write, with their
+// values, to change the memory limit of currentLimit, -1 if unlimited, to the
+// one of resources.  The kernel refuses a memory limit above the memory+swap
+// limit so the limits are written in the order which keeps them consistent.
+func memoryWrites(resources *execdriver.Resources, currentLimit int64, swapLimit bool) ([][2]string, error) {
+	memsw := ""
+	switch {
+	case resources.MemorySwap == 0:
+		// By default, MemorySwap is set to twice the size of RAM.
+		memsw = strconv.FormatInt(resources.Memory*2, 10)
+	case resources.MemorySwap > 0:
+		memsw = strconv.FormatInt(resources.MemorySwap, 10)
+	default:
+		memsw = "-1"
+	}
+	if !swapLimit {
+		if resources.MemorySwap > 0 {
+			return nil, fmt.Errorf("the kernel does not support updating the swap limit")
+		}
+		memsw = ""
+	}
+
+	writes := [][2]string{
+		{"memory.limit_in_bytes", strconv.FormatInt(resources.Memory, 10)},
+		{"memory.soft_limit_in_bytes", strconv.FormatInt(resources.Memory, 10)},
+	}
+	if memsw != "" {
+		if currentLimit >= 0 && resources.Memory > currentLimit {
+			writes = append([][2]string{{"memory.memsw.limit_in_bytes", memsw}}, writes...)
+		} else {
+			writes = append(writes, [2]string{"memory.memsw.limit_in_bytes", memsw})
+		}
+	}
+	return writes, nil
+}
+
+func writeCgroupFile(dir, file, data string) error {
+	if dir == "" {
+		return fmt.Errorf("the cgroup for %s cannot be found", file)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(data), 0700); err != nil {
+		return fmt.Errorf("updating %s: %s", file, err)
+	}
+	return nil
+}
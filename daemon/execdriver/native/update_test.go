@@ -0,0 +1,92 @@
+// +build linux,cgo
+
+package native
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+)
+
+func TestMemoryWrites(t *testing.T) {
+	for _, test := range []struct {
+		resources    execdriver.Resources
+		currentLimit int64
+		swapLimit    bool
+		expected     [][2]string
+	}{
+		// Raising the limit raises the memory+swap limit first
+		{execdriver.Resources{Memory: 200, MemorySwap: 400}, 100, true, [][2]string{
+			{"memory.memsw.limit_in_bytes", "400"},
+			{"memory.limit_in_bytes", "200"},
+			{"memory.soft_limit_in_bytes", "200"},
+		}},
+		// Lowering the limit lowers the memory+swap limit last
+		{execdriver.Resources{Memory: 50, MemorySwap: 0}, 100, true, [][2]string{
+			{"memory.limit_in_bytes", "50"},
+			{"memory.soft_limit_in_bytes", "50"},
+			{"memory.memsw.limit_in_bytes", "100"},
+		}},
+		// An unlimited cgroup has no memory+swap limit to exceed
+		{execdriver.Resources{Memory: 200, MemorySwap: -1}, -1, true, [][2]string{
+			{"memory.limit_in_bytes", "200"},
+			{"memory.soft_limit_in_bytes", "200"},
+			{"memory.memsw.limit_in_bytes", "-1"},
+		}},
+		// Without swap accounting, only the memory limits are written
+		{execdriver.Resources{Memory: 200}, 100, false, [][2]string{
+			{"memory.limit_in_bytes", "200"},
+			{"memory.soft_limit_in_bytes", "200"},
+		}},
+	} {
+		writes, err := memoryWrites(&test.resources, test.currentLimit, test.swapLimit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(writes, test.expected) {
+			t.Fatalf("%+v from %d: expected %v, got %v", test.resources, test.currentLimit, test.expected, writes)
+		}
+	}
+
+	if _, err := memoryWrites(&execdriver.Resources{Memory: 200, MemorySwap: 400}, 100, false); err == nil {
+		t.Fatal("Expected an error setting a swap limit without swap accounting")
+	}
+}
+
+func TestUpdateMemory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test-memory-cgroup")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	for file, value := range map[string]string{
+		"memory.limit_in_bytes":       "9223372036854775807\n",
+		"memory.soft_limit_in_bytes":  "9223372036854775807\n",
+		"memory.memsw.limit_in_bytes": "9223372036854775807\n",
+	} {
+		if err := ioutil.WriteFile(filepath.Join(dir, file), []byte(value), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := updateMemory(dir, &execdriver.Resources{Memory: 4194304}); err != nil {
+		t.Fatal(err)
+	}
+	for file, expected := range map[string]string{
+		"memory.limit_in_bytes":       "4194304",
+		"memory.soft_limit_in_bytes":  "4194304",
+		"memory.memsw.limit_in_bytes": "8388608",
+	} {
+		value, err := ioutil.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(value) != expected {
+			t.Fatalf("Expected %s to be %s, got %s", file, expected, value)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package daemon
+
+import (
+	"io/ioutil"
+	"strings"
+	"syscall"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/daemon/execdriver/lxc"
+	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
+)
+
+const (
+	// defaultProbeInterval is the time to wait between two health probes when
+	// the healthcheck of the container does not set one
+	defaultProbeInterval = 30 * time.Second
+
+	// defaultProbeTimeout is the time after which a health probe which did not
+	// exit yet is killed and considered failed
+	defaultProbeTimeout = 30 * time.Second
+
+	// The health of a running container, as reported in its state
+	healthStarting  = "starting"
+	healthHealthy   = "healthy"
+	healthUnhealthy = "unhealthy"
+)
+
+// shouldProbeHealth returns true if the container has a health probe for the
+// daemon to run.
+func (container *Container) shouldProbeHealth() bool {
+	health := container.Config.Healthcheck
+	if health == nil || len(health.Test) == 0 {
+		return false
+	}
+	switch health.Test[0] {
+	case "CMD", "CMD-SHELL":
+		return len(health.Test) > 1
+	}
+	return false
+}
+
+// setHealth sets the health of the run of the container which stop belongs
+// to, unless that run is over, and returns false once it is.  The container
+// must not be locked.
+func (container *Container) setHealth(status string, stop chan struct{}) bool {
+	container.Lock()
+	defer container.Unlock()
+	select {
+	case <-stop:
+		return false
+	default:
+	}
+	container.Health = status
+	return true
+}
+
+// probeHealth runs the health probe of the container every interval, until
+// stop is closed, and reports the results to the monitor.
+func (m *containerMonitor) probeHealth(health *runconfig.HealthConfig, stop chan struct{}) {
+	container := m.container
+	if strings.HasPrefix(container.daemon.execDriver.Name(), lxc.DriverName) {
+		log.Errorf("Cannot run the health probe of container %s: %s", utils.TruncateID(container.ID), lxc.ErrExec)
+		return
+	}
+
+	interval := health.Interval
+	if interval <= 0 {
+		interval = defaultProbeInterval
+	}
+	timeout := health.Timeout
+	if timeout <= 0 {
+		timeout = defaultProbeTimeout
+	}
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(interval):
+		}
+
+		healthy := m.runProbe(health.Test, timeout)
+
+		status := healthUnhealthy
+		if healthy {
+			status = healthHealthy
+		}
+		// The result of a probe interrupted by the exit of the container is meaningless
+		if !container.setHealth(status, stop) {
+			return
+		}
+
+		m.HealthStatus(healthy)
+	}
+}
+
+// runProbe execs the probe in the container and returns true if it exits
+// with 0 before the timeout.
+func (m *containerMonitor) runProbe(test []string, timeout time.Duration) bool {
+	container := m.container
+
+	processConfig := execdriver.ProcessConfig{
+		Entrypoint: test[1],
+		Arguments:  test[2:],
+	}
+	if test[0] == "CMD-SHELL" {
+		processConfig.Entrypoint = "/bin/sh"
+		processConfig.Arguments = []string{"-c", strings.Join(test[1:], " ")}
+	}
+	execConfig := &execConfig{
+		ID:            utils.GenerateRandomID(),
+		ProcessConfig: processConfig,
+		Container:     container,
+		Running:       true,
+	}
+	pipes := execdriver.NewPipes(nil, ioutil.Discard, ioutil.Discard, false)
+
+	var (
+		started  = make(chan int, 1)
+		exitCode = make(chan int, 1)
+	)
+	go func() {
+		code, err := container.daemon.Exec(container, execConfig, pipes, func(_ *execdriver.ProcessConfig, pid int) {
+			started <- pid
+		})
+		if err != nil {
+			log.Debugf("Error running the health probe of container %s: %s", utils.TruncateID(container.ID), err)
+		}
+		exitCode <- code
+	}()
+
+	select {
+	case code := <-exitCode:
+		log.Debugf("Health probe of container %s exited with code %d", utils.TruncateID(container.ID), code)
+		return code == 0
+	case <-time.After(timeout):
+		log.Infof("Health probe of container %s failed to exit within %s, killing it", utils.TruncateID(container.ID), timeout)
+		select {
+		case pid := <-started:
+			if err := syscall.Kill(pid, syscall.SIGKILL); err != nil {
+				log.Debugf("Error killing the health probe of container %s: %s", utils.TruncateID(container.ID), err)
+			}
+		default:
+		}
+		return false
+	}
+}
@@ -0,0 +1,19 @@
+package daemon
+
+import "testing"
+
+func TestSetHealth(t *testing.T) {
+	container := &Container{State: NewState()}
+	stop := make(chan struct{})
+
+	if !container.setHealth(healthHealthy, stop) || container.Health != healthHealthy {
+		t.Fatalf("Expected the health of the run to be set, got %q", container.Health)
+	}
+
+	// A probe which outlives its run must not overwrite the health of the next one
+	close(stop)
+	container.Health = healthStarting
+	if container.setHealth(healthUnhealthy, stop) || container.Health != healthStarting {
+		t.Fatalf("Expected the health of a finished run to be ignored, got %q", container.Health)
+	}
+}
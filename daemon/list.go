@@ -89,6 +89,10 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 			return nil
 		}
 
+		if !psFilters.MatchKVList("label", container.Config.Labels) {
+			return nil
+		}
+
 		if before != "" && !foundBefore {
 			if container.ID == beforeCont.ID {
 				foundBefore = true
@@ -146,6 +150,7 @@ func (daemon *Daemon) Containers(job *engine.Job) engine.Status {
 			return err
 		}
 		out.Set("Ports", str)
+		out.SetJson("Labels", container.Config.Labels)
 		if size {
 			sizeRw, sizeRootFs := container.GetSize()
 			out.SetInt64("SizeRw", sizeRw)
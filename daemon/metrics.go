@@ -0,0 +1,200 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/api/stats"
+	"github.com/docker/docker/engine"
+)
+
+// CmdMetrics writes the daemon's and its running containers' metrics to the
+// job's stdout in the Prometheus text exposition format.
+func (daemon *Daemon) CmdMetrics(job *engine.Job) engine.Status {
+	m := newMetricsWriter()
+
+	containers := daemon.List()
+	states := map[string]int{
+		"running":    0,
+		"paused":     0,
+		"restarting": 0,
+		"exited":     0,
+	}
+	for _, container := range containers {
+		states[container.State.StateString()]++
+	}
+	for _, state := range []string{"running", "paused", "restarting", "exited"} {
+		m.add("docker_daemon_containers", "gauge", "Number of containers by state.", float64(states[state]), "state", state)
+	}
+
+	images, _ := daemon.Graph().Map()
+	m.add("docker_daemon_images", "gauge", "Number of images in the graph.", float64(len(images)))
+
+	cjob := job.Eng.Job("subscribers_count")
+	env, _ := cjob.Stdout.AddEnv()
+	if err := cjob.Run(); err != nil {
+		return job.Error(err)
+	}
+	m.add("docker_daemon_events_subscribers", "gauge", "Number of clients listening for events.", float64(env.GetInt("count")))
+
+	jobStats := job.Eng.JobStats()
+	names := make([]string, 0, len(jobStats))
+	for name := range jobStats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		stat := jobStats[name]
+		m.add("docker_engine_job_duration_seconds_sum", "summary", "Time spent running engine jobs.", stat.Duration.Seconds(), "job", name)
+		m.add("docker_engine_job_duration_seconds_count", "summary", "", float64(stat.Count), "job", name)
+	}
+
+	samples := daemon.statsCollector.sample(containers)
+	for _, container := range containers {
+		update, exists := samples[container]
+		if !exists {
+			continue
+		}
+		addContainerMetrics(m, container, resourceStatsToAPI(update))
+	}
+
+	if _, err := m.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+// addContainerMetrics records the resource usage of a single container
+func addContainerMetrics(m *metricsWriter, container *Container, s *stats.Stats) {
+	id := []string{"id", container.ID, "name", strings.TrimPrefix(container.Name, "/")}
+	label := func(extra ...string) []string {
+		return append(append([]string{}, id...), extra...)
+	}
+
+	cpu := s.CpuStats.CpuUsage
+	m.add("docker_container_cpu_usage_seconds_total", "counter", "Total CPU time consumed.", nanosToSeconds(cpu.TotalUsage), id...)
+	m.add("docker_container_cpu_kernelmode_seconds_total", "counter", "CPU time consumed in kernel mode.", nanosToSeconds(cpu.UsageInKernelmode), id...)
+	m.add("docker_container_cpu_usermode_seconds_total", "counter", "CPU time consumed in user mode.", nanosToSeconds(cpu.UsageInUsermode), id...)
+	m.add("docker_container_cpu_throttled_seconds_total", "counter", "Time the container was throttled.", nanosToSeconds(s.CpuStats.ThrottlingData.ThrottledTime), id...)
+
+	mem := s.MemoryStats
+	m.add("docker_container_memory_usage_bytes", "gauge", "Current memory usage.", float64(mem.Usage), id...)
+	m.add("docker_container_memory_max_usage_bytes", "gauge", "Maximum memory usage ever recorded.", float64(mem.MaxUsage), id...)
+	m.add("docker_container_memory_limit_bytes", "gauge", "Memory limit of the container.", float64(mem.Limit), id...)
+	m.add("docker_container_memory_failures_total", "counter", "Number of times memory usage hit the limit.", float64(mem.Failcnt), id...)
+
+	for _, e := range s.BlkioStats.IoServiceBytesRecursive {
+		m.add("docker_container_blkio_io_service_bytes_total", "counter", "Bytes transferred to and from block devices.", float64(e.Value), label("device", fmt.Sprintf("%d:%d", e.Major, e.Minor), "op", e.Op)...)
+	}
+	for _, e := range s.BlkioStats.IoServicedRecursive {
+		m.add("docker_container_blkio_io_serviced_total", "counter", "IO operations performed on block devices.", float64(e.Value), label("device", fmt.Sprintf("%d:%d", e.Major, e.Minor), "op", e.Op)...)
+	}
+
+	networks := s.Networks
+	if len(networks) == 0 {
+		networks = map[string]stats.Network{"": s.Network}
+	}
+	// the interfaces are sorted so that the output is stable across scrapes
+	ifaces := make([]string, 0, len(networks))
+	for iface := range networks {
+		ifaces = append(ifaces, iface)
+	}
+	sort.Strings(ifaces)
+	for _, iface := range ifaces {
+		net := networks[iface]
+		l := id
+		if iface != "" {
+			l = label("interface", iface)
+		}
+		m.add("docker_container_network_receive_bytes_total", "counter", "Bytes received.", float64(net.RxBytes), l...)
+		m.add("docker_container_network_receive_packets_total", "counter", "Packets received.", float64(net.RxPackets), l...)
+		m.add("docker_container_network_receive_errors_total", "counter", "Receive errors.", float64(net.RxErrors), l...)
+		m.add("docker_container_network_receive_dropped_total", "counter", "Received packets dropped.", float64(net.RxDropped), l...)
+		m.add("docker_container_network_transmit_bytes_total", "counter", "Bytes transmitted.", float64(net.TxBytes), l...)
+		m.add("docker_container_network_transmit_packets_total", "counter", "Packets transmitted.", float64(net.TxPackets), l...)
+		m.add("docker_container_network_transmit_errors_total", "counter", "Transmit errors.", float64(net.TxErrors), l...)
+		m.add("docker_container_network_transmit_dropped_total", "counter", "Transmitted packets dropped.", float64(net.TxDropped), l...)
+	}
+}
+
+func nanosToSeconds(v uint64) float64 {
+	return float64(v) / nanoSeconds
+}
+
+type metricSample struct {
+	labels []string
+	value  float64
+}
+
+type metricFamily struct {
+	name    string
+	typ     string
+	help    string
+	samples []metricSample
+}
+
+// metricsWriter groups samples by metric name so that they can be written
+// out in the Prometheus text format, where all samples of a metric must be
+// contiguous.
+type metricsWriter struct {
+	families []*metricFamily
+	index    map[string]*metricFamily
+}
+
+func newMetricsWriter() *metricsWriter {
+	return &metricsWriter{
+		index: make(map[string]*metricFamily),
+	}
+}
+
+// add records a sample for the metric name.  labels are given as a list of
+// alternating label names and values.  The summary suffixes _sum and _count
+// are grouped under their base name.
+func (m *metricsWriter) add(name, typ, help string, value float64, labels ...string) {
+	family := name
+	if typ == "summary" {
+		family = strings.TrimSuffix(strings.TrimSuffix(name, "_sum"), "_count")
+	}
+	f, exists := m.index[family]
+	if !exists {
+		f = &metricFamily{name: family, typ: typ, help: help}
+		m.index[family] = f
+		m.families = append(m.families, f)
+	}
+	f.samples = append(f.samples, metricSample{labels: append([]string{name}, labels...), value: value})
+}
+
+func (m *metricsWriter) WriteTo(w io.Writer) (int64, error) {
+	var total int64
+	for _, f := range m.families {
+		n, err := fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", f.name, f.help, f.name, f.typ)
+		total += int64(n)
+		if err != nil {
+			return total, err
+		}
+		for _, s := range f.samples {
+			n, err := fmt.Fprintf(w, "%s%s %s\n", s.labels[0], formatLabels(s.labels[1:]), strconv.FormatFloat(s.value, 'g', -1, 64))
+			total += int64(n)
+			if err != nil {
+				return total, err
+			}
+		}
+	}
+	return total, nil
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	pairs := make([]string, 0, len(labels)/2)
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", labels[i], labelEscaper.Replace(labels[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
@@ -0,0 +1,73 @@
+package daemon
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/api/stats"
+)
+
+func TestMetricsWriterGroupsFamilies(t *testing.T) {
+	m := newMetricsWriter()
+	m.add("docker_daemon_containers", "gauge", "Number of containers by state.", 2, "state", "running")
+	m.add("docker_engine_job_duration_seconds_sum", "summary", "Time spent running engine jobs.", 1.5, "job", "start")
+	m.add("docker_engine_job_duration_seconds_count", "summary", "", 3, "job", "start")
+	m.add("docker_daemon_containers", "gauge", "Number of containers by state.", 1, "state", "exited")
+	m.add("docker_container_memory_usage_bytes", "gauge", "Current memory usage.", 1024, "name", `we"ird`)
+
+	var buf bytes.Buffer
+	if _, err := m.WriteTo(&buf); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `# HELP docker_daemon_containers Number of containers by state.
+# TYPE docker_daemon_containers gauge
+docker_daemon_containers{state="running"} 2
+docker_daemon_containers{state="exited"} 1
+# HELP docker_engine_job_duration_seconds Time spent running engine jobs.
+# TYPE docker_engine_job_duration_seconds summary
+docker_engine_job_duration_seconds_sum{job="start"} 1.5
+docker_engine_job_duration_seconds_count{job="start"} 3
+# HELP docker_container_memory_usage_bytes Current memory usage.
+# TYPE docker_container_memory_usage_bytes gauge
+docker_container_memory_usage_bytes{name="we\"ird"} 1024
+`
+	if buf.String() != expected {
+		t.Fatalf("Expected:\n%s\nReceived:\n%s", expected, buf.String())
+	}
+}
+
+func TestContainerMetricsInterfacesSorted(t *testing.T) {
+	container := &Container{ID: "abc", Name: "/web"}
+	s := &stats.Stats{
+		Networks: map[string]stats.Network{
+			"eth1": {RxBytes: 2},
+			"eth0": {RxBytes: 1},
+			"eth2": {RxBytes: 3},
+		},
+	}
+
+	var expected string
+	for i := 0; i < 5; i++ {
+		m := newMetricsWriter()
+		addContainerMetrics(m, container, s)
+		var buf bytes.Buffer
+		if _, err := m.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if i == 0 {
+			expected = buf.String()
+		} else if buf.String() != expected {
+			t.Fatalf("Expected the same output on every call, got:\n%s\nthen:\n%s", expected, buf.String())
+		}
+	}
+
+	receive := `docker_container_network_receive_bytes_total{id="abc",name="web",interface="eth0"} 1
+docker_container_network_receive_bytes_total{id="abc",name="web",interface="eth1"} 2
+docker_container_network_receive_bytes_total{id="abc",name="web",interface="eth2"} 3
+`
+	if !strings.Contains(expected, receive) {
+		t.Fatalf("Expected the interfaces in order:\n%s\nReceived:\n%s", receive, expected)
+	}
+}
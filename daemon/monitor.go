@@ -4,6 +4,7 @@ import (
 	"io"
 	"os/exec"
 	"sync"
+	"syscall"
 	"time"
 
 	log "github.com/Sirupsen/logrus"
@@ -12,7 +13,13 @@ import (
 	"github.com/docker/docker/utils"
 )
 
-const defaultTimeIncrement = 100
+const (
+	defaultTimeIncrement = 100
+
+	// defaultUnhealthyThreshold is the number of consecutive failed health probes
+	// an "on-unhealthy" container may report before it is killed and restarted
+	defaultUnhealthyThreshold = 3
+)
 
 // containerMonitor monitors the execution of a container's main process.
 // If a restart policy is specified for the container the monitor will ensure that the
@@ -50,6 +57,14 @@ type containerMonitor struct {
 
 	// lastStartTime is the time which the monitor last exec'd the container's process
 	lastStartTime time.Time
+
+	// unhealthyCount is the number of consecutive failed health probes reported
+	// for the current run of the container's process
+	unhealthyCount int
+
+	// healthStop is closed when the current run of the container's process exits
+	// to stop its health probes
+	healthStop chan struct{}
 }
 
 // newContainerMonitor returns an initialized containerMonitor for the provided container
@@ -135,7 +150,11 @@ func (m *containerMonitor) Start() error {
 
 		m.lastStartTime = time.Now()
 
-		if exitStatus, err = m.container.daemon.Run(m.container, pipes, m.callback); err != nil {
+		m.healthStop = make(chan struct{})
+
+		exitStatus, err = m.container.daemon.Run(m.container, pipes, m.callback)
+		close(m.healthStop)
+		if err != nil {
 			// if we receive an internal error from the initial start of a container then lets
 			// return it instead of entering the restart loop
 			if m.container.RestartCount == 0 {
@@ -197,6 +216,10 @@ func (m *containerMonitor) resetMonitor(successful bool) {
 		m.timeIncrement *= 2
 	}
 
+	m.mux.Lock()
+	m.unhealthyCount = 0
+	m.mux.Unlock()
+
 	// the container exited successfully so we need to reset the failure counter
 	if successful {
 		m.failureCount = 0
@@ -228,8 +251,9 @@ func (m *containerMonitor) shouldRestart(exitCode int) bool {
 	switch m.restartPolicy.Name {
 	case "always":
 		return true
-	case "on-failure":
-		// the default value of 0 for MaximumRetryCount means that we will not enforce a maximum count
+	case "on-failure", "on-unhealthy":
+		// the default value of 0 for MaximumRetryCount means that we will not enforce a maximum count.
+		// A container killed for being unhealthy exits with a non zero code so it is counted as a failure
 		if max := m.restartPolicy.MaximumRetryCount; max != 0 && m.failureCount > max {
 			log.Debugf("stopping restart of container %s because maximum failure could of %d has been reached",
 				utils.TruncateID(m.container.ID), max)
@@ -242,6 +266,50 @@ func (m *containerMonitor) shouldRestart(exitCode int) bool {
 	return false
 }
 
+// HealthStatus records the result of a health probe run against the container.
+// When the restart policy is "on-unhealthy" and the container has reported unhealthy
+// for the configured number of consecutive probes the process is killed so that the
+// monitor restarts it.
+func (m *containerMonitor) HealthStatus(healthy bool) {
+	m.mux.Lock()
+
+	if healthy || m.shouldStop {
+		m.unhealthyCount = 0
+		m.mux.Unlock()
+		return
+	}
+
+	m.unhealthyCount++
+
+	threshold := m.restartPolicy.UnhealthyThreshold
+	if threshold == 0 {
+		threshold = defaultUnhealthyThreshold
+	}
+
+	if m.restartPolicy.Name != "on-unhealthy" || m.unhealthyCount < threshold {
+		m.mux.Unlock()
+		return
+	}
+
+	m.unhealthyCount = 0
+	m.mux.Unlock()
+
+	container := m.container
+	container.Lock()
+	defer container.Unlock()
+
+	if !container.Running || container.Paused || container.Restarting {
+		return
+	}
+
+	log.Infof("container %s reported unhealthy for %d consecutive probes, restarting", utils.TruncateID(container.ID), threshold)
+	container.LogEvent("unhealthy")
+
+	if err := container.daemon.Kill(container, int(syscall.SIGKILL)); err != nil {
+		log.Errorf("%s: Error killing unhealthy container: %s", container.ID, err)
+	}
+}
+
 // callback ensures that the container's state is properly updated after we
 // received ack from the execution drivers
 func (m *containerMonitor) callback(processConfig *execdriver.ProcessConfig, pid int) {
@@ -254,8 +322,22 @@ func (m *containerMonitor) callback(processConfig *execdriver.ProcessConfig, pid
 		}
 	}
 
+	// Start keeps the container locked until the first run is started, the
+	// runs after a restart lock it here
+	restarted := m.container.RestartCount > 0
+	if restarted {
+		m.container.Lock()
+	}
 	m.container.setRunning(pid)
 
+	if m.container.shouldProbeHealth() {
+		m.container.Health = healthStarting
+		go m.probeHealth(m.container.Config.Healthcheck, m.healthStop)
+	}
+	if restarted {
+		m.container.Unlock()
+	}
+
 	// signal that the process has started
 	// close channel only if not closed
 	select {
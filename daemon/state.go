@@ -15,6 +15,7 @@ type State struct {
 	Paused     bool
 	Restarting bool
 	OOMKilled  bool
+	Health     string // health reported by the probe of a running container, if it has one
 	Pid        int
 	ExitCode   int
 	Error      string // contains last known error when starting the container
@@ -39,6 +40,10 @@ func (s *State) String() string {
 			return fmt.Sprintf("Restarting (%d) %s ago", s.ExitCode, units.HumanDuration(time.Now().UTC().Sub(s.FinishedAt)))
 		}
 
+		if s.Health != "" {
+			return fmt.Sprintf("Up %s (%s)", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)), s.Health)
+		}
+
 		return fmt.Sprintf("Up %s", units.HumanDuration(time.Now().UTC().Sub(s.StartedAt)))
 	}
 
@@ -144,6 +149,7 @@ func (s *State) setRunning(pid int) {
 	s.Running = true
 	s.Paused = false
 	s.Restarting = false
+	s.Health = ""
 	s.ExitCode = 0
 	s.Pid = pid
 	s.StartedAt = time.Now().UTC()
@@ -160,6 +166,7 @@ func (s *State) SetStopped(exitStatus *execdriver.ExitStatus) {
 func (s *State) setStopped(exitStatus *execdriver.ExitStatus) {
 	s.Running = false
 	s.Restarting = false
+	s.Health = ""
 	s.Pid = 0
 	s.FinishedAt = time.Now().UTC()
 	s.ExitCode = exitStatus.ExitCode
@@ -176,6 +183,7 @@ func (s *State) SetRestarting(exitStatus *execdriver.ExitStatus) {
 	// all the checks in docker around rm/stop/etc
 	s.Running = true
 	s.Restarting = true
+	s.Health = ""
 	s.Pid = 0
 	s.FinishedAt = time.Now().UTC()
 	s.ExitCode = exitStatus.ExitCode
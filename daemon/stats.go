@@ -6,22 +6,23 @@ import (
 	"github.com/docker/docker/api/stats"
 	"github.com/docker/docker/daemon/execdriver"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/parsers/filters"
 	"github.com/docker/libcontainer"
 	"github.com/docker/libcontainer/cgroups"
+	"github.com/docker/libcontainer/network"
 )
 
 func (daemon *Daemon) ContainerStats(job *engine.Job) engine.Status {
+	if job.EnvExists("stream") && !job.GetenvBool("stream") {
+		return daemon.containerStatsOnce(job)
+	}
 	updates, err := daemon.SubscribeToContainerStats(job.Args[0])
 	if err != nil {
 		return job.Error(err)
 	}
 	enc := json.NewEncoder(job.Stdout)
 	for v := range updates {
-		update := v.(*execdriver.ResourceStats)
-		ss := convertToAPITypes(update.ContainerStats)
-		ss.MemoryStats.Limit = uint64(update.MemoryLimit)
-		ss.Read = update.Read
-		ss.CpuStats.SystemUsage = update.SystemUsage
+		ss := resourceStatsToAPI(v.(*execdriver.ResourceStats))
 		if err := enc.Encode(ss); err != nil {
 			// TODO: handle the specific broken pipe
 			daemon.UnsubscribeToContainerStats(job.Args[0], updates)
@@ -31,21 +32,115 @@ func (daemon *Daemon) ContainerStats(job *engine.Job) engine.Status {
 	return engine.StatusOK
 }
 
+// containerStatsOnce writes a single stats reading for a running container.
+// Two readings are taken from the collector so that the previous cpu usage
+// can be included for computing the cpu usage between them.
+func (daemon *Daemon) containerStatsOnce(job *engine.Job) engine.Status {
+	name := job.Args[0]
+	container := daemon.Get(name)
+	if container == nil {
+		return job.Errorf("No such container: %s", name)
+	}
+	if !container.IsRunning() {
+		return job.Errorf("Container %s is not running", name)
+	}
+	updates, err := daemon.SubscribeToContainerStats(name)
+	if err != nil {
+		return job.Error(err)
+	}
+	defer daemon.UnsubscribeToContainerStats(name, updates)
+
+	var previous *stats.Stats
+	for v := range updates {
+		ss := resourceStatsToAPI(v.(*execdriver.ResourceStats))
+		if previous == nil {
+			previous = ss
+			continue
+		}
+		ss.PreCpuStats = previous.CpuStats
+		if err := json.NewEncoder(job.Stdout).Encode(ss); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	}
+	return job.Errorf("Container %s stopped before its stats could be read", name)
+}
+
+// ContainersStats writes a single stats reading for every running container
+// matching the job's filters.  All containers are read in the same pass of
+// the stats collector.
+func (daemon *Daemon) ContainersStats(job *engine.Job) engine.Status {
+	statsFilters, err := filters.FromParam(job.Getenv("filters"))
+	if err != nil {
+		return job.Error(err)
+	}
+
+	var containers []*Container
+	for _, container := range daemon.List() {
+		if !container.IsRunning() {
+			continue
+		}
+		if !statsFilters.Match("name", container.Name) || !statsFilters.Match("id", container.ID) {
+			continue
+		}
+		if !statsFilters.Match("status", container.State.StateString()) {
+			continue
+		}
+		containers = append(containers, container)
+	}
+
+	out := []*stats.ContainerStats{}
+	samples := daemon.statsCollector.sample(containers)
+	for _, container := range containers {
+		update, exists := samples[container]
+		if !exists {
+			continue
+		}
+		out = append(out, &stats.ContainerStats{
+			Id:    container.ID,
+			Name:  container.Name,
+			Stats: resourceStatsToAPI(update),
+		})
+	}
+	if err := json.NewEncoder(job.Stdout).Encode(out); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+// resourceStatsToAPI converts a stats reading from the exec driver, including
+// the values recorded by the daemon, to the api specific structs.
+func resourceStatsToAPI(update *execdriver.ResourceStats) *stats.Stats {
+	ss := convertToAPITypes(update.ContainerStats)
+	ss.MemoryStats.Limit = uint64(update.MemoryLimit)
+	ss.Read = update.Read
+	ss.CpuStats.SystemUsage = update.SystemUsage
+	if len(update.Interfaces) > 0 {
+		// the totals add up all the interfaces of the breakdown
+		ss.Network = stats.Network{}
+		ss.Networks = make(map[string]stats.Network, len(update.Interfaces))
+		for name, ns := range update.Interfaces {
+			n := convertNetworkStats(ns)
+			ss.Networks[name] = n
+			ss.Network.RxBytes += n.RxBytes
+			ss.Network.RxPackets += n.RxPackets
+			ss.Network.RxErrors += n.RxErrors
+			ss.Network.RxDropped += n.RxDropped
+			ss.Network.TxBytes += n.TxBytes
+			ss.Network.TxPackets += n.TxPackets
+			ss.Network.TxErrors += n.TxErrors
+			ss.Network.TxDropped += n.TxDropped
+		}
+	}
+	return ss
+}
+
 // convertToAPITypes converts the libcontainer.ContainerStats to the api specific
 // structs.  This is done to preserve API compatibility and versioning.
 func convertToAPITypes(ls *libcontainer.ContainerStats) *stats.Stats {
 	s := &stats.Stats{}
 	if ls.NetworkStats != nil {
-		s.Network = stats.Network{
-			RxBytes:   ls.NetworkStats.RxBytes,
-			RxPackets: ls.NetworkStats.RxPackets,
-			RxErrors:  ls.NetworkStats.RxErrors,
-			RxDropped: ls.NetworkStats.RxDropped,
-			TxBytes:   ls.NetworkStats.TxBytes,
-			TxPackets: ls.NetworkStats.TxPackets,
-			TxErrors:  ls.NetworkStats.TxErrors,
-			TxDropped: ls.NetworkStats.TxDropped,
-		}
+		s.Network = convertNetworkStats(ls.NetworkStats)
 	}
 	cs := ls.CgroupStats
 	if cs != nil {
@@ -84,6 +179,19 @@ func convertToAPITypes(ls *libcontainer.ContainerStats) *stats.Stats {
 	return s
 }
 
+func convertNetworkStats(ns *network.NetworkStats) stats.Network {
+	return stats.Network{
+		RxBytes:   ns.RxBytes,
+		RxPackets: ns.RxPackets,
+		RxErrors:  ns.RxErrors,
+		RxDropped: ns.RxDropped,
+		TxBytes:   ns.TxBytes,
+		TxPackets: ns.TxPackets,
+		TxErrors:  ns.TxErrors,
+		TxDropped: ns.TxDropped,
+	}
+}
+
 func copyBlkioEntry(entries []cgroups.BlkioStatEntry) []stats.BlkioStatEntry {
 	out := make([]stats.BlkioStatEntry, len(entries))
 	for i, re := range entries {
@@ -96,6 +96,33 @@ func (s *statsCollector) run() {
 	}
 }
 
+// sample takes a single stats reading for each of the provided containers
+// which are running, sharing one reading of the host's cpu usage so that the
+// results can be compared with each other.
+func (s *statsCollector) sample(containers []*Container) map[*Container]*execdriver.ResourceStats {
+	out := make(map[*Container]*execdriver.ResourceStats, len(containers))
+	systemUsage, err := s.getSystemCpuUsage()
+	if err != nil {
+		log.Errorf("collecting system cpu usage: %v", err)
+		return out
+	}
+	for _, container := range containers {
+		if !container.IsRunning() {
+			continue
+		}
+		stats, err := container.Stats()
+		if err != nil {
+			if err != execdriver.ErrNotRunning {
+				log.Errorf("collecting stats for %s: %v", container.ID, err)
+			}
+			continue
+		}
+		stats.SystemUsage = systemUsage
+		out[container] = stats
+	}
+	return out
+}
+
 const nanoSeconds = 1e9
 
 // getSystemCpuUSage returns the host system's cpu usage in nanoseconds
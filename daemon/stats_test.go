@@ -0,0 +1,37 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/libcontainer"
+	"github.com/docker/libcontainer/network"
+)
+
+func TestResourceStatsToAPINetworks(t *testing.T) {
+	update := &execdriver.ResourceStats{
+		ContainerStats: &libcontainer.ContainerStats{
+			NetworkStats: &network.NetworkStats{RxBytes: 100, TxBytes: 50},
+		},
+		Interfaces: map[string]*network.NetworkStats{
+			"eth0": {RxBytes: 100, RxPackets: 10, TxBytes: 50, TxDropped: 1},
+			"eth1": {RxBytes: 20, RxPackets: 2, TxBytes: 5, TxErrors: 3},
+		},
+	}
+
+	s := resourceStatsToAPI(update)
+	if len(s.Networks) != 2 || s.Networks["eth0"].RxBytes != 100 || s.Networks["eth1"].TxErrors != 3 {
+		t.Fatalf("Expected the statistics of eth0 and eth1, got %+v", s.Networks)
+	}
+	if s.Network.RxBytes != 120 || s.Network.RxPackets != 12 || s.Network.TxBytes != 55 ||
+		s.Network.TxErrors != 3 || s.Network.TxDropped != 1 {
+		t.Fatalf("Expected the totals of all the interfaces, got %+v", s.Network)
+	}
+
+	// without a breakdown, the totals are those libcontainer read
+	update.Interfaces = nil
+	s = resourceStatsToAPI(update)
+	if s.Networks != nil || s.Network.RxBytes != 100 || s.Network.TxBytes != 50 {
+		t.Fatalf("Expected the network statistics of libcontainer, got %+v %+v", s.Network, s.Networks)
+	}
+}
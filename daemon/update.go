@@ -0,0 +1,102 @@
+package daemon
+
+import (
+	"fmt"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/engine"
+)
+
+// ContainerUpdate changes the resource limits of a container.  The limits of
+// a running container are changed in place by the exec driver; limits which
+// are left unset in the job keep their current value.
+func (daemon *Daemon) ContainerUpdate(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s CONTAINER", job.Name)
+	}
+	name := job.Args[0]
+	container := daemon.Get(name)
+	if container == nil {
+		return job.Errorf("No such container: %s", name)
+	}
+
+	container.Lock()
+	defer container.Unlock()
+
+	resources := execdriver.Resources{
+		Memory:     container.Config.Memory,
+		MemorySwap: container.Config.MemorySwap,
+		CpuShares:  container.Config.CpuShares,
+		Cpuset:     container.Config.Cpuset,
+	}
+	if job.EnvExists("Memory") {
+		resources.Memory = job.GetenvInt64("Memory")
+	}
+	if job.EnvExists("MemorySwap") {
+		resources.MemorySwap = job.GetenvInt64("MemorySwap")
+	}
+	if job.EnvExists("CpuShares") {
+		resources.CpuShares = job.GetenvInt64("CpuShares")
+	}
+	if job.EnvExists("Cpuset") {
+		resources.Cpuset = job.Getenv("Cpuset")
+	}
+
+	if err := daemon.verifyUpdate(container, &resources); err != nil {
+		return job.Error(err)
+	}
+
+	if container.Running {
+		if err := daemon.execDriver.Update(container.command, &resources); err != nil {
+			return job.Errorf("Cannot update container %s: %s", name, err)
+		}
+		container.command.Resources = &resources
+	}
+
+	container.Config.Memory = resources.Memory
+	container.Config.MemorySwap = resources.MemorySwap
+	container.Config.CpuShares = resources.CpuShares
+	container.Config.Cpuset = resources.Cpuset
+	if err := container.toDisk(); err != nil {
+		return job.Error(err)
+	}
+	container.LogEvent("update")
+	return engine.StatusOK
+}
+
+// verifyUpdate checks that the container can be updated to the resource
+// limits.  The limits of a running container are applied to its cgroups, so
+// the limits which are only applied when the container starts cannot be
+// changed while it runs.
+func (daemon *Daemon) verifyUpdate(container *Container, resources *execdriver.Resources) error {
+	if resources.Memory != 0 && resources.Memory < 4194304 {
+		return fmt.Errorf("Minimum memory limit allowed is 4MB")
+	}
+	if resources.Memory > 0 && !daemon.SystemConfig().MemoryLimit {
+		return fmt.Errorf("Your kernel does not support memory limit capabilities")
+	}
+	if resources.Memory > 0 && resources.MemorySwap > 0 && !daemon.SystemConfig().SwapLimit {
+		return fmt.Errorf("Your kernel does not support swap limit capabilities")
+	}
+	if resources.Memory > 0 && resources.MemorySwap > 0 && resources.MemorySwap < resources.Memory {
+		return fmt.Errorf("Minimum memoryswap limit should be larger than memory limit, see usage.")
+	}
+
+	if !container.Running {
+		return nil
+	}
+	config := container.Config
+	if resources.Memory == 0 && config.Memory != 0 {
+		return fmt.Errorf("The memory limit of a running container cannot be removed")
+	}
+	if resources.Memory == 0 && resources.MemorySwap != config.MemorySwap {
+		return fmt.Errorf("The swap limit of a running container cannot be changed without a memory limit")
+	}
+	if resources.CpuShares == 0 && config.CpuShares != 0 {
+		return fmt.Errorf("The CPU shares of a running container cannot be reset")
+	}
+	if resources.Cpuset == "" && config.Cpuset != "" {
+		return fmt.Errorf("The cpuset of a running container cannot be reset")
+	}
+	return nil
+}
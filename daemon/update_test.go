@@ -0,0 +1,49 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/docker/docker/daemon/execdriver"
+	"github.com/docker/docker/pkg/sysinfo"
+	"github.com/docker/docker/runconfig"
+)
+
+func TestVerifyUpdate(t *testing.T) {
+	daemon := &Daemon{sysInfo: &sysinfo.SysInfo{MemoryLimit: true, SwapLimit: true}}
+	config := &runconfig.Config{Memory: 67108864, MemorySwap: 134217728, CpuShares: 512, Cpuset: "0"}
+
+	for _, test := range []struct {
+		running   bool
+		resources execdriver.Resources
+		valid     bool
+	}{
+		{true, execdriver.Resources{Memory: 33554432, MemorySwap: 67108864, CpuShares: 256, Cpuset: "0,1"}, true},
+		{true, execdriver.Resources{Memory: 1048576, MemorySwap: 134217728, CpuShares: 512, Cpuset: "0"}, false},
+		{true, execdriver.Resources{Memory: 67108864, MemorySwap: 33554432, CpuShares: 512, Cpuset: "0"}, false},
+		// Limits which are only applied when the container starts
+		{true, execdriver.Resources{Memory: 0, MemorySwap: 134217728, CpuShares: 512, Cpuset: "0"}, false},
+		{true, execdriver.Resources{Memory: 67108864, MemorySwap: 134217728, CpuShares: 0, Cpuset: "0"}, false},
+		{true, execdriver.Resources{Memory: 67108864, MemorySwap: 134217728, CpuShares: 512, Cpuset: ""}, false},
+		{false, execdriver.Resources{Memory: 0, MemorySwap: 0, CpuShares: 0, Cpuset: ""}, true},
+	} {
+		container := &Container{State: NewState(), Config: config}
+		container.Running = test.running
+		err := daemon.verifyUpdate(container, &test.resources)
+		if test.valid && err != nil {
+			t.Fatalf("Expected %+v to be valid (running: %v), got %s", test.resources, test.running, err)
+		}
+		if !test.valid && err == nil {
+			t.Fatalf("Expected %+v to be refused (running: %v)", test.resources, test.running)
+		}
+	}
+
+	// The swap limit of a running container without a memory limit
+	container := &Container{State: NewState(), Config: &runconfig.Config{MemorySwap: -1}}
+	container.Running = true
+	if err := daemon.verifyUpdate(container, &execdriver.Resources{MemorySwap: -1, CpuShares: 512}); err != nil {
+		t.Fatalf("Expected an update keeping the swap limit to be valid, got %s", err)
+	}
+	if err := daemon.verifyUpdate(container, &execdriver.Resources{MemorySwap: 134217728}); err == nil {
+		t.Fatal("Expected a swap limit without a memory limit to be refused")
+	}
+}
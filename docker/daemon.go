@@ -119,6 +119,14 @@ func mainDaemon() {
 		b := &builder.BuilderJob{eng, d}
 		b.Install()
 
+		if daemonCfg.RegistryServe != "" {
+			go func() {
+				if err := eng.Job("registry_serve", daemonCfg.RegistryServe).Run(); err != nil {
+					log.Errorf("Error serving the registry API: %s", err)
+				}
+			}()
+		}
+
 		// after the daemon is done setting up we can tell the api to start
 		// accepting connections
 		if err := eng.Job("acceptconnections").Run(); err != nil {
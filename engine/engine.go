@@ -58,6 +58,7 @@ type Engine struct {
 	l          sync.RWMutex // lock for shutdown
 	shutdown   bool
 	onShutdown []func() // shutdown handlers
+	stats      *jobStats
 }
 
 func (eng *Engine) Register(name string, handler Handler) error {
@@ -82,6 +83,7 @@ func New() *Engine {
 		Stderr:   os.Stderr,
 		Stdin:    os.Stdin,
 		Logging:  true,
+		stats:    newJobStats(),
 	}
 	eng.Register("commands", func(job *Job) Status {
 		for _, name := range eng.commands() {
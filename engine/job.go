@@ -80,8 +80,10 @@ func (job *Job) Run() error {
 		job.Errorf("%s: command not found", job.Name)
 		job.status = 127
 	} else {
+		start := time.Now()
 		job.status = job.handler(job)
 		job.end = time.Now()
+		job.Eng.stats.record(job.Name, job.end.Sub(start))
 	}
 	if job.closeIO {
 		// Wait for all background tasks to complete
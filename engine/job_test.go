@@ -73,3 +73,20 @@ func TestJobStderrString(t *testing.T) {
 		t.Fatalf("Stderr last line:\nExpected: %v\nReceived: %v", expectedOutput, output)
 	}
 }
+
+func TestJobStats(t *testing.T) {
+	eng := New()
+	eng.Register("counted", func(job *Job) Status { return StatusOK })
+	for i := 0; i < 3; i++ {
+		if err := eng.Job("counted").Run(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stat, exists := eng.JobStats()["counted"]
+	if !exists {
+		t.Fatalf("Expected stats to be recorded for job counted")
+	}
+	if stat.Count != 3 {
+		t.Fatalf("Expected: count=%d\nReceived: count=%d", 3, stat.Count)
+	}
+}
@@ -26,6 +26,9 @@ func TestShutdownAfterRun(t *testing.T) {
 	if err := eng.Job("foo").Run(); err != nil {
 		t.Fatal(err)
 	}
+	if !called {
+		t.Fatal("foo job was not called")
+	}
 	eng.Shutdown()
 	if err := eng.Job("foo").Run(); err == nil {
 		t.Fatalf("%#v", *eng)
@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"sync"
+	"time"
+)
+
+// JobStat holds the number of completed runs of a job and the total time
+// spent in its handler.
+type JobStat struct {
+	Count    int64
+	Duration time.Duration
+}
+
+type jobStats struct {
+	sync.Mutex
+	jobs map[string]JobStat
+}
+
+func newJobStats() *jobStats {
+	return &jobStats{
+		jobs: make(map[string]JobStat),
+	}
+}
+
+func (s *jobStats) record(name string, d time.Duration) {
+	s.Lock()
+	stat := s.jobs[name]
+	stat.Count++
+	stat.Duration += d
+	s.jobs[name] = stat
+	s.Unlock()
+}
+
+// JobStats returns a copy of the run counts and cumulative durations of
+// every job which has completed on the engine, indexed by job name.
+func (eng *Engine) JobStats() map[string]JobStat {
+	eng.stats.Lock()
+	defer eng.stats.Unlock()
+	out := make(map[string]JobStat, len(eng.stats.jobs))
+	for name, stat := range eng.stats.jobs {
+		out[name] = stat
+	}
+	return out
+}
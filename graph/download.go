@@ -0,0 +1,158 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/pkg/tarsum"
+	"github.com/docker/docker/utils"
+)
+
+// maxDownloadAttempts is the number of times the download of a layer is
+// attempted before giving up.
+const maxDownloadAttempts = 5
+
+// layerFetcher requests the content of a layer starting at offset.  It
+// returns the content from offset along with the total size of the layer, or
+// a size of 0 when it is not known.
+type layerFetcher func(offset int64) (io.ReadCloser, int64, error)
+
+// downloadLayer stages the content of a layer in a temporary file under the
+// graph root.  When the connection drops partway through, the download is
+// resumed from the last byte received instead of being restarted.  The
+// returned file is positioned at its start and must be released with
+// removeLayerFile.
+func (s *TagStore) downloadLayer(out io.Writer, sf *utils.StreamFormatter, id string, fetch layerFetcher) (*os.File, int64, error) {
+	tmpFile, err := s.graph.newTempFile()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		offset int64
+		size   int64
+	)
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 {
+			out.Write(sf.FormatProgress(utils.TruncateID(id), fmt.Sprintf("Download interrupted, resuming [retries: %d]", attempt-1), nil))
+			time.Sleep(time.Duration(attempt-1) * 500 * time.Millisecond)
+		}
+
+		var n int64
+		n, size, err = fetchLayer(tmpFile, out, sf, id, offset, fetch)
+		offset += n
+		if err == nil && (size <= 0 || offset == size) {
+			break
+		}
+		if err == nil {
+			err = io.ErrUnexpectedEOF
+		}
+		if !isTransientError(err) || attempt == maxDownloadAttempts {
+			removeLayerFile(tmpFile)
+			return nil, 0, err
+		}
+		log.Debugf("Download of %s interrupted after %d bytes: %s", id, offset, err)
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		removeLayerFile(tmpFile)
+		return nil, 0, err
+	}
+	if _, err := tmpFile.Seek(0, 0); err != nil {
+		removeLayerFile(tmpFile)
+		return nil, 0, err
+	}
+	return tmpFile, offset, nil
+}
+
+// fetchLayer appends the content of a layer from offset to f and returns the
+// number of bytes written along with the total size of the layer.
+func fetchLayer(f *os.File, out io.Writer, sf *utils.StreamFormatter, id string, offset int64, fetch layerFetcher) (int64, int64, error) {
+	body, size, err := fetch(offset)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer body.Close()
+
+	progress := utils.ResumedProgressReader(ioutil.NopCloser(body), int(size), int(offset), out, sf, false, utils.TruncateID(id), "Downloading")
+	n, err := io.Copy(f, progress)
+	return n, size, err
+}
+
+// verifyLayer checks the content of a staged layer against its blob sum,
+// which is either a tarsum or a plain digest of the layer.  The file is
+// positioned back at its start afterwards.
+func verifyLayer(f *os.File, sumStr string) error {
+	var (
+		sum string
+		err error
+	)
+	if strings.HasPrefix(sumStr, "tarsum") {
+		sumType := strings.SplitN(sumStr, ":", 2)[0]
+		ts, err := tarsum.NewTarSumForLabel(f, true, sumType)
+		if err != nil {
+			return fmt.Errorf("unable to wrap image blob reader with TarSum: %s", err)
+		}
+		if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+			return err
+		}
+		sum = ts.Sum(nil)
+	} else if sum, err = utils.HashData(f); err != nil {
+		return err
+	}
+	if !strings.EqualFold(sum, sumStr) {
+		return fmt.Errorf("image verification failed: checksum mismatch - expected %q but got %q", sumStr, sum)
+	}
+	_, err = f.Seek(0, 0)
+	return err
+}
+
+// verifyV1Layer checks the content of a layer staged by a v1 pull against
+// the checksum of its image JSON.  Tarsum checksums of v1 registries sum the
+// image JSON along with the layer, as pushes compute them, other checksums
+// are plain digests of the layer.  The file is positioned back at its start
+// afterwards.
+func verifyV1Layer(f *os.File, checksum string, imgJSON []byte) error {
+	if !strings.HasPrefix(checksum, "tarsum") {
+		return verifyLayer(f, checksum)
+	}
+	ts, err := tarsum.NewTarSumForLabel(f, true, strings.SplitN(checksum, ":", 2)[0])
+	if err != nil {
+		return fmt.Errorf("unable to wrap image blob reader with TarSum: %s", err)
+	}
+	if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+		return err
+	}
+	if sum := ts.Sum(imgJSON); !strings.EqualFold(sum, checksum) {
+		return fmt.Errorf("image verification failed: checksum mismatch - expected %q but got %q", checksum, sum)
+	}
+	_, err = f.Seek(0, 0)
+	return err
+}
+
+// removeLayerFile removes a file created by downloadLayer along with its
+// temporary directory.
+func removeLayerFile(f *os.File) {
+	f.Close()
+	os.RemoveAll(filepath.Dir(f.Name()))
+}
+
+// isTransientError returns true if err was caused by a network failure after
+// which the download can be resumed.
+func isTransientError(err error) bool {
+	if uerr, ok := err.(*url.Error); ok {
+		err = uerr.Err
+	}
+	if _, ok := err.(net.Error); ok {
+		return true
+	}
+	return err == io.ErrUnexpectedEOF
+}
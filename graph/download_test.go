@@ -0,0 +1,136 @@
+package graph
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/tarsum"
+	"github.com/docker/docker/utils"
+)
+
+// flakyReader returns the first n bytes of its content and then fails as if
+// the connection had dropped.
+type flakyReader struct {
+	r io.Reader
+	n int
+}
+
+func (f *flakyReader) Read(p []byte) (int, error) {
+	if f.n <= 0 {
+		return 0, io.ErrUnexpectedEOF
+	}
+	if len(p) > f.n {
+		p = p[:f.n]
+	}
+	n, err := f.r.Read(p)
+	f.n -= n
+	return n, err
+}
+
+func TestDownloadLayerResumes(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	content := []byte(strings.Repeat("layer content ", 1000))
+	var offsets []int64
+	fetch := func(offset int64) (io.ReadCloser, int64, error) {
+		offsets = append(offsets, offset)
+		r := bytes.NewReader(content[offset:])
+		// Drop the connection halfway through the first request
+		if offset == 0 {
+			return ioutil.NopCloser(&flakyReader{r: r, n: len(content) / 2}), int64(len(content)), nil
+		}
+		return ioutil.NopCloser(r), int64(len(content)), nil
+	}
+
+	f, size, err := store.downloadLayer(ioutil.Discard, utils.NewStreamFormatter(false), testOfficialImageID, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer removeLayerFile(f)
+
+	if size != int64(len(content)) {
+		t.Fatalf("Expected a size of %d, got %d", len(content), size)
+	}
+	if len(offsets) != 2 || offsets[1] != int64(len(content)/2) {
+		t.Fatalf("Expected the download to resume from %d, got requests at %v", len(content)/2, offsets)
+	}
+	staged, err := ioutil.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(staged, content) {
+		t.Fatal("The staged layer does not match the downloaded content")
+	}
+
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := utils.HashData(bytes.NewReader(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyLayer(f, digest); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyLayer(f, "sha256:0000"); err == nil {
+		t.Fatal("Expected a checksum mismatch")
+	}
+}
+
+func TestVerifyV1Layer(t *testing.T) {
+	layer, err := archive.Generate("etc/motd", "hello")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := ioutil.TempFile("", "docker-test-v1-layer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	// Checksums are computed as v1 pushes do, along with the image JSON
+	imgJSON := []byte(`{"id":"` + testOfficialImageID + `"}`)
+	ts, err := tarsum.NewTarSum(io.TeeReader(layer, f), true, tarsum.Version0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+		t.Fatal(err)
+	}
+	checksum := ts.Sum(imgJSON)
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyV1Layer(f, checksum, imgJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyV1Layer(f, checksum, []byte(`{"id":"other"}`)); err == nil {
+		t.Fatal("Expected a checksum mismatch with another image JSON")
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := utils.HashData(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Seek(0, 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyV1Layer(f, digest, imgJSON); err != nil {
+		t.Fatal(err)
+	}
+}
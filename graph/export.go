@@ -12,6 +12,7 @@ import (
 	"github.com/docker/docker/pkg/archive"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/registry"
+	"github.com/docker/docker/utils"
 )
 
 // CmdImageExport exports all images with the given tag. All versions
@@ -19,10 +20,21 @@ import (
 // uncompressed tar ball.
 // name is the set of tags to export.
 // out is the writer where the images are written to.
+// format is the layout of the tar ball, either "legacy" (the default) or "v2".
 func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 	if len(job.Args) < 1 {
 		return job.Errorf("Usage: %s IMAGE [IMAGE...]\n", job.Name)
 	}
+	switch format := job.Getenv("format"); format {
+	case "", "legacy":
+	case "v2":
+		if err := s.exportV2(job.Args, job.Stdout); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	default:
+		return job.Errorf("Unknown save format: %s", format)
+	}
 	// get image json
 	tempdir, err := ioutil.TempDir("", "docker-export-")
 	if err != nil {
@@ -46,6 +58,9 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 		if rootRepo != nil {
 			// this is a base repo name, like 'busybox'
 			for tag, id := range rootRepo {
+				if utils.DigestReference(tag) {
+					continue
+				}
 				addKey(name, tag, id)
 				if err := s.exportImage(job.Eng, id, tempdir); err != nil {
 					return job.Error(err)
@@ -63,7 +78,7 @@ func (s *TagStore) CmdImageExport(job *engine.Job) engine.Status {
 
 				// check this length, because a lookup of a truncated has will not have a tag
 				// and will not need to be added to this map
-				if len(repoTag) > 0 {
+				if len(repoTag) > 0 && !utils.DigestReference(repoTag) {
 					addKey(repoName, repoTag, img.ID)
 				}
 				if err := s.exportImage(job.Eng, img.ID, tempdir); err != nil {
@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"syscall"
 	"time"
 
@@ -24,9 +25,19 @@ import (
 
 // A Graph is a store for versioned filesystem images and the relationship between them.
 type Graph struct {
-	Root    string
-	idIndex *truncindex.TruncIndex
-	driver  graphdriver.Driver
+	Root         string
+	idIndex      *truncindex.TruncIndex
+	driver       graphdriver.Driver
+	registerLock sync.Mutex // Serializes Register for the drivers not in concurrentRegisterDrivers
+}
+
+// concurrentRegisterDrivers lists the graph drivers which can create and
+// apply several layers at the same time.  Layers are registered one at a
+// time with the other drivers.
+var concurrentRegisterDrivers = map[string]bool{
+	"aufs":    true,
+	"overlay": true,
+	"vfs":     true,
 }
 
 // NewGraph instantiates a new graph at the given root path in the filesystem.
@@ -138,8 +149,20 @@ func (graph *Graph) Create(layerData archive.ArchiveReader, containerID, contain
 	return img, nil
 }
 
+// SetBuildInfo records the Dockerfile instruction which built img and the
+// checksum of the context files the instruction used in its metadata.
+func (graph *Graph) SetBuildInfo(img *image.Image, instruction, checksum string) error {
+	img.BuildInstruction = instruction
+	img.ContextChecksum = checksum
+	return img.SaveJSON(graph.ImageRoot(img.ID))
+}
+
 // Register imports a pre-existing image into the graph.
 func (graph *Graph) Register(img *image.Image, layerData archive.ArchiveReader) (err error) {
+	if !concurrentRegisterDrivers[graph.driver.String()] {
+		graph.registerLock.Lock()
+		defer graph.registerLock.Unlock()
+	}
 	defer func() {
 		// If any error occurs, remove the new dir from the driver.
 		// Don't check for errors since the dir might not have been created.
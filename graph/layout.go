@@ -0,0 +1,340 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
+)
+
+// In the v2 layout, a saved archive holds an index of the saved references
+// along with content-addressed blobs:
+//
+//	index.json
+//	blobs/sha256/<hex>                 signed manifest of a reference
+//	blobs/tarsum.v1+sha256/<hex>       layer referenced by a manifest
+//
+// Manifests are saved as they were pulled or pushed, so that a loaded image
+// keeps its digest and signatures.
+const savedIndexFile = "index.json"
+
+// savedIndex is the content of the index of an archive in the v2 layout.
+type savedIndex struct {
+	SchemaVersion int        `json:"schemaVersion"`
+	Manifests     []savedRef `json:"manifests"`
+}
+
+// savedRef is a reference saved in the v2 layout, along with the digest of
+// its manifest.
+type savedRef struct {
+	Name   string `json:"name"`
+	Tag    string `json:"tag"`
+	Digest string `json:"digest"`
+}
+
+var validBlobDigest = regexp.MustCompile(`^[a-z0-9.+-]+:[a-fA-F0-9]+$`)
+
+// blobPath returns the path of the blob with digest under root.
+func blobPath(root, digest string) (string, error) {
+	if !validBlobDigest.MatchString(digest) {
+		return "", fmt.Errorf("Invalid blob digest: %s", digest)
+	}
+	parts := strings.SplitN(digest, ":", 2)
+	return filepath.Join(root, "blobs", parts[0], parts[1]), nil
+}
+
+func writeBlob(root, digest string, content io.Reader) error {
+	p, err := blobPath(root, digest)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = io.Copy(f, content)
+	return err
+}
+
+// manifestPayload returns the content of a signed manifest without checking
+// its signatures.
+func manifestPayload(manifestBytes []byte) (*registry.ManifestData, error) {
+	sig, err := libtrust.ParsePrettySignature(manifestBytes, "signatures")
+	if err != nil {
+		return nil, fmt.Errorf("error parsing payload: %s", err)
+	}
+	payload, err := sig.Payload()
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving payload: %s", err)
+	}
+	var manifest registry.ManifestData
+	if err := json.Unmarshal(payload, &manifest); err != nil {
+		return nil, fmt.Errorf("error unmarshalling manifest: %s", err)
+	}
+	return &manifest, nil
+}
+
+// savedRefs returns the references saved for name, which is either a
+// repository, whose tags are all saved, or a single reference.
+func (s *TagStore) savedRefs(name string) ([]savedRef, error) {
+	repoName, ref := parsers.ParseRepositoryTag(name)
+	repo, err := s.Get(repoName)
+	if err != nil {
+		return nil, err
+	}
+	if repo == nil {
+		return nil, fmt.Errorf("Cannot save %s in the v2 layout: only the references of a repository have a manifest", name)
+	}
+	repoName = registry.NormalizeLocalName(repoName)
+	if ref != "" {
+		if _, exists := repo[ref]; !exists {
+			return nil, fmt.Errorf("No such image: %s", name)
+		}
+		return []savedRef{{Name: repoName, Tag: ref}}, nil
+	}
+	var refs []savedRef
+	for tag := range repo {
+		if !utils.DigestReference(tag) {
+			refs = append(refs, savedRef{Name: repoName, Tag: tag})
+		}
+	}
+	sort.Sort(savedRefsByTag(refs))
+	return refs, nil
+}
+
+type savedRefsByTag []savedRef
+
+func (r savedRefsByTag) Len() int           { return len(r) }
+func (r savedRefsByTag) Less(i, j int) bool { return r[i].Tag < r[j].Tag }
+func (r savedRefsByTag) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+
+// exportV2 writes the images of names to out as an archive in the v2
+// layout.
+func (s *TagStore) exportV2(names []string, out io.Writer) error {
+	tempdir, err := ioutil.TempDir("", "docker-export-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempdir)
+
+	index := savedIndex{SchemaVersion: 1, Manifests: []savedRef{}}
+	for _, name := range names {
+		refs, err := s.savedRefs(name)
+		if err != nil {
+			return err
+		}
+		for _, ref := range refs {
+			log.Debugf("Serializing %s", utils.ImageReference(ref.Name, ref.Tag))
+			if ref.Digest, err = s.exportManifest(tempdir, ref.Name, ref.Tag); err != nil {
+				return err
+			}
+			index.Manifests = append(index.Manifests, ref)
+		}
+	}
+	indexJSON, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(tempdir, savedIndexFile), indexJSON, 0644); err != nil {
+		return err
+	}
+
+	fs, err := archive.Tar(tempdir, archive.Uncompressed)
+	if err != nil {
+		return err
+	}
+	defer fs.Close()
+	_, err = io.Copy(out, fs)
+	return err
+}
+
+// exportManifest saves the manifest of a reference and its layers under
+// root, and returns the digest of the manifest.  The manifest the reference
+// was pulled or pushed with is saved if there is one, otherwise a manifest
+// signed with the daemon's key is generated.
+func (s *TagStore) exportManifest(root, localName, ref string) (string, error) {
+	manifestBytes, err := s.getManifest(localName, ref)
+	if err != nil {
+		return "", err
+	}
+	if manifestBytes == nil {
+		if utils.DigestReference(ref) {
+			return "", fmt.Errorf("No manifest for %s", utils.ImageReference(localName, ref))
+		}
+		repoInfo, err := registry.ParseRepositoryInfo(localName)
+		if err != nil {
+			return "", err
+		}
+		if manifestBytes, err = s.signedManifest(localName, repoInfo.RemoteName, ref); err != nil {
+			return "", err
+		}
+	}
+	manifest, err := manifestPayload(manifestBytes)
+	if err != nil {
+		return "", err
+	}
+	if err := checkValidManifest(manifest); err != nil {
+		return "", err
+	}
+	digest, err := registry.ManifestDigest(manifestBytes)
+	if err != nil {
+		return "", err
+	}
+	if err := writeBlob(root, digest, bytes.NewReader(manifestBytes)); err != nil {
+		return "", err
+	}
+
+	for i, fsLayer := range manifest.FSLayers {
+		p, err := blobPath(root, fsLayer.BlobSum)
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(p); err == nil {
+			continue
+		}
+		img, err := image.NewImgJSON([]byte(manifest.History[i].V1Compatibility))
+		if err != nil {
+			return "", fmt.Errorf("failed to parse json: %s", err)
+		}
+		layer, err := s.graph.Get(img.ID)
+		if err != nil {
+			return "", err
+		}
+		if layer == nil {
+			return "", fmt.Errorf("No such layer: %s", img.ID)
+		}
+		arch, err := layer.TarLayer()
+		if err != nil {
+			return "", err
+		}
+		err = writeBlob(root, fsLayer.BlobSum, arch)
+		arch.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return digest, nil
+}
+
+// loadV2 loads the images of an archive in the v2 layout extracted at root.
+// The manifests and layers are verified against their digests, and the
+// manifests are checked against the trust policy as if they were pulled.
+func (s *TagStore) loadV2(eng *engine.Engine, root string) error {
+	indexJSON, err := ioutil.ReadFile(filepath.Join(root, savedIndexFile))
+	if err != nil {
+		return err
+	}
+	var index savedIndex
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		return fmt.Errorf("Invalid %s: %s", savedIndexFile, err)
+	}
+	if index.SchemaVersion != 1 {
+		return fmt.Errorf("Unsupported %s schema version: %d", savedIndexFile, index.SchemaVersion)
+	}
+
+	for _, ref := range index.Manifests {
+		if err := s.loadManifestRef(eng, root, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *TagStore) loadManifestRef(eng *engine.Engine, root string, ref savedRef) error {
+	name := utils.ImageReference(ref.Name, ref.Tag)
+	log.Debugf("Loading %s", name)
+
+	p, err := blobPath(root, ref.Digest)
+	if err != nil {
+		return err
+	}
+	manifestBytes, err := ioutil.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	digest, err := registry.ManifestDigest(manifestBytes)
+	if err != nil {
+		return err
+	}
+	if !strings.EqualFold(digest, ref.Digest) {
+		return fmt.Errorf("Manifest of %s does not match its digest: expected %s but got %s", name, ref.Digest, digest)
+	}
+
+	manifest, verified, err := s.loadManifest(eng, manifestBytes)
+	if err != nil {
+		return fmt.Errorf("error verifying manifest of %s: %s", name, err)
+	}
+	if err := checkValidManifest(manifest); err != nil {
+		return err
+	}
+	repoInfo, err := registry.ParseRepositoryInfo(ref.Name)
+	if err != nil {
+		return err
+	}
+	if err := checkTrustPolicy(eng, repoInfo, manifest, verified); err != nil {
+		return err
+	}
+
+	var topID string
+	for i := len(manifest.FSLayers) - 1; i >= 0; i-- {
+		img, err := image.NewImgJSON([]byte(manifest.History[i].V1Compatibility))
+		if err != nil {
+			return fmt.Errorf("failed to parse json: %s", err)
+		}
+		if err := utils.ValidateID(img.ID); err != nil {
+			return err
+		}
+		topID = img.ID
+		if s.graph.Exists(img.ID) {
+			continue
+		}
+		if err := s.loadBlobLayer(root, img, manifest.FSLayers[i].BlobSum); err != nil {
+			return err
+		}
+	}
+
+	if utils.DigestReference(ref.Tag) {
+		err = s.SetDigest(repoInfo.LocalName, ref.Tag, topID)
+	} else {
+		err = s.Set(repoInfo.LocalName, ref.Tag, topID, true)
+	}
+	if err != nil {
+		return err
+	}
+	return s.setManifest(repoInfo.LocalName, ref.Tag, manifestBytes)
+}
+
+func (s *TagStore) loadBlobLayer(root string, img *image.Image, blobSum string) error {
+	p, err := blobPath(root, blobSum)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := verifyLayer(f, blobSum); err != nil {
+		return fmt.Errorf("Layer %s: %s", img.ID, err)
+	}
+	return s.graph.Register(img, f)
+}
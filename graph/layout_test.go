@@ -0,0 +1,160 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/archive"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/trust"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
+)
+
+func mkLayoutTestStore(root string, t *testing.T) (*TagStore, *engine.Engine) {
+	store := mkTestTagStore(root, t)
+	eng := engine.New()
+	trustStore, err := trust.NewTrustStore(filepath.Join(root, "trust"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trustStore.Install(eng); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Install(eng); err != nil {
+		t.Fatal(err)
+	}
+	if store.trustKey, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+		t.Fatal(err)
+	}
+	return store, eng
+}
+
+func TestSaveLoadV2(t *testing.T) {
+	srcDir, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(srcDir)
+	src, srcEng := mkLayoutTestStore(srcDir, t)
+	defer src.graph.driver.Cleanup()
+
+	// A pulled manifest is saved as is, so that its digest is kept
+	pulled, err := src.signedManifest(testPrivateImageName, testPrivateImageName, DEFAULTTAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := src.setManifest(testPrivateImageName, DEFAULTTAG, pulled); err != nil {
+		t.Fatal(err)
+	}
+	pulledDigest, err := registry.ManifestDigest(pulled)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	job := srcEng.Job("image_export", testOfficialImageName, testPrivateImageName+":"+DEFAULTTAG)
+	job.Setenv("format", "v2")
+	saved := &bytes.Buffer{}
+	job.Stdout.Add(saved)
+	if err := job.Run(); err != nil {
+		t.Fatal(err)
+	}
+	if err := srcEng.Job("image_export", testOfficialImageID).Run(); err != nil {
+		t.Fatal(err)
+	}
+	job = srcEng.Job("image_export", testOfficialImageID)
+	job.Setenv("format", "v2")
+	if err := job.Run(); err == nil {
+		t.Fatal("Expected an error when saving an image ID in the v2 layout")
+	}
+
+	extracted, err := ioutil.TempDir("", "docker-layout-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(extracted)
+	if err := archive.Untar(saved, extracted, nil); err != nil {
+		t.Fatal(err)
+	}
+	indexJSON, err := ioutil.ReadFile(filepath.Join(extracted, savedIndexFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var index savedIndex
+	if err := json.Unmarshal(indexJSON, &index); err != nil {
+		t.Fatal(err)
+	}
+	if len(index.Manifests) != 2 {
+		t.Fatalf("Expected 2 saved references, got %d", len(index.Manifests))
+	}
+	if ref := index.Manifests[1]; ref.Name != testPrivateImageName || ref.Tag != DEFAULTTAG || ref.Digest != pulledDigest {
+		t.Fatalf("Unexpected saved reference: %+v", ref)
+	}
+
+	dstDir, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dstDir)
+	dst, dstEng := mkLayoutTestStore(dstDir, t)
+	defer dst.graph.driver.Cleanup()
+	if err := dst.DeleteAll(testPrivateImageID); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.graph.Delete(testPrivateImageID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := dst.loadV2(dstEng, extracted); err != nil {
+		t.Fatal(err)
+	}
+	if img, err := dst.GetImage(testPrivateImageName, DEFAULTTAG); err != nil {
+		t.Fatal(err)
+	} else if img == nil || img.ID != testPrivateImageID {
+		t.Fatalf("Expected %s to be loaded", testPrivateImageName)
+	}
+	loaded, err := dst.getManifest(testPrivateImageName, DEFAULTTAG)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if digest, err := registry.ManifestDigest(loaded); err != nil {
+		t.Fatal(err)
+	} else if digest != pulledDigest {
+		t.Fatalf("Expected the digest %s to be kept, got %s", pulledDigest, digest)
+	}
+
+	// A layer which does not match its blob sum is refused
+	if err := dst.DeleteAll(testPrivateImageID); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.graph.Delete(testPrivateImageID); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := manifestPayload(loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p, err := blobPath(extracted, manifest.FSLayers[0].BlobSum)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered, err := fakeTar()
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(p, append(content, make([]byte, 1024)...), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := dst.loadV2(dstEng, extracted); err == nil {
+		t.Fatal("Expected an error for a tampered layer")
+	}
+}
@@ -1,7 +1,6 @@
 package graph
 
 import (
-	"fmt"
 	"log"
 	"path"
 	"strings"
@@ -9,9 +8,13 @@ import (
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/parsers/filters"
+	"github.com/docker/docker/utils"
 )
 
-var acceptedImageFilterTags = map[string]struct{}{"dangling": {}}
+var acceptedImageFilterTags = map[string]struct{}{
+	"dangling": {},
+	"label":    {},
+}
 
 func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	var (
@@ -54,16 +57,25 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 				continue
 			}
 		}
-		for tag, id := range repository {
+		for ref, id := range repository {
 			image, err := s.graph.Get(id)
 			if err != nil {
-				log.Printf("Warning: couldn't load %s from %s/%s: %s", id, name, tag, err)
+				log.Printf("Warning: couldn't load %s from %s: %s", id, utils.ImageReference(name, ref), err)
+				continue
+			}
+			if !imageFilters.MatchKVList("label", imageLabels(image)) {
+				// Keep the image out of the untagged images as well
+				delete(allImages, id)
 				continue
 			}
 
+			key := "RepoTags"
+			if utils.DigestReference(ref) {
+				key = "RepoDigests"
+			}
 			if out, exists := lookup[id]; exists {
 				if filt_tagged {
-					out.SetList("RepoTags", append(out.GetList("RepoTags"), fmt.Sprintf("%s:%s", name, tag)))
+					out.SetList(key, append(out.GetList(key), utils.ImageReference(name, ref)))
 				}
 			} else {
 				// get the boolean list for if only the untagged images are requested
@@ -71,11 +83,14 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 				if filt_tagged {
 					out := &engine.Env{}
 					out.SetJson("ParentId", image.Parent)
-					out.SetList("RepoTags", []string{fmt.Sprintf("%s:%s", name, tag)})
+					out.SetList("RepoTags", []string{})
+					out.SetList("RepoDigests", []string{})
+					out.SetList(key, []string{utils.ImageReference(name, ref)})
 					out.SetJson("Id", image.ID)
 					out.SetInt64("Created", image.Created.Unix())
 					out.SetInt64("Size", image.Size)
 					out.SetInt64("VirtualSize", image.GetParentsSize(0)+image.Size)
+					out.SetJson("Labels", imageLabels(image))
 					lookup[id] = out
 				}
 			}
@@ -84,6 +99,13 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	}
 	s.Unlock()
 
+	// Images which are only referenced by digest have no tag
+	for _, out := range lookup {
+		if len(out.GetList("RepoTags")) == 0 {
+			out.SetList("RepoTags", []string{"<none>:<none>"})
+		}
+	}
+
 	outs := engine.NewTable("Created", len(lookup))
 	for _, value := range lookup {
 		outs.Add(value)
@@ -92,13 +114,18 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	// Display images which aren't part of a repository/tag
 	if job.Getenv("filter") == "" {
 		for _, image := range allImages {
+			if !imageFilters.MatchKVList("label", imageLabels(image)) {
+				continue
+			}
 			out := &engine.Env{}
 			out.SetJson("ParentId", image.Parent)
 			out.SetList("RepoTags", []string{"<none>:<none>"})
+			out.SetList("RepoDigests", []string{"<none>@<none>"})
 			out.SetJson("Id", image.ID)
 			out.SetInt64("Created", image.Created.Unix())
 			out.SetInt64("Size", image.Size)
 			out.SetInt64("VirtualSize", image.GetParentsSize(0)+image.Size)
+			out.SetJson("Labels", imageLabels(image))
 			outs.Add(out)
 		}
 	}
@@ -109,3 +136,11 @@ func (s *TagStore) CmdImages(job *engine.Job) engine.Status {
 	}
 	return engine.StatusOK
 }
+
+// imageLabels returns the labels set in the configuration of img.
+func imageLabels(img *image.Image) map[string]string {
+	if img.Config == nil {
+		return nil
+	}
+	return img.Config.Labels
+}
@@ -46,6 +46,13 @@ func (s *TagStore) CmdLoad(job *engine.Job) engine.Status {
 		return job.Error(err)
 	}
 
+	if _, err := os.Stat(path.Join(repoDir, savedIndexFile)); err == nil {
+		if err := s.loadV2(job.Eng, repoDir); err != nil {
+			return job.Error(err)
+		}
+		return engine.StatusOK
+	}
+
 	dirs, err := ioutil.ReadDir(repoDir)
 	if err != nil {
 		return job.Error(err)
@@ -6,12 +6,16 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/engine"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/pkg/tarsum"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/runconfig"
+	"github.com/docker/docker/utils"
 	"github.com/docker/libtrust"
 )
 
@@ -140,27 +144,10 @@ func (s *TagStore) loadManifest(eng *engine.Engine, manifestBytes []byte) (*regi
 
 	var verified bool
 	for _, key := range keys {
-		job := eng.Job("trust_key_check")
-		b, err := key.MarshalJSON()
+		result, err := checkKey(eng, trustNamespace(manifest.Name), key)
 		if err != nil {
-			return nil, false, fmt.Errorf("error marshalling public key: %s", err)
+			return nil, false, err
 		}
-		namespace := manifest.Name
-		if namespace[0] != '/' {
-			namespace = "/" + namespace
-		}
-		stdoutBuffer := bytes.NewBuffer(nil)
-
-		job.Args = append(job.Args, namespace)
-		job.Setenv("PublicKey", string(b))
-		// Check key has read/write permission (0x03)
-		job.SetenvInt("Permission", 0x03)
-		job.Stdout.Add(stdoutBuffer)
-		if err = job.Run(); err != nil {
-			return nil, false, fmt.Errorf("error running key check: %s", err)
-		}
-		result := engine.Tail(stdoutBuffer, 1)
-		log.Debugf("Key check result: %q", result)
 		if result == "verified" {
 			verified = true
 		}
@@ -169,6 +156,146 @@ func (s *TagStore) loadManifest(eng *engine.Engine, manifestBytes []byte) (*regi
 	return &manifest, verified, nil
 }
 
+// checkKey returns the result of checking a signing key against the trust
+// graph for namespace: "verified", "not verified", "expired" or "no graph".
+func checkKey(eng *engine.Engine, namespace string, key libtrust.PublicKey) (string, error) {
+	job := eng.Job("trust_key_check")
+	b, err := key.MarshalJSON()
+	if err != nil {
+		return "", fmt.Errorf("error marshalling public key: %s", err)
+	}
+	stdoutBuffer := bytes.NewBuffer(nil)
+
+	job.Args = append(job.Args, namespace)
+	job.Setenv("PublicKey", string(b))
+	// Check key has read/write permission (0x03)
+	job.SetenvInt("Permission", 0x03)
+	job.Stdout.Add(stdoutBuffer)
+	if err = job.Run(); err != nil {
+		return "", fmt.Errorf("error running key check: %s", err)
+	}
+	result := engine.Tail(stdoutBuffer, 1)
+	log.Debugf("Key check result: %q", result)
+	return result, nil
+}
+
+// trustNamespace returns the namespace of the trust graph and policy which
+// covers the repository with the given remote name.
+func trustNamespace(remoteName string) string {
+	if strings.HasPrefix(remoteName, "/") {
+		return remoteName
+	}
+	return "/" + remoteName
+}
+
+// trustPolicyEnforced returns true if the trust policy of the daemon requires
+// the images of namespace to be signed by an allowed key.
+func trustPolicyEnforced(eng *engine.Engine, namespace string) (bool, error) {
+	stdoutBuffer := bytes.NewBuffer(nil)
+	job := eng.Job("trust_policy_check", namespace)
+	job.Stdout.Add(stdoutBuffer)
+	if err := job.Run(); err != nil {
+		return false, fmt.Errorf("error running trust policy check: %s", err)
+	}
+	return engine.Tail(stdoutBuffer, 1) == "enforced", nil
+}
+
+// checkTrustPolicy refuses a manifest which is not signed by a key the trust
+// policy allows for the repository it was pulled from.
+func checkTrustPolicy(eng *engine.Engine, repoInfo *registry.RepositoryInfo, manifest *registry.ManifestData, verified bool) error {
+	enforced, err := trustPolicyEnforced(eng, trustNamespace(repoInfo.RemoteName))
+	if err != nil || !enforced {
+		return err
+	}
+	if manifest.Name != repoInfo.RemoteName {
+		return fmt.Errorf("the manifest of %s is for the repository %s", repoInfo.CanonicalName, manifest.Name)
+	}
+	if !verified {
+		return fmt.Errorf("the manifest of %s is not signed by a key allowed by the trust policy", repoInfo.CanonicalName)
+	}
+	return nil
+}
+
+// CheckTrustPolicy checks that the image a repository reference refers to
+// was pulled with a manifest signed by a key the trust policy of the daemon
+// allows.  An image ID is checked through the repository references of its
+// image: it is refused if one of them is covered by the policy and none of
+// those was pulled with an allowed signed manifest.
+func (s *TagStore) CheckTrustPolicy(eng *engine.Engine, name string) error {
+	repoName, ref := parsers.ParseRepositoryTag(name)
+	if ref == "" {
+		ref = DEFAULTTAG
+	}
+	img, err := s.GetImage(repoName, ref)
+	if err != nil {
+		return err
+	}
+	if img != nil {
+		_, err := s.checkReferenceTrust(eng, repoName, ref, img.ID)
+		return err
+	}
+
+	if img, err = s.LookupImage(name); err != nil || img == nil {
+		return err
+	}
+	var refused error
+	for _, reference := range s.ByID()[img.ID] {
+		repoName, ref := parsers.ParseRepositoryTag(reference)
+		enforced, err := s.checkReferenceTrust(eng, repoName, ref, img.ID)
+		if err == nil && enforced {
+			return nil
+		}
+		if err != nil && refused == nil {
+			refused = err
+		}
+	}
+	if refused != nil {
+		return fmt.Errorf("%s is refused by the trust policy: %s", name, refused)
+	}
+	return nil
+}
+
+// checkReferenceTrust checks the trust policy for the reference ref of
+// repoName, which refers to the image imgID.  It returns whether the policy
+// covers the repository.
+func (s *TagStore) checkReferenceTrust(eng *engine.Engine, repoName, ref, imgID string) (bool, error) {
+	name := utils.ImageReference(repoName, ref)
+	repoInfo, err := registry.ParseRepositoryInfo(repoName)
+	if err != nil {
+		return false, err
+	}
+	if enforced, err := trustPolicyEnforced(eng, trustNamespace(repoInfo.RemoteName)); err != nil || !enforced {
+		return false, err
+	}
+
+	manifestBytes, err := s.getManifest(repoInfo.LocalName, ref)
+	if err != nil {
+		return true, err
+	}
+	if manifestBytes == nil {
+		return true, fmt.Errorf("%s was not pulled with a signed manifest, which the trust policy requires", name)
+	}
+	manifest, verified, err := s.loadManifest(eng, manifestBytes)
+	if err != nil {
+		return true, fmt.Errorf("error verifying manifest: %s", err)
+	}
+	if err := checkTrustPolicy(eng, repoInfo, manifest, verified); err != nil {
+		return true, err
+	}
+	if err := checkValidManifest(manifest); err != nil {
+		return true, err
+	}
+	// The reference may have been moved to another image since the pull
+	top, err := image.NewImgJSON([]byte(manifest.History[0].V1Compatibility))
+	if err != nil {
+		return true, err
+	}
+	if top.ID != imgID {
+		return true, fmt.Errorf("%s no longer refers to the image of its signed manifest", name)
+	}
+	return true, nil
+}
+
 func checkValidManifest(manifest *registry.ManifestData) error {
 	if len(manifest.FSLayers) != len(manifest.History) {
 		return fmt.Errorf("length of history not equal to number of layers")
@@ -3,11 +3,15 @@ package graph
 import (
 	"encoding/json"
 	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/docker/docker/engine"
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/registry"
+	"github.com/docker/docker/trust"
 	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
 )
 
 const (
@@ -80,3 +84,85 @@ func TestManifestTarsumCache(t *testing.T) {
 		t.Fatalf("Unexpected json value\nExpected:\n%s\nActual:\n%s", v1compat, manifest.History[0].V1Compatibility)
 	}
 }
+
+func TestCheckTrustPolicy(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	eng := engine.New()
+	trustStore, err := trust.NewTrustStore(filepath.Join(tmp, "trust"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trustStore.Install(eng); err != nil {
+		t.Fatal(err)
+	}
+	if store.trustKey, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+		t.Fatal(err)
+	}
+	otherKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("myteam/app", "1.0", testOfficialImageID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.CheckTrustPolicy(eng, "myteam/app:1.0"); err != nil {
+		t.Fatalf("Expected no enforcement without a policy, got %s", err)
+	}
+
+	trustStore.SetPolicy(trust.Policy{"/myteam": {store.trustKey.KeyID()}})
+	if err := store.CheckTrustPolicy(eng, "myteam/app:1.0"); err == nil {
+		t.Fatal("Expected an image without a signed manifest to be refused")
+	}
+	if err := store.CheckTrustPolicy(eng, testOfficialImageID); err == nil {
+		t.Fatal("Expected the ID of an image without a signed manifest to be refused")
+	}
+	if err := store.CheckTrustPolicy(eng, utils.TruncateID(testOfficialImageID)); err == nil {
+		t.Fatal("Expected the short ID of an image without a signed manifest to be refused")
+	}
+
+	manifestBytes, err := store.signedManifest("myteam/app", "myteam/app", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.setManifest("myteam/app", "1.0", manifestBytes); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.CheckTrustPolicy(eng, "myteam/app:1.0"); err != nil {
+		t.Fatalf("Expected the image signed by an allowed key to be accepted, got %s", err)
+	}
+	if err := store.CheckTrustPolicy(eng, testOfficialImageID); err != nil {
+		t.Fatalf("Expected the ID of the image signed by an allowed key to be accepted, got %s", err)
+	}
+
+	trustStore.SetPolicy(trust.Policy{"/myteam": {otherKey.KeyID()}})
+	if err := store.CheckTrustPolicy(eng, "myteam/app:1.0"); err == nil {
+		t.Fatal("Expected the image signed by a key which is not allowed to be refused")
+	}
+
+	if err := store.CheckTrustPolicy(eng, testOfficialImageID); err == nil {
+		t.Fatal("Expected the ID of the image signed by a key which is not allowed to be refused")
+	}
+
+	trustStore.SetPolicy(trust.Policy{"/other": {}})
+	if err := store.CheckTrustPolicy(eng, "myteam/app:1.0"); err != nil {
+		t.Fatalf("Expected no enforcement outside of the policy namespaces, got %s", err)
+	}
+	if err := store.CheckTrustPolicy(eng, testOfficialImageID); err != nil {
+		t.Fatalf("Expected no enforcement of image IDs outside of the policy namespaces, got %s", err)
+	}
+
+	if _, err := store.Delete("myteam/app", "1.0"); err != nil {
+		t.Fatal(err)
+	}
+	if manifestBytes, err := store.getManifest("myteam/app", "1.0"); err != nil || manifestBytes != nil {
+		t.Fatalf("Expected the manifest to be removed with its tag, got %q (%v)", manifestBytes, err)
+	}
+}
@@ -0,0 +1,157 @@
+package graph
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/utils"
+)
+
+// DefaultMaxConcurrentDownloads is the number of layers downloaded at the
+// same time unless the daemon sets another limit.
+const DefaultMaxConcurrentDownloads = 3
+
+// SetMaxConcurrentDownloads sets the number of layers downloaded at the same
+// time by all the pulls.  It must be called before any pull starts.
+func (store *TagStore) SetMaxConcurrentDownloads(n int) error {
+	if n < 1 {
+		return fmt.Errorf("Invalid number of concurrent downloads: %d", n)
+	}
+	store.downloadSlots = make(chan struct{}, n)
+	return nil
+}
+
+// pullLayer is a layer of an image to pull.
+type pullLayer struct {
+	id string
+	// download stages the content of the layer and returns its image along
+	// with the staged file and its size
+	download func() (*image.Image, *os.File, int64, error)
+}
+
+// pullStatuses are the statuses reported for the layers a pull registers
+// and for those which already exist.
+type pullStatuses struct {
+	complete string
+	exists   string
+}
+
+var (
+	v2PullStatuses = pullStatuses{"Pull complete", "Already exists"}
+	// v1 pulls have always reported each layer as downloaded
+	v1PullStatuses = pullStatuses{"Download complete", "Download complete"}
+)
+
+// stagedLayer is the outcome of the download of a layer.
+type stagedLayer struct {
+	img  *image.Image
+	file *os.File
+	size int64
+	err  error
+}
+
+// stageLayer downloads a layer once a download slot is free.
+func (s *TagStore) stageLayer(layer pullLayer) stagedLayer {
+	s.downloadSlots <- struct{}{}
+	defer func() { <-s.downloadSlots }()
+	img, f, size, err := layer.download()
+	return stagedLayer{img, f, size, err}
+}
+
+// pullLayers pulls the layers of an image, listed from the base layer up,
+// and returns true if any of them was downloaded, reporting each layer with
+// statuses once it is present.  When parallel is set, the layers are
+// downloaded concurrently, and each one is registered into the graph as soon
+// as it is downloaded and its parent is registered.
+// Otherwise each layer is downloaded once its parent is registered.
+func (s *TagStore) pullLayers(out io.Writer, sf *utils.StreamFormatter, layers []pullLayer, parallel bool, statuses pullStatuses) (bool, error) {
+	var (
+		exists  = make([]bool, len(layers))
+		waits   = make([]chan struct{}, len(layers))
+		staged  = make([]chan stagedLayer, len(layers))
+		owned   []string
+		pending []chan stagedLayer
+	)
+	defer func() {
+		// Discard the layers still being downloaded after a failure
+		for _, c := range pending {
+			go func(c chan stagedLayer) {
+				if l := <-c; l.file != nil {
+					removeLayerFile(l.file)
+				}
+			}(c)
+		}
+		for _, id := range owned {
+			s.poolRemove("pull", "layer:"+id)
+		}
+	}()
+
+	// Layers are claimed from the base up, so that pulls of images which
+	// share layers wait for each other in the same order
+	for i, layer := range layers {
+		if s.graph.Exists(layer.id) {
+			exists[i] = true
+			continue
+		}
+		// ensure no two downloads of the same layer happen at the same time
+		if c, err := s.poolAdd("pull", "layer:"+layer.id); err != nil {
+			log.Debugf("Layer (id: %s) pull is already running, waiting: %v", layer.id, err)
+			out.Write(sf.FormatProgress(utils.TruncateID(layer.id), "Layer already being pulled by another client. Waiting.", nil))
+			waits[i] = c
+			continue
+		}
+		owned = append(owned, layer.id)
+		out.Write(sf.FormatProgress(utils.TruncateID(layer.id), "Pulling fs layer", nil))
+		if parallel {
+			staged[i] = make(chan stagedLayer, 1)
+			pending = append(pending, staged[i])
+			go func(layer pullLayer, c chan stagedLayer) {
+				c <- s.stageLayer(layer)
+			}(layer, staged[i])
+		}
+	}
+
+	var layersDownloaded bool
+	for i, layer := range layers {
+		var l stagedLayer
+		switch {
+		case exists[i]:
+			out.Write(sf.FormatProgress(utils.TruncateID(layer.id), statuses.exists, nil))
+			continue
+		case waits[i] != nil:
+			<-waits[i]
+			if s.graph.Exists(layer.id) {
+				out.Write(sf.FormatProgress(utils.TruncateID(layer.id), statuses.exists, nil))
+				continue
+			}
+			// The other pull failed, download the layer here
+			l = s.stageLayer(layer)
+		case staged[i] != nil:
+			l = <-staged[i]
+			pending = pending[1:]
+		default:
+			l = s.stageLayer(layer)
+		}
+		if l.err != nil {
+			return layersDownloaded, l.err
+		}
+		layersDownloaded = true
+
+		err := s.graph.Register(l.img,
+			utils.ProgressReader(l.file, int(l.size), out, sf, false, utils.TruncateID(layer.id), "Extracting"))
+		removeLayerFile(l.file)
+		if err != nil {
+			out.Write(sf.FormatProgress(utils.TruncateID(layer.id), "Error extracting layer", nil))
+			return layersDownloaded, err
+		}
+		out.Write(sf.FormatProgress(utils.TruncateID(layer.id), statuses.complete, nil))
+		if waits[i] == nil {
+			// Let the pulls waiting for the layer go on
+			s.poolRemove("pull", "layer:"+layer.id)
+		}
+	}
+	return layersDownloaded, nil
+}
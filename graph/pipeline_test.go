@@ -0,0 +1,124 @@
+package graph
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/docker/docker/image"
+	"github.com/docker/docker/utils"
+)
+
+func TestPullLayersPipeline(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+	if err := store.SetMaxConcurrentDownloads(0); err == nil {
+		t.Fatal("Expected an error for no concurrent downloads")
+	}
+	if err := store.SetMaxConcurrentDownloads(2); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu            sync.Mutex
+		active        int
+		maxActive     int
+		downloaded    []string
+		parent        = testOfficialImageID
+		layers        = []pullLayer{{id: testOfficialImageID}}
+		ids           []string
+		baseExtracted = make(chan struct{})
+	)
+	for i := 0; i < 3; i++ {
+		img := &image.Image{ID: utils.GenerateRandomID(), Parent: parent}
+		parent = img.ID
+		ids = append(ids, img.ID)
+		last := i == 2
+		layers = append(layers, pullLayer{id: img.ID, download: func() (*image.Image, *os.File, int64, error) {
+			mu.Lock()
+			active++
+			if active > maxActive {
+				maxActive = active
+			}
+			downloaded = append(downloaded, img.ID)
+			mu.Unlock()
+			defer func() {
+				mu.Lock()
+				active--
+				mu.Unlock()
+			}()
+
+			if last {
+				// The base layer is extracted while the top one downloads
+				select {
+				case <-baseExtracted:
+				case <-time.After(10 * time.Second):
+					t.Error("The first layer was not extracted during the download of the last one")
+				}
+			} else {
+				time.Sleep(50 * time.Millisecond)
+			}
+
+			archive, err := fakeTar()
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			f, err := store.graph.newTempFile()
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			size, err := bufferToFile(f, archive)
+			if err != nil {
+				return nil, nil, 0, err
+			}
+			return img, f, size, nil
+		}})
+	}
+	go func() {
+		for !store.graph.Exists(ids[0]) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		close(baseExtracted)
+	}()
+
+	out := &bytes.Buffer{}
+	layersDownloaded, err := store.pullLayers(out, utils.NewStreamFormatter(false), layers, true, v2PullStatuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !layersDownloaded {
+		t.Fatal("Expected layers to be downloaded")
+	}
+	for _, id := range ids {
+		if !store.graph.Exists(id) {
+			t.Fatalf("Layer %s was not registered", id)
+		}
+	}
+	if len(downloaded) != 3 {
+		t.Fatalf("Expected 3 downloads, got %d", len(downloaded))
+	}
+	if maxActive > 2 {
+		t.Fatalf("Expected at most 2 concurrent downloads, got %d", maxActive)
+	}
+	if !strings.Contains(out.String(), "Already exists") {
+		t.Fatalf("Expected the existing layer to be reported, got %q", out.String())
+	}
+
+	// Nothing is downloaded once all the layers exist
+	layersDownloaded, err = store.pullLayers(ioutil.Discard, utils.NewStreamFormatter(false), layers, true, v2PullStatuses)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if layersDownloaded || len(downloaded) != 3 {
+		t.Fatal("Expected no download of existing layers")
+	}
+}
@@ -0,0 +1,196 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/units"
+	"github.com/docker/docker/utils"
+)
+
+// Phases of a layer reported in structured progress.
+const (
+	phaseResolving   = "resolving"
+	phaseWaiting     = "waiting"
+	phaseDownloading = "downloading"
+	phaseVerifying   = "verifying"
+	phaseDownloaded  = "downloaded"
+	phaseExtracting  = "extracting"
+	phasePreparing   = "preparing"
+	phaseUploading   = "uploading"
+	phaseComplete    = "complete"
+	phaseExists      = "exists"
+	phaseFailed      = "failed"
+)
+
+// layerPhases maps the progress messages of pulls and pushes to the phase of
+// the layer they are about.  Messages are matched by prefix, in order.
+var layerPhases = []struct {
+	prefix string
+	phase  string
+}{
+	{"Pulling image", phaseResolving},
+	{"Pulling metadata", phaseResolving},
+	{"Pulling dependent layers", phaseResolving},
+	{"Pulling fs layer", phaseResolving},
+	{"Layer already being pulled", phaseWaiting},
+	{"Downloading", phaseDownloading},
+	{"Download interrupted", phaseDownloading},
+	{"Verifying Checksum", phaseVerifying},
+	{"Download complete", phaseDownloaded},
+	{"Extracting", phaseExtracting},
+	{"Pull complete", phaseComplete},
+	{"Already exists", phaseExists},
+	{"Buffering to", phasePreparing},
+	{"Pushing", phaseUploading},
+	{"Image successfully pushed", phaseComplete},
+	{"Image already", phaseExists},
+	{"Image push failed", phaseFailed},
+	{"Error", phaseFailed},
+}
+
+func layerPhase(status string) string {
+	for _, p := range layerPhases {
+		if strings.HasPrefix(status, p.prefix) {
+			return p.phase
+		}
+	}
+	return ""
+}
+
+type layerProgress struct {
+	phase       string
+	current     int64
+	total       int64
+	transferred bool
+}
+
+// structuredProgress annotates the JSON messages of a pull or push with the
+// phase of the layer they are about and with the progress of the whole
+// image.  Each write must hold a single message.
+type structuredProgress struct {
+	sync.Mutex
+	out    io.Writer
+	start  time.Time
+	layers map[string]*layerProgress
+	order  []string
+}
+
+// progressOutput returns the writer a pull or push job reports its progress
+// to.  When the job asked for structured progress, the returned
+// structuredProgress must be finished once the job succeeds; it is nil
+// otherwise.
+func progressOutput(job *engine.Job, sf *utils.StreamFormatter) (io.Writer, *structuredProgress) {
+	if !job.GetenvBool("structured") || !sf.Json() {
+		return job.Stdout, nil
+	}
+	p := &structuredProgress{
+		out:    job.Stdout,
+		start:  time.Now(),
+		layers: make(map[string]*layerProgress),
+	}
+	return p, p
+}
+
+func (p *structuredProgress) Write(b []byte) (int, error) {
+	var jm utils.JSONMessage
+	if err := json.Unmarshal(b, &jm); err != nil || jm.ID == "" || jm.Error != nil {
+		return p.out.Write(b)
+	}
+	phase := layerPhase(jm.Status)
+	if phase == "" {
+		return p.out.Write(b)
+	}
+
+	p.Lock()
+	defer p.Unlock()
+	layer, exists := p.layers[jm.ID]
+	if !exists {
+		layer = &layerProgress{}
+		p.layers[jm.ID] = layer
+		p.order = append(p.order, jm.ID)
+	}
+	switch layer.phase {
+	case phaseComplete, phaseExists, phaseFailed:
+		// Later messages about a finished layer do not change its phase
+	default:
+		layer.phase = phase
+	}
+	if (phase == phaseDownloading || phase == phaseUploading) && jm.Progress != nil {
+		layer.current = int64(jm.Progress.Current)
+		if jm.Progress.Total > 0 {
+			layer.total = int64(jm.Progress.Total)
+		}
+		layer.transferred = true
+	}
+
+	jm.Phase = layer.phase
+	jm.Aggregate = p.aggregate()
+	if err := p.writeMessage(&jm); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// aggregate returns the progress of the whole image.  The total grows as
+// the size of each layer becomes known, when its transfer starts; the layers
+// whose size is unknown are left out of the byte counts.
+func (p *structuredProgress) aggregate() *utils.JSONAggregate {
+	a := &utils.JSONAggregate{Layers: len(p.layers)}
+	for _, layer := range p.layers {
+		if layer.total > 0 {
+			a.Current += layer.current
+			a.Total += layer.total
+		}
+		switch layer.phase {
+		case phaseComplete, phaseExists:
+			a.Complete++
+		}
+	}
+	if a.Total > 0 {
+		a.Percent = int(a.Current * 100 / a.Total)
+		if a.Percent > 100 {
+			a.Percent = 100
+		}
+	}
+	return a
+}
+
+func (p *structuredProgress) writeMessage(jm *utils.JSONMessage) error {
+	b, err := json.Marshal(jm)
+	if err != nil {
+		return err
+	}
+	_, err = p.out.Write(append(b, '\r', '\n'))
+	return err
+}
+
+// finish writes the summary of the transfer.  It does nothing when the job
+// did not ask for structured progress.
+func (p *structuredProgress) finish() {
+	if p == nil {
+		return
+	}
+	p.Lock()
+	defer p.Unlock()
+	summary := &utils.JSONSummary{Duration: time.Since(p.start).Seconds()}
+	for _, id := range p.order {
+		layer := p.layers[id]
+		if layer.transferred {
+			summary.BytesTransferred += layer.current
+			summary.LayersTransferred++
+		} else if layer.phase == phaseExists {
+			summary.LayersReused++
+		}
+	}
+	p.writeMessage(&utils.JSONMessage{
+		Status:    fmt.Sprintf("Transferred %s in %d layers, %d layers already present", units.HumanSize(float64(summary.BytesTransferred)), summary.LayersTransferred, summary.LayersReused),
+		Aggregate: p.aggregate(),
+		Summary:   summary,
+	})
+}
@@ -0,0 +1,129 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/utils"
+)
+
+func TestStructuredProgress(t *testing.T) {
+	eng := engine.New()
+	sf := utils.NewStreamFormatter(true)
+
+	job := eng.Job("pull")
+	buf := &bytes.Buffer{}
+	job.Stdout.Add(buf)
+	if _, progress := progressOutput(job, sf); progress != nil {
+		t.Fatal("Expected plain progress when structured progress is not asked for")
+	}
+
+	job.Setenv("structured", "1")
+	out, progress := progressOutput(job, sf)
+	if progress == nil {
+		t.Fatal("Expected structured progress")
+	}
+	out.Write(sf.FormatStatus("", "Pulling repository busybox"))
+	out.Write(sf.FormatStatus("aaaa", "Pulling fs layer"))
+	out.Write(sf.FormatStatus("bbbb", "Already exists"))
+	out.Write(sf.FormatProgress("aaaa", "Downloading", &utils.JSONProgress{Current: 50, Total: 200}))
+	out.Write(sf.FormatProgress("aaaa", "Downloading", &utils.JSONProgress{Current: 200, Total: 200}))
+	out.Write(sf.FormatStatus("aaaa", "Download complete"))
+	out.Write(sf.FormatStatus("aaaa", "Pull complete"))
+	out.Write(sf.FormatStatus("aaaa", "Download complete"))
+	progress.finish()
+
+	var messages []utils.JSONMessage
+	dec := json.NewDecoder(buf)
+	for {
+		var jm utils.JSONMessage
+		if err := dec.Decode(&jm); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		messages = append(messages, jm)
+	}
+	if len(messages) != 9 {
+		t.Fatalf("Expected 9 messages, got %d", len(messages))
+	}
+
+	if messages[0].Phase != "" || messages[0].Aggregate != nil {
+		t.Fatalf("Expected the repository message to be left unchanged, got %+v", messages[0])
+	}
+	expected := []struct {
+		phase    string
+		current  int64
+		total    int64
+		complete int
+	}{
+		{phaseResolving, 0, 0, 0},
+		{phaseExists, 0, 0, 1},
+		{phaseDownloading, 50, 200, 1},
+		{phaseDownloading, 200, 200, 1},
+		{phaseDownloaded, 200, 200, 1},
+		{phaseComplete, 200, 200, 2},
+		{phaseComplete, 200, 200, 2},
+	}
+	for i, e := range expected {
+		jm := messages[i+1]
+		if jm.Phase != e.phase {
+			t.Fatalf("Expected phase %q for message %d, got %q", e.phase, i+1, jm.Phase)
+		}
+		a := jm.Aggregate
+		if a == nil {
+			t.Fatalf("Expected an aggregate for message %d", i+1)
+		}
+		if a.Current != e.current || a.Total != e.total || a.Complete != e.complete || a.Layers != 2 && i > 0 {
+			t.Fatalf("Unexpected aggregate for message %d: %+v", i+1, a)
+		}
+	}
+	if p := messages[4].Aggregate.Percent; p != 100 {
+		t.Fatalf("Expected 100 percent, got %d", p)
+	}
+
+	summary := messages[8].Summary
+	if summary == nil {
+		t.Fatal("Expected a summary message")
+	}
+	if summary.BytesTransferred != 200 || summary.LayersTransferred != 1 || summary.LayersReused != 1 {
+		t.Fatalf("Unexpected summary: %+v", summary)
+	}
+}
+
+func TestStructuredProgressUnsizedLayer(t *testing.T) {
+	eng := engine.New()
+	sf := utils.NewStreamFormatter(true)
+
+	job := eng.Job("pull")
+	buf := &bytes.Buffer{}
+	job.Stdout.Add(buf)
+	job.Setenv("structured", "1")
+	out, progress := progressOutput(job, sf)
+	out.Write(sf.FormatProgress("aaaa", "Downloading", &utils.JSONProgress{Current: 100, Total: 200}))
+	out.Write(sf.FormatProgress("bbbb", "Downloading", &utils.JSONProgress{Current: 500}))
+	progress.finish()
+
+	var last utils.JSONMessage
+	dec := json.NewDecoder(buf)
+	for i := 0; i < 2; i++ {
+		if err := dec.Decode(&last); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a := last.Aggregate
+	if a.Current != 100 || a.Total != 200 || a.Percent != 50 {
+		t.Fatalf("Expected the layer of unknown size to be left out of the aggregate, got %+v", a)
+	}
+
+	var summary utils.JSONMessage
+	if err := dec.Decode(&summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Summary.BytesTransferred != 600 {
+		t.Fatalf("Expected the summary to count the bytes of every layer, got %+v", summary.Summary)
+	}
+}
@@ -1,11 +1,9 @@
 package graph
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
-	"io/ioutil"
-	"net"
-	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -13,7 +11,6 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/image"
-	"github.com/docker/docker/pkg/tarsum"
 	"github.com/docker/docker/registry"
 	"github.com/docker/docker/utils"
 )
@@ -44,7 +41,9 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 	job.GetenvJson("authConfig", authConfig)
 	job.GetenvJson("metaHeaders", &metaHeaders)
 
-	c, err := s.poolAdd("pull", repoInfo.LocalName+":"+tag)
+	out, progress := progressOutput(job, sf)
+
+	c, err := s.poolAdd("pull", utils.ImageReference(repoInfo.LocalName, tag))
 	if err != nil {
 		if c != nil {
 			// Another pull of the same repository is already taking place; just wait for it to finish
@@ -54,7 +53,7 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 		}
 		return job.Error(err)
 	}
-	defer s.poolRemove("pull", repoInfo.LocalName+":"+tag)
+	defer s.poolRemove("pull", utils.ImageReference(repoInfo.LocalName, tag))
 
 	log.Debugf("pulling image from host %q with remote name %q", repoInfo.Index.Name, repoInfo.RemoteName)
 	endpoint, err := repoInfo.GetEndpoint()
@@ -69,7 +68,12 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 
 	logName := repoInfo.LocalName
 	if tag != "" {
-		logName += ":" + tag
+		logName = utils.ImageReference(logName, tag)
+	}
+
+	enforced, err := trustPolicyEnforced(job.Eng, trustNamespace(repoInfo.RemoteName))
+	if err != nil {
+		return job.Error(err)
 	}
 
 	if len(repoInfo.Index.Mirrors) == 0 && ((repoInfo.Official && repoInfo.Index.Official) || endpoint.Version == registry.APIVersion2) {
@@ -79,11 +83,16 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 		}
 
 		log.Debugf("pulling v2 repository with local name %q", repoInfo.LocalName)
-		if err := s.pullV2Repository(job.Eng, r, job.Stdout, repoInfo, tag, sf, job.GetenvBool("parallel")); err == nil {
+		if err := s.pullV2Repository(job.Eng, r, out, repoInfo, tag, sf, job.GetenvBool("parallel")); err == nil {
 			if err = job.Eng.Job("log", "pull", logName, "").Run(); err != nil {
 				log.Errorf("Error logging event 'pull' for %s: %s", logName, err)
 			}
+			progress.finish()
 			return engine.StatusOK
+		} else if utils.DigestReference(tag) || enforced {
+			// Content digests and signatures only exist on v2 registries,
+			// there is nothing to fall back to
+			return job.Error(err)
 		} else if err != registry.ErrDoesNotExist {
 			log.Errorf("Error from V2 registry: %s", err)
 		}
@@ -91,14 +100,22 @@ func (s *TagStore) CmdPull(job *engine.Job) engine.Status {
 		log.Debug("image does not exist on v2 registry, falling back to v1")
 	}
 
+	if utils.DigestReference(tag) {
+		return job.Errorf("Cannot pull %s by digest: digests are only supported by v2 registries", logName)
+	}
+	if enforced {
+		return job.Errorf("Cannot pull %s: the trust policy requires signed images, which are only supported by v2 registries", logName)
+	}
+
 	log.Debugf("pulling v1 repository with local name %q", repoInfo.LocalName)
-	if err = s.pullRepository(r, job.Stdout, repoInfo, tag, sf, job.GetenvBool("parallel")); err != nil {
+	if err = s.pullRepository(r, out, repoInfo, tag, sf, job.GetenvBool("parallel")); err != nil {
 		return job.Error(err)
 	}
 
 	if err = job.Eng.Job("log", "pull", logName, "").Run(); err != nil {
 		log.Errorf("Error logging event 'pull' for %s: %s", logName, err)
 	}
+	progress.finish()
 
 	return engine.StatusOK
 }
@@ -189,7 +206,7 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, repoInfo *
 			var is_downloaded bool
 			for _, ep := range repoInfo.Index.Mirrors {
 				out.Write(sf.FormatProgress(utils.TruncateID(img.ID), fmt.Sprintf("Pulling image (%s) from %s, mirror: %s", img.Tag, repoInfo.CanonicalName, ep), nil))
-				if is_downloaded, err = s.pullImage(r, out, img.ID, ep, repoData.Tokens, sf); err != nil {
+				if is_downloaded, err = s.pullImage(r, out, img.ID, ep, repoData.Tokens, sf, parallel); err != nil {
 					// Don't report errors when pulling from mirrors.
 					log.Debugf("Error pulling image (%s) from %s, mirror: %s, %s", img.Tag, repoInfo.CanonicalName, ep, err)
 					continue
@@ -201,7 +218,7 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, repoInfo *
 			if !success {
 				for _, ep := range repoData.Endpoints {
 					out.Write(sf.FormatProgress(utils.TruncateID(img.ID), fmt.Sprintf("Pulling image (%s) from %s, endpoint: %s", img.Tag, repoInfo.CanonicalName, ep), nil))
-					if is_downloaded, err = s.pullImage(r, out, img.ID, ep, repoData.Tokens, sf); err != nil {
+					if is_downloaded, err = s.pullImage(r, out, img.ID, ep, repoData.Tokens, sf, parallel); err != nil {
 						// It's not ideal that only the last error is returned, it would be better to concatenate the errors.
 						// As the error is also given to the output stream the user will see the error.
 						lastErr = err
@@ -263,27 +280,17 @@ func (s *TagStore) pullRepository(r *registry.Session, out io.Writer, repoInfo *
 	return nil
 }
 
-func (s *TagStore) pullImage(r *registry.Session, out io.Writer, imgID, endpoint string, token []string, sf *utils.StreamFormatter) (bool, error) {
+func (s *TagStore) pullImage(r *registry.Session, out io.Writer, imgID, endpoint string, token []string, sf *utils.StreamFormatter, parallel bool) (bool, error) {
 	history, err := r.GetRemoteHistory(imgID, endpoint, token)
 	if err != nil {
 		return false, err
 	}
 	out.Write(sf.FormatProgress(utils.TruncateID(imgID), "Pulling dependent layers", nil))
-	// FIXME: Try to stream the images?
-	// FIXME: Launch the getRemoteImage() in goroutines
 
-	layers_downloaded := false
+	layers := make([]pullLayer, 0, len(history))
 	for i := len(history) - 1; i >= 0; i-- {
 		id := history[i]
-
-		// ensure no two downloads of the same layer happen at the same time
-		if c, err := s.poolAdd("pull", "layer:"+id); err != nil {
-			log.Debugf("Image (id: %s) pull is already running, skipping: %v", id, err)
-			<-c
-		}
-		defer s.poolRemove("pull", "layer:"+id)
-
-		if !s.graph.Exists(id) {
+		layers = append(layers, pullLayer{id: id, download: func() (*image.Image, *os.File, int64, error) {
 			out.Write(sf.FormatProgress(utils.TruncateID(id), "Pulling metadata", nil))
 			var (
 				imgJSON []byte
@@ -296,16 +303,15 @@ func (s *TagStore) pullImage(r *registry.Session, out io.Writer, imgID, endpoint
 				imgJSON, imgSize, err = r.GetRemoteImageJSON(id, endpoint, token)
 				if err != nil && j == retries {
 					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error pulling dependent layers", nil))
-					return layers_downloaded, err
+					return nil, nil, 0, err
 				} else if err != nil {
 					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
 					continue
 				}
 				img, err = image.NewImgJSON(imgJSON)
-				layers_downloaded = true
 				if err != nil && j == retries {
 					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error pulling dependent layers", nil))
-					return layers_downloaded, fmt.Errorf("Failed to parse json: %s", err)
+					return nil, nil, 0, fmt.Errorf("Failed to parse json: %s", err)
 				} else if err != nil {
 					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
 					continue
@@ -314,43 +320,37 @@ func (s *TagStore) pullImage(r *registry.Session, out io.Writer, imgID, endpoint
 				}
 			}
 
-			for j := 1; j <= retries; j++ {
-				// Get the layer
-				status := "Pulling fs layer"
-				if j > 1 {
-					status = fmt.Sprintf("Pulling fs layer [retries: %d]", j)
-				}
-				out.Write(sf.FormatProgress(utils.TruncateID(id), status, nil))
-				layer, err := r.GetRemoteImageLayer(img.ID, endpoint, token, int64(imgSize))
-				if uerr, ok := err.(*url.Error); ok {
-					err = uerr.Err
-				}
-				if terr, ok := err.(net.Error); ok && terr.Timeout() && j < retries {
-					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
-					continue
-				} else if err != nil {
-					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error pulling dependent layers", nil))
-					return layers_downloaded, err
-				}
-				layers_downloaded = true
-				defer layer.Close()
+			layerFile, size, err := s.downloadLayer(out, sf, id, func(offset int64) (io.ReadCloser, int64, error) {
+				layer, err := r.GetRemoteImageLayer(img.ID, endpoint, token, int64(imgSize), offset)
+				return layer, int64(imgSize), err
+			})
+			if err != nil {
+				out.Write(sf.FormatProgress(utils.TruncateID(id), "Error pulling dependent layers", nil))
+				return nil, nil, 0, err
+			}
 
-				err = s.graph.Register(img,
-					utils.ProgressReader(layer, imgSize, out, sf, false, utils.TruncateID(id), "Downloading"))
-				if terr, ok := err.(net.Error); ok && terr.Timeout() && j < retries {
-					time.Sleep(time.Duration(j) * 500 * time.Millisecond)
-					continue
-				} else if err != nil {
-					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error downloading dependent layers", nil))
-					return layers_downloaded, err
-				} else {
-					break
+			// The layer is only registered once it matches the checksum of
+			// its image JSON, for the registries which have one
+			var v1Img struct {
+				Checksum string `json:"checksum"`
+			}
+			if err := json.Unmarshal(imgJSON, &v1Img); err == nil && v1Img.Checksum != "" {
+				out.Write(sf.FormatProgress(utils.TruncateID(id), "Verifying Checksum", nil))
+				if err := verifyV1Layer(layerFile, v1Img.Checksum, imgJSON); err != nil {
+					removeLayerFile(layerFile)
+					out.Write(sf.FormatProgress(utils.TruncateID(id), "Error pulling dependent layers", nil))
+					return nil, nil, 0, err
 				}
 			}
-		}
-		out.Write(sf.FormatProgress(utils.TruncateID(id), "Download complete", nil))
+			return img, layerFile, size, nil
+		}})
 	}
-	return layers_downloaded, nil
+	// Structured progress needs to tell the reused layers apart
+	statuses := v1PullStatuses
+	if _, structured := out.(*structuredProgress); structured {
+		statuses = v2PullStatuses
+	}
+	return s.pullLayers(out, sf, layers, parallel, statuses)
 }
 
 func WriteStatus(requestedTag string, out io.Writer, sf *utils.StreamFormatter, layers_downloaded bool) {
@@ -361,16 +361,6 @@ func WriteStatus(requestedTag string, out io.Writer, sf *utils.StreamFormatter,
 	}
 }
 
-// downloadInfo is used to pass information from download to extractor
-type downloadInfo struct {
-	imgJSON    []byte
-	img        *image.Image
-	tmpFile    *os.File
-	length     int64
-	downloaded bool
-	err        chan error
-}
-
 func (s *TagStore) pullV2Repository(eng *engine.Engine, r *registry.Session, out io.Writer, repoInfo *registry.RepositoryInfo, tag string, sf *utils.StreamFormatter, parallel bool) error {
 	endpoint, err := r.V2RegistryEndpoint(repoInfo.Index)
 	if err != nil {
@@ -407,7 +397,7 @@ func (s *TagStore) pullV2Repository(eng *engine.Engine, r *registry.Session, out
 
 	requestedTag := repoInfo.CanonicalName
 	if len(tag) > 0 {
-		requestedTag = repoInfo.CanonicalName + ":" + tag
+		requestedTag = utils.ImageReference(repoInfo.CanonicalName, tag)
 	}
 	WriteStatus(requestedTag, out, sf, layersDownloaded)
 	return nil
@@ -415,7 +405,7 @@ func (s *TagStore) pullV2Repository(eng *engine.Engine, r *registry.Session, out
 
 func (s *TagStore) pullV2Tag(eng *engine.Engine, r *registry.Session, out io.Writer, endpoint *registry.Endpoint, repoInfo *registry.RepositoryInfo, tag string, sf *utils.StreamFormatter, parallel bool, auth *registry.RequestAuthorization) (bool, error) {
 	log.Debugf("Pulling tag from V2 registry: %q", tag)
-	manifestBytes, err := r.GetV2ImageManifest(endpoint, repoInfo.RemoteName, tag, auth)
+	manifestBytes, digest, err := r.GetV2ImageManifest(endpoint, repoInfo.RemoteName, tag, auth)
 	if err != nil {
 		return false, err
 	}
@@ -429,14 +419,20 @@ func (s *TagStore) pullV2Tag(eng *engine.Engine, r *registry.Session, out io.Wri
 		return false, err
 	}
 
+	if err := checkTrustPolicy(eng, repoInfo, manifest, verified); err != nil {
+		return false, err
+	}
+
 	if verified {
-		log.Printf("Image manifest for %s:%s has been verified", repoInfo.CanonicalName, tag)
+		log.Printf("Image manifest for %s has been verified", utils.ImageReference(repoInfo.CanonicalName, tag))
 	} else {
 		out.Write(sf.FormatStatus(tag, "Pulling from %s", repoInfo.CanonicalName))
 	}
 
-	downloads := make([]downloadInfo, len(manifest.FSLayers))
-
+	var (
+		layers = make([]pullLayer, 0, len(manifest.FSLayers))
+		topImg *image.Image
+	)
 	for i := len(manifest.FSLayers) - 1; i >= 0; i-- {
 		var (
 			sumStr  = manifest.FSLayers[i].BlobSum
@@ -447,118 +443,55 @@ func (s *TagStore) pullV2Tag(eng *engine.Engine, r *registry.Session, out io.Wri
 		if err != nil {
 			return false, fmt.Errorf("failed to parse json: %s", err)
 		}
-		downloads[i].img = img
-
-		// Check if exists
-		if s.graph.Exists(img.ID) {
-			log.Debugf("Image already exists: %s", img.ID)
-			continue
-		}
+		topImg = img
 
 		chunks := strings.SplitN(sumStr, ":", 2)
 		if len(chunks) < 2 {
 			return false, fmt.Errorf("expected 2 parts in the sumStr, got %#v", chunks)
 		}
 		sumType, checksum := chunks[0], chunks[1]
-		out.Write(sf.FormatProgress(utils.TruncateID(img.ID), "Pulling fs layer", nil))
 
-		downloadFunc := func(di *downloadInfo) error {
+		layers = append(layers, pullLayer{id: img.ID, download: func() (*image.Image, *os.File, int64, error) {
 			log.Debugf("pulling blob %q to V1 img %s", sumStr, img.ID)
-
-			if c, err := s.poolAdd("pull", "img:"+img.ID); err != nil {
-				if c != nil {
-					out.Write(sf.FormatProgress(utils.TruncateID(img.ID), "Layer already being pulled by another client. Waiting.", nil))
-					<-c
-					out.Write(sf.FormatProgress(utils.TruncateID(img.ID), "Download complete", nil))
-				} else {
-					log.Debugf("Image (id: %s) pull is already running, skipping: %v", img.ID, err)
-				}
-			} else {
-				defer s.poolRemove("pull", "img:"+img.ID)
-				tmpFile, err := ioutil.TempFile("", "GetV2ImageBlob")
-				if err != nil {
-					return err
-				}
-
-				r, l, err := r.GetV2ImageBlobReader(endpoint, repoInfo.RemoteName, sumType, checksum, auth)
-				if err != nil {
-					return err
-				}
-				defer r.Close()
-
-				// Wrap the reader with the appropriate TarSum reader.
-				tarSumReader, err := tarsum.NewTarSumForLabel(r, true, sumType)
-				if err != nil {
-					return fmt.Errorf("unable to wrap image blob reader with TarSum: %s", err)
-				}
-
-				io.Copy(tmpFile, utils.ProgressReader(ioutil.NopCloser(tarSumReader), int(l), out, sf, false, utils.TruncateID(img.ID), "Downloading"))
-
-				out.Write(sf.FormatProgress(utils.TruncateID(img.ID), "Verifying Checksum", nil))
-
-				if finalChecksum := tarSumReader.Sum(nil); !strings.EqualFold(finalChecksum, sumStr) {
-					return fmt.Errorf("image verification failed: checksum mismatch - expected %q but got %q", sumStr, finalChecksum)
-				}
-
-				out.Write(sf.FormatProgress(utils.TruncateID(img.ID), "Download complete", nil))
-
-				log.Debugf("Downloaded %s to tempfile %s", img.ID, tmpFile.Name())
-				di.tmpFile = tmpFile
-				di.length = l
-				di.downloaded = true
+			tmpFile, l, err := s.downloadLayer(out, sf, img.ID, func(offset int64) (io.ReadCloser, int64, error) {
+				return r.GetV2ImageBlobReader(endpoint, repoInfo.RemoteName, sumType, checksum, offset, auth)
+			})
+			if err != nil {
+				return nil, nil, 0, err
 			}
-			di.imgJSON = imgJSON
 
-			return nil
-		}
+			out.Write(sf.FormatProgress(utils.TruncateID(img.ID), "Verifying Checksum", nil))
 
-		if parallel {
-			downloads[i].err = make(chan error)
-			go func(di *downloadInfo) {
-				di.err <- downloadFunc(di)
-			}(&downloads[i])
-		} else {
-			err := downloadFunc(&downloads[i])
-			if err != nil {
-				return false, err
+			if err := verifyLayer(tmpFile, sumStr); err != nil {
+				removeLayerFile(tmpFile)
+				return nil, nil, 0, err
 			}
-		}
-	}
 
-	var layersDownloaded bool
-	for i := len(downloads) - 1; i >= 0; i-- {
-		d := &downloads[i]
-		if d.err != nil {
-			err := <-d.err
-			if err != nil {
-				return false, err
-			}
-		}
-		if d.downloaded {
-			// if tmpFile is empty assume download and extracted elsewhere
-			defer os.Remove(d.tmpFile.Name())
-			defer d.tmpFile.Close()
-			d.tmpFile.Seek(0, 0)
-			if d.tmpFile != nil {
-				err = s.graph.Register(d.img,
-					utils.ProgressReader(d.tmpFile, int(d.length), out, sf, false, utils.TruncateID(d.img.ID), "Extracting"))
-				if err != nil {
-					return false, err
-				}
+			out.Write(sf.FormatProgress(utils.TruncateID(img.ID), "Download complete", nil))
 
-				// FIXME: Pool release here for parallel tag pull (ensures any downloads block until fully extracted)
-			}
-			out.Write(sf.FormatProgress(utils.TruncateID(d.img.ID), "Pull complete", nil))
-			layersDownloaded = true
-		} else {
-			out.Write(sf.FormatProgress(utils.TruncateID(d.img.ID), "Already exists", nil))
-		}
+			log.Debugf("Downloaded %s to tempfile %s", img.ID, tmpFile.Name())
+			return img, tmpFile, l, nil
+		}})
+	}
 
+	layersDownloaded, err := s.pullLayers(out, sf, layers, parallel, v2PullStatuses)
+	if err != nil {
+		return false, err
 	}
 
-	out.Write(sf.FormatStatus(repoInfo.CanonicalName+":"+tag, "The image you are pulling has been verified. Important: image verification is a tech preview feature and should not be relied on to provide security."))
+	out.Write(sf.FormatStatus(utils.ImageReference(repoInfo.CanonicalName, tag), "The image you are pulling has been verified. Important: image verification is a tech preview feature and should not be relied on to provide security."))
 
-	if err = s.Set(repoInfo.LocalName, tag, downloads[0].img.ID, true); err != nil {
+	if utils.DigestReference(tag) {
+		if err = s.SetDigest(repoInfo.LocalName, tag, topImg.ID); err != nil {
+			return false, err
+		}
+	} else {
+		if err = s.Set(repoInfo.LocalName, tag, topImg.ID, true); err != nil {
+			return false, err
+		}
+		out.Write(sf.FormatStatus("", "Digest: %s", digest))
+	}
+	if err = s.setManifest(repoInfo.LocalName, tag, manifestBytes); err != nil {
 		return false, err
 	}
 
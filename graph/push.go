@@ -8,6 +8,7 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 	"sync"
 
@@ -33,6 +34,10 @@ func (s *TagStore) getImageList(localRepo map[string]string, requestedTag string
 		if requestedTag != "" && requestedTag != tag {
 			continue
 		}
+		// Digests are derived from the pushed content and cannot be pushed as tags
+		if utils.DigestReference(tag) {
+			continue
+		}
 		var imageListForThisTag []string
 
 		tagsByImage[id] = append(tagsByImage[id], tag)
@@ -82,7 +87,9 @@ func (s *TagStore) getImageTags(localName, askedTag string) ([]string, error) {
 	}
 	var tags []string
 	for tag := range localRepo {
-		tags = append(tags, tag)
+		if !utils.DigestReference(tag) {
+			tags = append(tags, tag)
+		}
 	}
 	return tags, nil
 }
@@ -273,7 +280,7 @@ func (s *TagStore) pushImage(r *registry.Session, out io.Writer, imgID, ep strin
 	return imgData.Checksum, nil
 }
 
-func (s *TagStore) pushV2Repository(r *registry.Session, eng *engine.Engine, out io.Writer, repoInfo *registry.RepositoryInfo, tag string, sf *utils.StreamFormatter) error {
+func (s *TagStore) pushV2Repository(r *registry.Session, eng *engine.Engine, out io.Writer, repoInfo *registry.RepositoryInfo, tag string, signKeys []libtrust.PrivateKey, sf *utils.StreamFormatter) error {
 	if repoInfo.Official {
 		j := eng.Job("trust_update_base")
 		if err := j.Run(); err != nil {
@@ -317,12 +324,18 @@ func (s *TagStore) pushV2Repository(r *registry.Session, eng *engine.Engine, out
 		if err = js.Sign(s.trustKey); err != nil {
 			return err
 		}
+		log.Infof("Signed manifest for %s:%s using daemon's key: %s", repoInfo.LocalName, tag, s.trustKey.KeyID())
+		for _, key := range signKeys {
+			if err = js.Sign(key); err != nil {
+				return err
+			}
+			log.Infof("Signed manifest for %s:%s using key: %s", repoInfo.LocalName, tag, key.KeyID())
+		}
 
 		signedBody, err := js.PrettySignature("signatures")
 		if err != nil {
 			return err
 		}
-		log.Infof("Signed manifest for %s:%s using daemon's key: %s", repoInfo.LocalName, tag, s.trustKey.KeyID())
 
 		manifestBytes := string(signedBody)
 
@@ -376,6 +389,17 @@ func (s *TagStore) pushV2Repository(r *registry.Session, eng *engine.Engine, out
 		if err := r.PutV2ImageManifest(endpoint, repoInfo.RemoteName, tag, bytes.NewReader([]byte(manifestBytes)), auth); err != nil {
 			return err
 		}
+
+		digest, err := registry.ManifestDigest(signedBody)
+		if err != nil {
+			return err
+		}
+		out.Write(sf.FormatStatus("", "%s: digest: %s", tag, digest))
+
+		// Keep the signatures so that they can be inspected
+		if err := s.setManifest(repoInfo.LocalName, tag, signedBody); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -439,6 +463,22 @@ func (s *TagStore) CmdPush(job *engine.Job) engine.Status {
 	job.GetenvJson("authConfig", authConfig)
 	job.GetenvJson("metaHeaders", &metaHeaders)
 
+	// Additional keys to sign the manifest with, read from the files on the
+	// host of the daemon
+	var signKeys []libtrust.PrivateKey
+	for _, keyPath := range job.GetenvList("signKeys") {
+		if !filepath.IsAbs(keyPath) {
+			return job.Errorf("Invalid signing key %s: the path must be absolute", keyPath)
+		}
+		key, err := libtrust.LoadKeyFile(keyPath)
+		if err != nil {
+			return job.Errorf("Error loading signing key %s: %s", keyPath, err)
+		}
+		signKeys = append(signKeys, key)
+	}
+
+	out, progress := progressOutput(job, sf)
+
 	if _, err := s.poolAdd("push", repoInfo.LocalName); err != nil {
 		return job.Error(err)
 	}
@@ -456,8 +496,9 @@ func (s *TagStore) CmdPush(job *engine.Job) engine.Status {
 	}
 
 	if endpoint.Version == registry.APIVersion2 {
-		err := s.pushV2Repository(r, job.Eng, job.Stdout, repoInfo, tag, sf)
+		err := s.pushV2Repository(r, job.Eng, out, repoInfo, tag, signKeys, sf)
 		if err == nil {
+			progress.finish()
 			return engine.StatusOK
 		}
 
@@ -466,26 +507,32 @@ func (s *TagStore) CmdPush(job *engine.Job) engine.Status {
 		}
 	}
 
+	if len(signKeys) > 0 {
+		return job.Errorf("Cannot sign %s: signatures are only supported by v2 registries", repoInfo.CanonicalName)
+	}
+
 	if err != nil {
 		reposLen := 1
 		if tag == "" {
 			reposLen = len(s.Repositories[repoInfo.LocalName])
 		}
-		job.Stdout.Write(sf.FormatStatus("", "The push refers to a repository [%s] (len: %d)", repoInfo.CanonicalName, reposLen))
+		out.Write(sf.FormatStatus("", "The push refers to a repository [%s] (len: %d)", repoInfo.CanonicalName, reposLen))
 		// If it fails, try to get the repository
 		if localRepo, exists := s.Repositories[repoInfo.LocalName]; exists {
-			if err := s.pushRepository(r, job.Stdout, repoInfo, localRepo, tag, sf); err != nil {
+			if err := s.pushRepository(r, out, repoInfo, localRepo, tag, sf); err != nil {
 				return job.Error(err)
 			}
+			progress.finish()
 			return engine.StatusOK
 		}
 		return job.Error(err)
 	}
 
 	var token []string
-	job.Stdout.Write(sf.FormatStatus("", "The push refers to an image: [%s]", repoInfo.CanonicalName))
-	if _, err := s.pushImage(r, job.Stdout, img.ID, endpoint.String(), token, sf); err != nil {
+	out.Write(sf.FormatStatus("", "The push refers to an image: [%s]", repoInfo.CanonicalName))
+	if _, err := s.pushImage(r, out, img.ID, endpoint.String(), token, sf); err != nil {
 		return job.Error(err)
 	}
+	progress.finish()
 	return engine.StatusOK
 }
@@ -0,0 +1,489 @@
+package graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/Sirupsen/logrus"
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/registry/v2"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
+	"github.com/gorilla/mux"
+)
+
+// CmdServeRegistry serves the repositories of the tag store read-only over
+// the v2 registry API, so that other daemons can pull the images this host
+// already has.  It runs until the listener on the address given as argument
+// fails.
+func (s *TagStore) CmdServeRegistry(job *engine.Job) engine.Status {
+	if len(job.Args) != 1 {
+		return job.Errorf("Usage: %s ADDR", job.Name)
+	}
+	l, err := net.Listen("tcp", job.Args[0])
+	if err != nil {
+		return job.Error(err)
+	}
+	handler, err := s.registryHandler()
+	if err != nil {
+		return job.Error(err)
+	}
+	log.Infof("Serving the v2 registry API read-only on %s", job.Args[0])
+	if err := http.Serve(l, handler); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
+
+// registryServer serves the repositories of a tag store over the v2 registry
+// API.  The manifests it serves, and the blobs of the layers they reference,
+// are kept so that each is only generated once.
+type registryServer struct {
+	store    *TagStore
+	blobsDir string // where the blobs of the layers are staged
+
+	sync.Mutex
+	manifests map[string]*servedManifest         // manifests by repository and tag
+	digests   map[string]map[string]*servedManifest // manifests by repository and digest
+	layers    map[string]map[string]string          // layer IDs by repository and blob sum
+	blobs     map[string]*servedBlob                // staged blobs by layer ID
+}
+
+// servedManifest is a signed manifest the registry serves.
+type servedManifest struct {
+	imageID string // the image the manifest was made for
+	bytes   []byte
+	digest  string
+}
+
+// servedBlob is the blob of a layer, staged the first time it is requested.
+type servedBlob struct {
+	once sync.Once
+	path string
+	err  error
+}
+
+// registryHandler returns the handler of the read-only v2 registry API.
+func (s *TagStore) registryHandler() (http.Handler, error) {
+	// Blobs staged by a previous run are stale
+	blobsDir := filepath.Join(s.graph.Root, "_tmp", "registry-blobs")
+	if err := os.RemoveAll(blobsDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(blobsDir, 0700); err != nil {
+		return nil, err
+	}
+	rs := &registryServer{
+		store:     s,
+		blobsDir:  blobsDir,
+		manifests: make(map[string]*servedManifest),
+		digests:   make(map[string]map[string]*servedManifest),
+		layers:    make(map[string]map[string]string),
+		blobs:     make(map[string]*servedBlob),
+	}
+
+	router := v2.Router()
+	for name, handler := range map[string]http.HandlerFunc{
+		v2.RouteNameBase:     func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("{}")) },
+		v2.RouteNameCatalog:  rs.serveCatalog,
+		v2.RouteNameTags:     rs.serveTags,
+		v2.RouteNameManifest: rs.serveManifest,
+		v2.RouteNameBlob:     rs.serveBlob,
+	} {
+		router.GetRoute(name).Handler(readOnly(handler))
+	}
+	for _, name := range []string{v2.RouteNameBlobUpload, v2.RouteNameBlobUploadChunk} {
+		router.GetRoute(name).Handler(readOnly(nil))
+	}
+	return router, nil
+}
+
+// readOnly sets the API version header on every response and refuses all
+// requests which would modify the registry.
+func readOnly(handler http.HandlerFunc) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+		if handler == nil || (r.Method != "GET" && r.Method != "HEAD") {
+			writeRegistryError(w, http.StatusMethodNotAllowed, v2.ErrorCodeUnknown, "the registry is read-only")
+			return
+		}
+		handler(w, r)
+	})
+}
+
+func writeRegistryError(w http.ResponseWriter, status int, code v2.ErrorCode, detail string) {
+	var errs v2.Errors
+	errs.Push(code, detail)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errs)
+}
+
+// repository returns the local repository served under the name of the
+// request.  Official images are served under library/<name>.
+func (rs *registryServer) repository(w http.ResponseWriter, r *http.Request) (string, Repository, bool) {
+	name := mux.Vars(r)["name"]
+	repo, err := rs.store.Get(name)
+	if err != nil || repo == nil {
+		writeRegistryError(w, http.StatusNotFound, v2.ErrorCodeNameUnknown, name)
+		return "", nil, false
+	}
+	return registry.NormalizeLocalName(name), repo, true
+}
+
+// serveCatalog lists the repositories served, n names at a time after the
+// name last when the request sets them.  Repositories of other registries are
+// not served, so they are not listed.
+func (rs *registryServer) serveCatalog(w http.ResponseWriter, r *http.Request) {
+	var (
+		last = r.URL.Query().Get("last")
+		n    int
+	)
+	if v := r.URL.Query().Get("n"); v != "" {
+		var err error
+		if n, err = strconv.Atoi(v); err != nil || n < 0 {
+			writeRegistryError(w, http.StatusBadRequest, v2.ErrorCodeUnknown, "invalid n: "+v)
+			return
+		}
+	}
+
+	rs.store.Lock()
+	names := []string{}
+	for name := range rs.store.Repositories {
+		if !strings.Contains(name, "/") {
+			name = "library/" + name
+		}
+		if v2.RepositoryNameRegexp.FindString(name) == name && name > last {
+			names = append(names, name)
+		}
+	}
+	rs.store.Unlock()
+	sort.Strings(names)
+
+	if n > 0 && len(names) > n {
+		names = names[:n]
+		u := url.URL{Path: r.URL.Path, RawQuery: url.Values{"n": {strconv.Itoa(n)}, "last": {names[n-1]}}.Encode()}
+		w.Header().Set("Link", fmt.Sprintf("<%s>; rel=\"next\"", u.String()))
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"repositories": names,
+	})
+}
+
+func (rs *registryServer) serveTags(w http.ResponseWriter, r *http.Request) {
+	_, repo, ok := rs.repository(w, r)
+	if !ok {
+		return
+	}
+	tags := []string{}
+	for tag := range repo {
+		if !utils.DigestReference(tag) {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name": mux.Vars(r)["name"],
+		"tags": tags,
+	})
+}
+
+func (rs *registryServer) serveManifest(w http.ResponseWriter, r *http.Request) {
+	localName, repo, ok := rs.repository(w, r)
+	if !ok {
+		return
+	}
+	var (
+		name      = mux.Vars(r)["name"]
+		reference = mux.Vars(r)["tag"]
+		manifest  *servedManifest
+		err       error
+	)
+	if _, exists := repo[reference]; exists {
+		manifest, err = rs.manifest(localName, name, repo, reference)
+	} else if utils.DigestReference(reference) {
+		manifest, err = rs.manifestByDigest(localName, name, repo, reference)
+	}
+	if err != nil {
+		writeRegistryError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err.Error())
+		return
+	}
+	if manifest == nil {
+		writeRegistryError(w, http.StatusNotFound, v2.ErrorCodeManifestUnknown, reference)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Docker-Content-Digest", manifest.digest)
+	w.Header().Set("Content-Length", fmt.Sprint(len(manifest.bytes)))
+	if r.Method == "GET" {
+		w.Write(manifest.bytes)
+	}
+}
+
+// manifest returns the manifest of the reference ref of the repository repo,
+// served under remoteName, or nil for a digest without a manifest.  The signed manifest the reference was pulled or
+// pushed with is served as is, so that it keeps its digest, and a manifest
+// signed with the daemon's key is generated for the others.
+func (rs *registryServer) manifest(localName, remoteName string, repo Repository, ref string) (*servedManifest, error) {
+	key := localName + ":" + ref
+	imageID := repo[ref]
+	rs.Lock()
+	manifest := rs.manifests[key]
+	rs.Unlock()
+	if manifest != nil && manifest.imageID == imageID {
+		return manifest, nil
+	}
+
+	mBytes, err := rs.store.getManifest(localName, ref)
+	if err != nil {
+		return nil, err
+	}
+	if mBytes == nil {
+		// Manifests cannot be generated for digests
+		if utils.DigestReference(ref) {
+			return nil, nil
+		}
+		if mBytes, err = rs.store.signedManifest(localName, remoteName, ref); err != nil {
+			return nil, err
+		}
+	}
+	digest, err := registry.ManifestDigest(mBytes)
+	if err != nil {
+		return nil, err
+	}
+	layers, err := manifestLayers(mBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	manifest = &servedManifest{imageID: imageID, bytes: mBytes, digest: digest}
+	rs.Lock()
+	defer rs.Unlock()
+	rs.manifests[key] = manifest
+	if rs.digests[localName] == nil {
+		rs.digests[localName] = make(map[string]*servedManifest)
+	}
+	rs.digests[localName][strings.ToLower(digest)] = manifest
+	if rs.layers[localName] == nil {
+		rs.layers[localName] = make(map[string]string)
+	}
+	for blobSum, id := range layers {
+		rs.layers[localName][strings.ToLower(blobSum)] = id
+	}
+	return manifest, nil
+}
+
+// manifestByDigest returns the manifest of repo whose digest is digest, or
+// nil if none has it.  The manifests of the tags of the repository are only
+// generated when the digest is not one of the manifests already served.
+func (rs *registryServer) manifestByDigest(localName, remoteName string, repo Repository, digest string) (*servedManifest, error) {
+	rs.Lock()
+	manifest := rs.digests[localName][strings.ToLower(digest)]
+	rs.Unlock()
+	if manifest != nil && repo.hasImage(manifest.imageID) {
+		return manifest, nil
+	}
+	if err := rs.indexRepository(localName, remoteName, repo); err != nil {
+		return nil, err
+	}
+	rs.Lock()
+	defer rs.Unlock()
+	if manifest = rs.digests[localName][strings.ToLower(digest)]; manifest != nil && repo.hasImage(manifest.imageID) {
+		return manifest, nil
+	}
+	return nil, nil
+}
+
+// indexRepository records the digests of the manifests of every reference of
+// repo, and the blobs they reference.
+func (rs *registryServer) indexRepository(localName, remoteName string, repo Repository) error {
+	for ref := range repo {
+		if _, err := rs.manifest(localName, remoteName, repo, ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasImage returns true if a reference of the repository points to id.
+func (r Repository) hasImage(id string) bool {
+	for _, imageID := range r {
+		if imageID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// manifestLayers returns the IDs of the layers a signed manifest references,
+// by blob sum.
+func manifestLayers(mBytes []byte) (map[string]string, error) {
+	sig, err := libtrust.ParsePrettySignature(mBytes, "signatures")
+	if err != nil {
+		return nil, err
+	}
+	payload, err := sig.Payload()
+	if err != nil {
+		return nil, err
+	}
+	var manifest registry.ManifestData
+	if err := json.Unmarshal(payload, &manifest); err != nil {
+		return nil, err
+	}
+	if len(manifest.FSLayers) != len(manifest.History) {
+		return nil, fmt.Errorf("length of history not equal to number of layers")
+	}
+	layers := make(map[string]string, len(manifest.FSLayers))
+	for i, fsLayer := range manifest.FSLayers {
+		var v1 struct {
+			ID string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(manifest.History[i].V1Compatibility), &v1); err != nil {
+			return nil, err
+		}
+		layers[fsLayer.BlobSum] = v1.ID
+	}
+	return layers, nil
+}
+
+// signedManifest returns the manifest of a tag signed with the daemon's key.
+func (s *TagStore) signedManifest(localName, remoteName, tag string) ([]byte, error) {
+	mBytes, err := s.newManifest(localName, remoteName, tag)
+	if err != nil {
+		return nil, err
+	}
+	js, err := libtrust.NewJSONSignature(mBytes)
+	if err != nil {
+		return nil, err
+	}
+	if err := js.Sign(s.trustKey); err != nil {
+		return nil, err
+	}
+	return js.PrettySignature("signatures")
+}
+
+func (rs *registryServer) serveBlob(w http.ResponseWriter, r *http.Request) {
+	localName, repo, ok := rs.repository(w, r)
+	if !ok {
+		return
+	}
+	var (
+		name   = mux.Vars(r)["name"]
+		digest = mux.Vars(r)["digest"]
+	)
+	id, err := rs.layer(localName, name, repo, digest)
+	if err != nil {
+		writeRegistryError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err.Error())
+		return
+	}
+	if id == "" {
+		writeRegistryError(w, http.StatusNotFound, v2.ErrorCodeBlobUnknown, digest)
+		return
+	}
+
+	path, err := rs.stageBlob(id)
+	if err != nil {
+		writeRegistryError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err.Error())
+		return
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		writeRegistryError(w, http.StatusInternalServerError, v2.ErrorCodeUnknown, err.Error())
+		return
+	}
+	defer f.Close()
+	w.Header().Set("Docker-Content-Digest", digest)
+	// The length of the staged blob is known, so byte ranges can be served
+	// to clients resuming a download
+	http.ServeContent(w, r, "", time.Time{}, f)
+}
+
+// layer returns the ID of the layer of repo whose blob sum is digest, or an
+// empty ID if none has it.  Only the blobs the manifests of the repository
+// reference are served.
+func (rs *registryServer) layer(localName, remoteName string, repo Repository, digest string) (string, error) {
+	rs.Lock()
+	id, indexed := rs.layers[localName][strings.ToLower(digest)]
+	rs.Unlock()
+	if !indexed {
+		if err := rs.indexRepository(localName, remoteName, repo); err != nil {
+			return "", err
+		}
+		rs.Lock()
+		id = rs.layers[localName][strings.ToLower(digest)]
+		rs.Unlock()
+	}
+	// Layers of deleted images are no longer served
+	if id == "" || !rs.store.graph.Exists(id) {
+		return "", nil
+	}
+	return id, nil
+}
+
+// stageBlob returns the path of the file where the blob of layer id is
+// staged, the first time it is requested.
+func (rs *registryServer) stageBlob(id string) (string, error) {
+	rs.Lock()
+	blob := rs.blobs[id]
+	if blob == nil {
+		blob = &servedBlob{path: filepath.Join(rs.blobsDir, id)}
+		rs.blobs[id] = blob
+	}
+	rs.Unlock()
+
+	blob.once.Do(func() {
+		blob.err = rs.writeBlob(id, blob.path)
+	})
+	if blob.err != nil {
+		// Let the next request try again
+		rs.Lock()
+		if rs.blobs[id] == blob {
+			delete(rs.blobs, id)
+		}
+		rs.Unlock()
+		return "", blob.err
+	}
+	return blob.path, nil
+}
+
+// writeBlob writes the layer id to the file at path.
+func (rs *registryServer) writeBlob(id, path string) error {
+	img, err := rs.store.graph.Get(id)
+	if err != nil {
+		return err
+	}
+	arch, err := img.TarLayer()
+	if err != nil {
+		return err
+	}
+	defer arch.Close()
+
+	f, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := bufferToFile(f, arch); err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,272 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/docker/docker/pkg/tarsum"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
+)
+
+func TestServeRegistry(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	if store.trustKey, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("myteam/app", "1.0", testOfficialImageID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := store.registryHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	get := func(method, path string, expected int) (*http.Response, []byte) {
+		req, err := http.NewRequest(method, server.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, err := ioutil.ReadAll(res.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.StatusCode != expected {
+			t.Fatalf("%s %s: expected status %d, got %d: %s", method, path, expected, res.StatusCode, body)
+		}
+		if v := res.Header.Get("Docker-Distribution-API-Version"); v != "registry/2.0" {
+			t.Fatalf("%s %s: unexpected API version %q", method, path, v)
+		}
+		return res, body
+	}
+
+	get("GET", "/v2/", http.StatusOK)
+
+	_, body := get("GET", "/v2/myteam/app/tags/list", http.StatusOK)
+	var tags struct {
+		Name string
+		Tags []string
+	}
+	if err := json.Unmarshal(body, &tags); err != nil {
+		t.Fatal(err)
+	}
+	if tags.Name != "myteam/app" || len(tags.Tags) != 1 || tags.Tags[0] != "1.0" {
+		t.Fatalf("Unexpected tag list %s", body)
+	}
+	get("GET", "/v2/myteam/missing/tags/list", http.StatusNotFound)
+
+	// Official repositories are listed under library/, and the repositories
+	// of other registries are not listed
+	res, body := get("GET", "/v2/_catalog?n=1", http.StatusOK)
+	var catalog struct {
+		Repositories []string
+	}
+	if err := json.Unmarshal(body, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Repositories) != 1 || catalog.Repositories[0] != "library/"+testOfficialImageName || res.Header.Get("Link") == "" {
+		t.Fatalf("Unexpected catalog %s", body)
+	}
+	res, body = get("GET", "/v2/_catalog?n=1&last=library/"+testOfficialImageName, http.StatusOK)
+	if err := json.Unmarshal(body, &catalog); err != nil {
+		t.Fatal(err)
+	}
+	if len(catalog.Repositories) != 1 || catalog.Repositories[0] != "myteam/app" || res.Header.Get("Link") != "" {
+		t.Fatalf("Unexpected catalog %s", body)
+	}
+
+	res, manifestBytes := get("GET", "/v2/myteam/app/manifests/1.0", http.StatusOK)
+	digest, err := registry.ManifestDigest(manifestBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Header.Get("Docker-Content-Digest") != digest {
+		t.Fatalf("Expected digest %s, got %s", digest, res.Header.Get("Docker-Content-Digest"))
+	}
+	get("GET", "/v2/myteam/app/manifests/"+digest, http.StatusOK)
+	get("GET", "/v2/myteam/app/manifests/2.0", http.StatusNotFound)
+	get("PUT", "/v2/myteam/app/manifests/1.0", http.StatusMethodNotAllowed)
+	get("POST", "/v2/myteam/app/blobs/uploads/", http.StatusMethodNotAllowed)
+
+	sig, err := libtrust.ParsePrettySignature(manifestBytes, "signatures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := sig.Payload()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var manifest registry.ManifestData
+	if err := json.Unmarshal(payload, &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	blobSum := manifest.FSLayers[0].BlobSum
+	_, blob := get("GET", "/v2/myteam/app/blobs/"+blobSum, http.StatusOK)
+	ts, err := tarsum.NewTarSumForLabel(bytes.NewReader(blob), true, strings.SplitN(blobSum, ":", 2)[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.Copy(ioutil.Discard, ts); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Sum(nil) != blobSum {
+		t.Fatalf("Expected the blob to match %s, got %s", blobSum, ts.Sum(nil))
+	}
+	get("GET", "/v2/myteam/app/blobs/tarsum.v1+sha256:0000", http.StatusNotFound)
+}
+
+func TestServeRegistryMissingImage(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+	if store.trustKey, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+		t.Fatal(err)
+	}
+	store.Repositories["broken"] = Repository{"latest": "missing"}
+
+	handler, err := store.registryHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a broken repository is an error, rather than a blob which is not found
+	for path, expected := range map[string]int{
+		"/v2/library/broken/blobs/tarsum.v1+sha256:0000":                   http.StatusInternalServerError,
+		"/v2/library/" + testOfficialImageName + "/blobs/tarsum.v1+sha256:0000": http.StatusNotFound,
+	} {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Fatalf("GET %s: expected status %d, got %d: %s", path, expected, w.Code, w.Body)
+		}
+	}
+}
+
+func TestServeRegistryStoredManifest(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+	if store.trustKey, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Set("myteam/app", "1.0", testOfficialImageID, false); err != nil {
+		t.Fatal(err)
+	}
+
+	// The manifest the tag was pulled with, signed by the upstream registry
+	upstreamKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mBytes, err := store.newManifest("myteam/app", "myteam/app", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := libtrust.NewJSONSignature(mBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := js.Sign(upstreamKey); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.setManifest("myteam/app", "1.0", stored); err != nil {
+		t.Fatal(err)
+	}
+	digest, err := registry.ManifestDigest(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler, err := store.registryHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	get := func(path string, expected int) *httptest.ResponseRecorder {
+		req, err := http.NewRequest("GET", path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != expected {
+			t.Fatalf("GET %s: expected status %d, got %d: %s", path, expected, w.Code, w.Body)
+		}
+		return w
+	}
+
+	for _, ref := range []string{digest, "1.0"} {
+		w := get("/v2/myteam/app/manifests/"+ref, http.StatusOK)
+		if !bytes.Equal(w.Body.Bytes(), stored) || w.Header().Get("Docker-Content-Digest") != digest {
+			t.Fatalf("Expected the stored manifest to be served as is for %s, got %s", ref, w.Body)
+		}
+	}
+
+	// Blobs are staged once, and served from the staged file
+	layers, err := manifestLayers(stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var blobSum string
+	for sum := range layers {
+		blobSum = sum
+	}
+	blob := get("/v2/myteam/app/blobs/"+blobSum, http.StatusOK).Body.Bytes()
+	staged := filepath.Join(store.graph.Root, "_tmp", "registry-blobs", layers[blobSum])
+	if err := ioutil.WriteFile(staged, blob[:10], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if served := get("/v2/myteam/app/blobs/"+blobSum, http.StatusOK).Body.Bytes(); !bytes.Equal(served, blob[:10]) {
+		t.Fatal("Expected the blob to be served from the staged file")
+	}
+
+	// Moving the tag drops the stored manifest, and the one of the new
+	// image is generated
+	if err := store.Set("myteam/app", "1.0", testPrivateImageID, true); err != nil {
+		t.Fatal(err)
+	}
+	w := get("/v2/myteam/app/manifests/1.0", http.StatusOK)
+	if w.Header().Get("Docker-Content-Digest") == digest {
+		t.Fatal("Expected a new manifest for the new image of the tag")
+	}
+	get("/v2/myteam/app/manifests/"+digest, http.StatusNotFound)
+}
@@ -11,20 +11,22 @@ import (
 
 func (s *TagStore) Install(eng *engine.Engine) error {
 	for name, handler := range map[string]engine.Handler{
-		"image_set":      s.CmdSet,
-		"image_tag":      s.CmdTag,
-		"tag":            s.CmdTagLegacy, // FIXME merge with "image_tag"
-		"image_get":      s.CmdGet,
-		"image_inspect":  s.CmdLookup,
-		"image_tarlayer": s.CmdTarLayer,
-		"image_export":   s.CmdImageExport,
-		"history":        s.CmdHistory,
-		"images":         s.CmdImages,
-		"viz":            s.CmdViz,
-		"load":           s.CmdLoad,
-		"import":         s.CmdImport,
-		"pull":           s.CmdPull,
-		"push":           s.CmdPush,
+		"image_set":        s.CmdSet,
+		"image_tag":        s.CmdTag,
+		"tag":              s.CmdTagLegacy, // FIXME merge with "image_tag"
+		"image_get":        s.CmdGet,
+		"image_inspect":    s.CmdLookup,
+		"image_tarlayer":   s.CmdTarLayer,
+		"image_export":     s.CmdImageExport,
+		"history":          s.CmdHistory,
+		"image_signatures": s.CmdSignatures,
+		"images":           s.CmdImages,
+		"viz":              s.CmdViz,
+		"load":             s.CmdLoad,
+		"import":           s.CmdImport,
+		"pull":             s.CmdPull,
+		"push":             s.CmdPush,
+		"registry_serve":   s.CmdServeRegistry,
 	} {
 		if err := eng.Register(name, handler); err != nil {
 			return fmt.Errorf("Could not register %q: %v", name, err)
@@ -150,6 +152,8 @@ func (s *TagStore) CmdLookup(job *engine.Job) engine.Status {
 		out.Set("Os", image.OS)
 		out.SetInt64("Size", image.Size)
 		out.SetInt64("VirtualSize", image.GetParentsSize(0)+image.Size)
+		out.Set("BuildInstruction", image.BuildInstruction)
+		out.Set("ContextChecksum", image.ContextChecksum)
 		if _, err = out.WriteTo(job.Stdout); err != nil {
 			return job.Error(err)
 		}
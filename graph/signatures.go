@@ -0,0 +1,86 @@
+package graph
+
+import (
+	"encoding/json"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/pkg/parsers"
+	"github.com/docker/docker/registry"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
+)
+
+// CmdSignatures lists the keys which signed the manifest a repository
+// reference was last pulled or pushed with, along with the result of checking
+// each key against the trust graph for the repository.
+func (s *TagStore) CmdSignatures(job *engine.Job) engine.Status {
+	if n := len(job.Args); n != 1 {
+		return job.Errorf("Usage: %s NAME", job.Name)
+	}
+	repoName, ref := parsers.ParseRepositoryTag(job.Args[0])
+	if ref == "" {
+		ref = DEFAULTTAG
+	}
+	name := utils.ImageReference(repoName, ref)
+	img, err := s.GetImage(repoName, ref)
+	if err != nil {
+		return job.Error(err)
+	}
+	if img == nil {
+		return job.Errorf("No such image: %s", name)
+	}
+	repoInfo, err := registry.ParseRepositoryInfo(repoName)
+	if err != nil {
+		return job.Error(err)
+	}
+	manifestBytes, err := s.getManifest(repoInfo.LocalName, ref)
+	if err != nil {
+		return job.Error(err)
+	}
+	if manifestBytes == nil {
+		return job.Errorf("No signed manifest for %s: it was not pulled from or pushed to a v2 registry", name)
+	}
+
+	sig, err := libtrust.ParsePrettySignature(manifestBytes, "signatures")
+	if err != nil {
+		return job.Errorf("error parsing payload: %s", err)
+	}
+	keys, err := sig.Verify()
+	if err != nil {
+		return job.Errorf("error verifying payload: %s", err)
+	}
+	payload, err := sig.Payload()
+	if err != nil {
+		return job.Errorf("error retrieving payload: %s", err)
+	}
+	var manifest registry.ManifestData
+	if err := json.Unmarshal(payload, &manifest); err != nil {
+		return job.Errorf("error unmarshalling manifest: %s", err)
+	}
+	digest, err := registry.ManifestDigest(manifestBytes)
+	if err != nil {
+		return job.Error(err)
+	}
+
+	type signature struct {
+		KeyID  string
+		Status string
+	}
+	signatures := make([]signature, 0, len(keys))
+	for _, key := range keys {
+		status, err := checkKey(job.Eng, trustNamespace(manifest.Name), key)
+		if err != nil {
+			return job.Error(err)
+		}
+		signatures = append(signatures, signature{KeyID: key.KeyID(), Status: status})
+	}
+
+	out := &engine.Env{}
+	out.Set("Name", name)
+	out.Set("Digest", digest)
+	out.SetJson("Signatures", signatures)
+	if _, err := out.WriteTo(job.Stdout); err != nil {
+		return job.Error(err)
+	}
+	return engine.StatusOK
+}
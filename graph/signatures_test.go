@@ -0,0 +1,112 @@
+package graph
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/docker/docker/engine"
+	"github.com/docker/docker/trust"
+	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
+)
+
+func TestSignatures(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	eng := engine.New()
+	trustStore, err := trust.NewTrustStore(filepath.Join(tmp, "trust"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := trustStore.Install(eng); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Install(eng); err != nil {
+		t.Fatal(err)
+	}
+	if store.trustKey, err = libtrust.GenerateECP256PrivateKey(); err != nil {
+		t.Fatal(err)
+	}
+	signKey, err := libtrust.GenerateECP256PrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	trustStore.SetPolicy(trust.Policy{"/myteam": {signKey.KeyID()}})
+
+	if err := store.Set("myteam/app", "1.0", testOfficialImageID, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := eng.Job("image_signatures", "myteam/app:1.0").Run(); err == nil {
+		t.Fatal("Expected an error for a tag without a signed manifest")
+	}
+
+	mBytes, err := store.newManifest("myteam/app", "myteam/app", "1.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	js, err := libtrust.NewJSONSignature(mBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []libtrust.PrivateKey{store.trustKey, signKey} {
+		if err := js.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+	}
+	manifestBytes, err := js.PrettySignature("signatures")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.setManifest("myteam/app", "1.0", manifestBytes); err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := bytes.NewBuffer(nil)
+	job := eng.Job("image_signatures", "myteam/app:1.0")
+	job.Stdout.Add(stdout)
+	if err := job.Run(); err != nil {
+		t.Fatal(err)
+	}
+	var out engine.Env
+	if err := out.Decode(stdout); err != nil {
+		t.Fatal(err)
+	}
+	if out.Get("Name") != "myteam/app:1.0" || out.Get("Digest") == "" {
+		t.Fatalf("Unexpected name or digest: %v", out)
+	}
+	var signatures []struct {
+		KeyID  string
+		Status string
+	}
+	if err := out.GetJson("Signatures", &signatures); err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{
+		store.trustKey.KeyID(): "no graph",
+		signKey.KeyID():        "verified",
+	}
+	if len(signatures) != len(expected) {
+		t.Fatalf("Expected %d signatures, got %v", len(expected), signatures)
+	}
+	for _, sig := range signatures {
+		if expected[sig.KeyID] != sig.Status {
+			t.Fatalf("Expected key %s to be %q, got %q", sig.KeyID, expected[sig.KeyID], sig.Status)
+		}
+	}
+
+	// Moving the tag to another image drops the signatures
+	if err := store.Set("myteam/app", "1.0", testPrivateImageID, true); err != nil {
+		t.Fatal(err)
+	}
+	if manifestBytes, err := store.getManifest("myteam/app", "1.0"); err != nil || manifestBytes != nil {
+		t.Fatalf("Expected the manifest to be removed with the retag, got %q (%v)", manifestBytes, err)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -14,6 +15,7 @@ import (
 	"github.com/docker/docker/image"
 	"github.com/docker/docker/pkg/parsers"
 	"github.com/docker/docker/registry"
+	"github.com/docker/docker/registry/v2"
 	"github.com/docker/docker/utils"
 	"github.com/docker/libtrust"
 )
@@ -34,8 +36,13 @@ type TagStore struct {
 	// to a helper type
 	pullingPool map[string]chan struct{}
 	pushingPool map[string]chan struct{}
+	// downloadSlots bounds the number of layers downloaded at the same
+	// time by all the pulls
+	downloadSlots chan struct{}
 }
 
+// Repository maps the tags and the content digests of a repository to image
+// IDs.  Digests are told apart from tags by the colon they contain.
 type Repository map[string]string
 
 // update Repository mapping with content of u
@@ -63,12 +70,13 @@ func NewTagStore(path string, graph *Graph, key libtrust.PrivateKey) (*TagStore,
 	}
 
 	store := &TagStore{
-		path:         abspath,
-		graph:        graph,
-		trustKey:     key,
-		Repositories: make(map[string]Repository),
-		pullingPool:  make(map[string]chan struct{}),
-		pushingPool:  make(map[string]chan struct{}),
+		path:          abspath,
+		graph:         graph,
+		trustKey:      key,
+		Repositories:  make(map[string]Repository),
+		pullingPool:   make(map[string]chan struct{}),
+		pushingPool:   make(map[string]chan struct{}),
+		downloadSlots: make(chan struct{}, DefaultMaxConcurrentDownloads),
 	}
 	// Load the json file if it exists, otherwise create it.
 	if err := store.reload(); os.IsNotExist(err) {
@@ -132,7 +140,7 @@ func (store *TagStore) ByID() map[string][]string {
 	byID := make(map[string][]string)
 	for repoName, repository := range store.Repositories {
 		for tag, id := range repository {
-			name := repoName + ":" + tag
+			name := utils.ImageReference(repoName, tag)
 			if _, exists := byID[id]; !exists {
 				byID[id] = []string{name}
 			} else {
@@ -157,15 +165,9 @@ func (store *TagStore) DeleteAll(id string) error {
 		return nil
 	}
 	for _, name := range names {
-		if strings.Contains(name, ":") {
-			nameParts := strings.Split(name, ":")
-			if _, err := store.Delete(nameParts[0], nameParts[1]); err != nil {
-				return err
-			}
-		} else {
-			if _, err := store.Delete(name, ""); err != nil {
-				return err
-			}
+		repoName, tag := parsers.ParseRepositoryTag(name)
+		if _, err := store.Delete(repoName, tag); err != nil {
+			return err
 		}
 	}
 	return nil
@@ -186,12 +188,14 @@ func (store *TagStore) Delete(repoName, tag string) (bool, error) {
 				if len(r) == 0 {
 					delete(store.Repositories, repoName)
 				}
+				os.Remove(store.manifestPath(repoName, tag))
 				deleted = true
 			} else {
-				return false, fmt.Errorf("No such tag: %s:%s", repoName, tag)
+				return false, fmt.Errorf("No such tag: %s", utils.ImageReference(repoName, tag))
 			}
 		} else {
 			delete(store.Repositories, repoName)
+			os.RemoveAll(filepath.Dir(store.manifestPath(repoName, "")))
 			deleted = true
 		}
 	} else {
@@ -201,21 +205,35 @@ func (store *TagStore) Delete(repoName, tag string) (bool, error) {
 }
 
 func (store *TagStore) Set(repoName, tag, imageName string, force bool) error {
+	if tag == "" {
+		tag = DEFAULTTAG
+	}
+	if err := ValidateTagName(tag); err != nil {
+		return err
+	}
+	return store.setReference(repoName, tag, imageName, force)
+}
+
+// SetDigest records that the content digest of repoName refers to imageName.
+// A digest always refers to the same content, so an existing reference is
+// silently replaced.
+func (store *TagStore) SetDigest(repoName, digest, imageName string) error {
+	if err := validateDigest(digest); err != nil {
+		return err
+	}
+	return store.setReference(repoName, digest, imageName, true)
+}
+
+func (store *TagStore) setReference(repoName, tag, imageName string, force bool) error {
 	img, err := store.LookupImage(imageName)
 	store.Lock()
 	defer store.Unlock()
 	if err != nil {
 		return err
 	}
-	if tag == "" {
-		tag = DEFAULTTAG
-	}
 	if err := validateRepoName(repoName); err != nil {
 		return err
 	}
-	if err := ValidateTagName(tag); err != nil {
-		return err
-	}
 	if err := store.reload(); err != nil {
 		return err
 	}
@@ -225,6 +243,9 @@ func (store *TagStore) Set(repoName, tag, imageName string, force bool) error {
 		repo = r
 		if old, exists := store.Repositories[repoName][tag]; exists && !force {
 			return fmt.Errorf("Conflict: Tag %s is already set to image %s, if you want to replace it, please use -f option", tag, old)
+		} else if exists && old != img.ID {
+			// The signatures of the previous image do not apply anymore
+			os.Remove(store.manifestPath(repoName, tag))
 		}
 	} else {
 		repo = make(map[string]string)
@@ -234,6 +255,33 @@ func (store *TagStore) Set(repoName, tag, imageName string, force bool) error {
 	return store.save()
 }
 
+// manifestPath returns the path where the signed manifest a reference of
+// repoName was pulled with is kept.  Manifests do not depend on the graph
+// driver, so they are shared by the tag stores of all drivers.
+func (store *TagStore) manifestPath(repoName, ref string) string {
+	return filepath.Join(filepath.Dir(store.path), "manifests", url.QueryEscape(registry.NormalizeLocalName(repoName)), ref+".json")
+}
+
+// setManifest keeps the signed manifest a reference of repoName was pulled
+// with, so that its signatures can be checked again later.
+func (store *TagStore) setManifest(repoName, ref string, manifestBytes []byte) error {
+	p := store.manifestPath(repoName, ref)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(p, manifestBytes, 0600)
+}
+
+// getManifest returns the signed manifest a reference of repoName was pulled
+// with, or nil if it was not pulled from a v2 registry.
+func (store *TagStore) getManifest(repoName, ref string) ([]byte, error) {
+	manifestBytes, err := ioutil.ReadFile(store.manifestPath(repoName, ref))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return manifestBytes, err
+}
+
 func (store *TagStore) Get(repoName string) (Repository, error) {
 	store.Lock()
 	defer store.Unlock()
@@ -259,6 +307,9 @@ func (store *TagStore) GetImage(repoName, tagOrID string) (*image.Image, error)
 	if revision, exists := repo[tagOrID]; exists {
 		return store.graph.Get(revision)
 	}
+	if utils.DigestReference(tagOrID) {
+		return nil, nil
+	}
 	// If no matching tag is found, search through images for a matching image id
 	for _, revision := range repo {
 		if strings.HasPrefix(revision, tagOrID) {
@@ -275,7 +326,7 @@ func (store *TagStore) GetRepoRefs() map[string][]string {
 	for name, repository := range store.Repositories {
 		for tag, id := range repository {
 			shortID := utils.TruncateID(id)
-			reporefs[shortID] = append(reporefs[shortID], fmt.Sprintf("%s:%s", name, tag))
+			reporefs[shortID] = append(reporefs[shortID], utils.ImageReference(name, tag))
 		}
 	}
 	store.Unlock()
@@ -304,6 +355,14 @@ func ValidateTagName(name string) error {
 	return nil
 }
 
+// Validate a content digest of the form algorithm:hex
+func validateDigest(digest string) error {
+	if !v2.DigestRegexpAnchored.MatchString(digest) {
+		return fmt.Errorf("Illegal digest (%s): expected algorithm:hex", digest)
+	}
+	return nil
+}
+
 func (store *TagStore) poolAdd(kind, key string) (chan struct{}, error) {
 	store.Lock()
 	defer store.Unlock()
@@ -174,3 +174,62 @@ func TestInvalidTagName(t *testing.T) {
 		}
 	}
 }
+
+func TestValidateDigest(t *testing.T) {
+	for _, digest := range []string{"sha256:abc123", "tarsum+sha256:ABCDEF0"} {
+		if err := validateDigest(digest); err != nil {
+			t.Errorf("'%s' should've been a valid digest", digest)
+		}
+	}
+	for _, digest := range []string{"sha256", "sha256:", "sha256:xyz", "x sha256:abc", "sha256:abc def"} {
+		if err := validateDigest(digest); err == nil {
+			t.Errorf("'%s' shouldn't have been a valid digest", digest)
+		}
+	}
+}
+
+func TestLookupImageByDigest(t *testing.T) {
+	tmp, err := utils.TestDirectory("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmp)
+	store := mkTestTagStore(tmp, t)
+	defer store.graph.driver.Cleanup()
+
+	digest := "sha256:bc8813ea7b3603864987522f02a76101c17ad122e1c46d790efc0fca78ca7bfb"
+	if err := store.SetDigest(testOfficialImageName, digest, testOfficialImageID); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SetDigest(testOfficialImageName, "latest", testOfficialImageID); err == nil {
+		t.Fatal("Expected an error when setting a tag as a digest")
+	}
+
+	if img, err := store.LookupImage(testOfficialImageName + "@" + digest); err != nil {
+		t.Fatal(err)
+	} else if img.ID != testOfficialImageID {
+		t.Fatalf("Expected ID '%s' found '%s'", testOfficialImageID, img.ID)
+	}
+	if _, err := store.LookupImage(testOfficialImageName + "@sha256:" + testOfficialImageIDShort); err == nil {
+		t.Fatal("Expected a digest not to match by prefix")
+	}
+
+	names := store.ByID()[testOfficialImageID]
+	expected := testOfficialImageName + "@" + digest
+	found := false
+	for _, name := range names {
+		if name == expected {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("Expected %s in %v", expected, names)
+	}
+
+	if _, err := store.Delete(testOfficialImageName, digest); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.LookupImage(testOfficialImageName + "@" + digest); err == nil {
+		t.Fatal("Expected an error looking up a deleted digest")
+	}
+}
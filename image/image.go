@@ -33,6 +33,12 @@ type Image struct {
 	OS              string            `json:"os,omitempty"`
 	Size            int64
 
+	// The Dockerfile instruction the image was built with, and the checksum
+	// of the files of the context it used, which builds match against when
+	// the image is one of their --cache-from images
+	BuildInstruction string `json:"build_instruction,omitempty"`
+	ContextChecksum  string `json:"context_checksum,omitempty"`
+
 	graph Graph
 }
 
@@ -91,6 +97,11 @@ func StoreImage(img *Image, layerData archive.ArchiveReader, root string) (err e
 		return err
 	}
 
+	return img.SaveJSON(root)
+}
+
+// SaveJSON stores the metadata of `img` in the directory `root`.
+func (img *Image) SaveJSON(root string) error {
 	f, err := os.OpenFile(jsonPath(root), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.FileMode(0600))
 	if err != nil {
 		return err
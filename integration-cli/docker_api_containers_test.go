@@ -300,6 +300,85 @@ func TestGetContainerStats(t *testing.T) {
 	logDone("container REST API - check GET containers/stats")
 }
 
+func TestGetContainerStatsNoStream(t *testing.T) {
+	defer deleteAllContainers()
+	name := "statscontainer"
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "-d", "--name", name, "busybox", "top")); err != nil {
+		t.Fatalf("Error on container creation: %v, output: %q", err, out)
+	}
+
+	type b struct {
+		body []byte
+		err  error
+	}
+	bc := make(chan b, 1)
+	go func() {
+		body, err := sockRequest("GET", "/containers/"+name+"/stats?stream=false", nil)
+		bc <- b{body, err}
+	}()
+
+	// the response ends after a single sample, without removing the container
+	select {
+	case <-time.After(10 * time.Second):
+		t.Fatal("stats with stream=false did not return")
+	case sr := <-bc:
+		if sr.err != nil {
+			t.Fatal(sr.err)
+		}
+		dec := json.NewDecoder(bytes.NewBuffer(sr.body))
+		var s *stats.Stats
+		if err := dec.Decode(&s); err != nil {
+			t.Fatal(err)
+		}
+		if s.Read.IsZero() {
+			t.Fatalf("Expected a stats sample, got %s", sr.body)
+		}
+		if err := dec.Decode(&s); err != io.EOF {
+			t.Fatalf("Expected exactly one stats sample, got %s", sr.body)
+		}
+	}
+	logDone("container REST API - check GET containers/stats with stream=false")
+}
+
+func TestGetContainersStatsAll(t *testing.T) {
+	deleteAllContainers()
+	defer deleteAllContainers()
+
+	running := map[string]bool{}
+	for _, name := range []string{"statsrunning1", "statsrunning2"} {
+		out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "-d", "--name", name, "busybox", "top"))
+		if err != nil {
+			t.Fatalf("Error on container creation: %v, output: %q", err, out)
+		}
+		running[strings.TrimSpace(out)] = true
+	}
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--name", "statsstopped", "busybox", "true")); err != nil {
+		t.Fatalf("Error on container creation: %v, output: %q", err, out)
+	}
+
+	body, err := sockRequest("GET", "/containers/stats", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var all []stats.ContainerStats
+	if err := json.Unmarshal(body, &all); err != nil {
+		t.Fatalf("Error decoding %s: %s", body, err)
+	}
+	if len(all) != len(running) {
+		t.Fatalf("Expected one entry per running container (%d), got %s", len(running), body)
+	}
+	for _, s := range all {
+		if !running[s.Id] {
+			t.Fatalf("Expected only the running containers, got %s", s.Id)
+		}
+		if s.Stats == nil || s.Stats.Read.IsZero() {
+			t.Fatalf("Expected a stats sample for %s, got %s", s.Id, body)
+		}
+		delete(running, s.Id)
+	}
+	logDone("container REST API - check GET /containers/stats returns every running container")
+}
+
 func TestBuildApiDockerfilePath(t *testing.T) {
 	// Test to make sure we stop people from trying to leave the
 	// build context when specifying the path to the dockerfile
@@ -366,3 +445,49 @@ func TestBuildApiDockerfileSymlink(t *testing.T) {
 
 	logDone("container REST API - check build w/bad Dockerfile symlink path")
 }
+
+func TestBuildApiDockerignore(t *testing.T) {
+	// Clients of the API which send the files .dockerignore excludes get
+	// them excluded by the builder
+	name := "testbuildapidockerignore"
+	defer deleteImages(name)
+	buffer := new(bytes.Buffer)
+	tw := tar.NewWriter(buffer)
+	defer tw.Close()
+
+	files := map[string]string{
+		"Dockerfile": `FROM busybox
+ADD . /ctx
+RUN [ ! -e /ctx/docs/index.md ] && [ -f /ctx/docs/README.md ] && [ ! -e /ctx/vendor ] && [ -f /ctx/main.go ]`,
+		".dockerignore":  "**/*.md\n!docs/README.md\nvendor",
+		"docs/index.md":  "index",
+		"docs/README.md": "readme",
+		"vendor/lib.go":  "package lib",
+		"main.go":        "package main",
+	}
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name: name,
+			Size: int64(len(content)),
+			Mode: 0644,
+		}); err != nil {
+			t.Fatalf("failed to write tar file header: %v", err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatalf("failed to write tar file content: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("failed to close tar archive: %v", err)
+	}
+
+	out, err := sockRequestRaw("POST", "/build?t="+name, buffer, "application/x-tar")
+	if err != nil {
+		t.Fatalf("Build failed: %s, %v", out, err)
+	}
+	if !strings.Contains(string(out), "Successfully built") {
+		t.Fatalf("Expected the ignored files to be excluded from the context: %s", out)
+	}
+
+	logDone("container REST API - build honours .dockerignore")
+}
@@ -3373,6 +3373,37 @@ func TestBuildDockerignore(t *testing.T) {
 	logDone("build - test .dockerignore")
 }
 
+func TestBuildDockerignoreExceptions(t *testing.T) {
+	name := "testbuilddockerignoreexceptions"
+	defer deleteImages(name)
+	dockerfile := `
+        FROM busybox
+        ADD . /bla
+		RUN [[ -f /bla/README.md ]]
+		RUN [[ ! -e /bla/docs/index.md ]]
+		RUN [[ ! -e /bla/docs/sources/api.md ]]
+		RUN [[ -f /bla/docs/conf.py ]]
+		RUN [[ ! -e /bla/src/a.go ]]
+		RUN [[ -f /bla/src/keep.go ]]`
+	ctx, err := fakeContext(dockerfile, map[string]string{
+		"README.md":           "readme",
+		"docs/index.md":       "index",
+		"docs/sources/api.md": "api",
+		"docs/conf.py":        "conf",
+		"src/a.go":            "package main",
+		"src/keep.go":         "package main",
+		".dockerignore":       "**/*.md\n!README.md\nsrc\n!src/keep.go",
+	})
+	defer ctx.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := buildImageFromContext(name, ctx, true); err != nil {
+		t.Fatal(err)
+	}
+	logDone("build - test .dockerignore with ** and exceptions")
+}
+
 func TestBuildDockerignoreCleanPaths(t *testing.T) {
 	name := "testbuilddockerignorecleanpaths"
 	defer deleteImages(name)
@@ -3966,6 +3997,38 @@ func TestBuildFromGIT(t *testing.T) {
 	logDone("build - build from GIT")
 }
 
+func TestBuildFromGITRefAndSubdir(t *testing.T) {
+	name := "testbuildfromgitrefandsubdir"
+	defer deleteImages(name)
+	git, err := fakeGIT("repo", map[string]string{
+		"Dockerfile": `FROM busybox
+					MAINTAINER root`,
+		"sub/Dockerfile.sub": `FROM busybox
+					ADD first /first
+					RUN [ -f /first ]
+					MAINTAINER sub`,
+		"sub/first": "test git data",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer git.Close()
+
+	dockerCmd(t, "build", "-t", name, "-f", "Dockerfile.sub", git.RepoURL+"#master:sub")
+	res, err := inspectField(name, "Author")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "sub" {
+		t.Fatalf("Maintainer should be sub, got %s", res)
+	}
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "build", "-t", name, git.RepoURL+"#master:missing")); err == nil || !strings.Contains(out, "does not exist in the repository") {
+		t.Fatalf("Expected a missing subdirectory to fail the build, got %s", out)
+	}
+	logDone("build - build from a GIT ref and subdirectory")
+}
+
 func TestBuildCleanupCmdOnEntrypoint(t *testing.T) {
 	name := "testbuildcmdcleanuponentrypoint"
 	defer deleteImages(name)
@@ -4656,3 +4719,514 @@ func TestBuildDockerfileOutsideContext(t *testing.T) {
 
 	logDone("build - Dockerfile outside context")
 }
+
+func buildImageWithArgs(name, dockerfile string, buildArgs ...string) (string, string, error) {
+	args := []string{"build", "-t", name}
+	for _, arg := range buildArgs {
+		args = append(args, "--build-arg", arg)
+	}
+	buildCmd := exec.Command(dockerBinary, append(args, "-")...)
+	buildCmd.Stdin = strings.NewReader(dockerfile)
+	out, exitCode, err := runCommandWithOutput(buildCmd)
+	if err != nil || exitCode != 0 {
+		return "", out, fmt.Errorf("failed to build the image: %s", out)
+	}
+	id, err := getIDByName(name)
+	return id, out, err
+}
+
+func TestBuildBuildTimeArg(t *testing.T) {
+	name := "testbuildbuildtimearg"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox
+		ARG FOO
+		ARG BAR=default
+		ENV BAZ $BAR
+		RUN [ "$FOO" = "foo" ] && [ "$BAR" = "default" ]`
+
+	id1, _, err := buildImageWithArgs(name, dockerfile, "FOO=foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inspectField(name, "Config.Env")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(res, "FOO") || !strings.Contains(res, "BAZ=default") {
+		t.Fatalf("Expected only the ENV to be committed, got %s", res)
+	}
+
+	// The same arguments use the cache, other values do not
+	id2, out, err := buildImageWithArgs(name, dockerfile, "FOO=foo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id1 != id2 || !strings.Contains(out, "Using cache") {
+		t.Fatalf("Expected the build to be cached: %s", out)
+	}
+	if _, out, err := buildImageWithArgs(name, dockerfile, "FOO=bar"); err == nil {
+		t.Fatalf("Expected the RUN to see the new value: %s", out)
+	}
+
+	logDone("build - build-time arguments")
+}
+
+func TestBuildBuildTimeArgNotDeclared(t *testing.T) {
+	name := "testbuildbuildtimeargnotdeclared"
+	defer deleteImages(name)
+
+	_, out, err := buildImageWithArgs(name, "FROM busybox\nRUN true", "FOO=foo")
+	if err == nil || !strings.Contains(out, "[FOO] were not consumed") {
+		t.Fatalf("Expected an undeclared build-arg to fail the build: %s", out)
+	}
+
+	logDone("build - build-time arguments must be declared")
+}
+
+func TestBuildBuildTimeArgStages(t *testing.T) {
+	name := "testbuildbuildtimeargstages"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox AS builder
+		ARG FOO
+		ARG BAR=default
+		RUN [ "$FOO" = "foo" ] && [ "$BAR" = "default" ]
+
+		FROM busybox
+		ARG FOO
+		ARG BAR
+		RUN [ "$FOO" = "foo" ] && [ -z "$BAR" ]`
+
+	if _, out, err := buildImageWithArgs(name, dockerfile, "FOO=foo"); err != nil {
+		t.Fatalf("Expected the default of BAR to stay in the first stage: %s", out)
+	}
+
+	logDone("build - build-time argument defaults are scoped to their stage")
+}
+
+func TestBuildMultiStage(t *testing.T) {
+	name := "testbuildmultistage"
+	defer deleteImages(name)
+	dockerfile := `FROM busybox AS builder
+		RUN mkdir -p /out && echo built > /out/file
+		LABEL stage=builder
+
+		FROM busybox
+		COPY --from=builder /out/file /from-name
+		COPY --from=0 /out/ /from-index/
+		COPY --from=busybox /bin/busybox /from-image
+		RUN [ "$(cat /from-name)" = "built" ] && [ "$(cat /from-index/file)" = "built" ] && [ -x /from-image ]`
+
+	out, _, err := buildImageWithOut(name, dockerfile, true)
+	if err != nil {
+		t.Fatalf("build failed: %s, %v", out, err)
+	}
+
+	// Only the image of the last stage is tagged, and it does not inherit
+	// the configuration of the earlier stage
+	res, err := inspectField(name, "Config.Labels")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(res, "builder") {
+		t.Fatalf("Expected the labels of the first stage to be left out, got %s", res)
+	}
+
+	logDone("build - multi-stage build with COPY --from")
+}
+
+func TestBuildMultiStageErrors(t *testing.T) {
+	name := "testbuildmultistageerrors"
+	defer deleteImages(name)
+
+	for dockerfile, expected := range map[string]string{
+		"FROM busybox\nCOPY --from=1 /bin/sh /sh":                       "Build stage 1 does not exist",
+		"FROM busybox AS a\nFROM busybox AS A":                          "Duplicate name for build stage",
+		"FROM busybox AS 1st":                                           "Invalid name for build stage",
+		"FROM busybox\nADD --from=busybox /bin/sh /sh":                  "Unknown flag for ADD: --from",
+		"FROM busybox AS a\nFROM busybox\nCOPY --from=a /missing /file": "/missing: no such file or directory",
+	} {
+		if _, out, err := buildImageWithOut(name, dockerfile, true); err == nil || !strings.Contains(out, expected) {
+			t.Fatalf("Expected %q to fail with %q, got %s", dockerfile, expected, out)
+		}
+	}
+
+	logDone("build - multi-stage build errors")
+}
+
+func TestBuildCacheFrom(t *testing.T) {
+	name := "testbuildcachefrom"
+	name2 := "testbuildcachefrom2"
+	defer deleteImages(name, name2)
+	dockerfile := `
+		FROM busybox
+		COPY foo /foo
+		RUN echo bar > /bar`
+	ctx, err := fakeContext(dockerfile, map[string]string{
+		"foo": "foo",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	id1, err := buildImageFromContext(name, ctx, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The instruction and the checksum of the files it copied are recorded
+	res, err := inspectField(id1, "BuildInstruction")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "RUN echo bar > /bar" {
+		t.Fatalf("Expected the RUN instruction to be recorded, got %q", res)
+	}
+	parent, err := inspectField(id1, "Parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res, err = inspectField(parent, "ContextChecksum"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(res, "file:") {
+		t.Fatalf("Expected the checksum of the copied file to be recorded, got %q", res)
+	}
+
+	buildCmd := exec.Command(dockerBinary, "build", "-t", name2, "--cache-from", name, ".")
+	buildCmd.Dir = ctx.Dir
+	out, exitCode, err := runCommandWithOutput(buildCmd)
+	if err != nil || exitCode != 0 {
+		t.Fatalf("failed to build the image: %s", out)
+	}
+	id2, err := getIDByName(name2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id1 != id2 || !strings.Contains(out, "Using cache") {
+		t.Fatalf("Expected the build to use the --cache-from image: %s", out)
+	}
+
+	buildCmd = exec.Command(dockerBinary, "build", "-t", name2, "--cache-from", "127.0.0.1:1/doesnotexist", ".")
+	buildCmd.Dir = ctx.Dir
+	if out, _, err := runCommandWithOutput(buildCmd); err == nil {
+		t.Fatalf("Expected a --cache-from image which can't be pulled to fail the build: %s", out)
+	}
+
+	logDone("build - --cache-from")
+}
+
+func TestBuildStopSignal(t *testing.T) {
+	name := "testbuildstopsignal"
+	defer deleteImages(name)
+	_, err := buildImage(name,
+		`FROM busybox
+		 STOPSIGNAL SIGKILL`,
+		true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inspectField(name, "Config.StopSignal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != "SIGKILL" {
+		t.Fatalf("Signal %s, expected SIGKILL", res)
+	}
+
+	if _, out, err := buildImageWithOut(name, "FROM busybox\nSTOPSIGNAL SIGNOPE", true); err == nil || !strings.Contains(out, "Invalid signal: SIGNOPE") {
+		t.Fatalf("Expected an invalid signal to fail the build, got %s", out)
+	}
+
+	logDone("build - STOPSIGNAL")
+}
+
+func TestBuildHealthcheck(t *testing.T) {
+	name := "testbuildhealthcheck"
+	defer deleteImages(name)
+	_, err := buildImage(name,
+		`FROM busybox
+		 HEALTHCHECK --interval=5s --timeout=3s CMD ls /`,
+		true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := inspectFieldJSON(name, "Config.Healthcheck")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := `{"Test":["CMD-SHELL","ls /"],"Interval":5000000000,"Timeout":3000000000}`; res != expected {
+		t.Fatalf("Healthcheck %s, expected %s", res, expected)
+	}
+
+	if _, err = buildImage(name, "FROM busybox\nHEALTHCHECK NONE", true); err != nil {
+		t.Fatal(err)
+	}
+	if res, err = inspectFieldJSON(name, "Config.Healthcheck.Test"); err != nil {
+		t.Fatal(err)
+	}
+	if res != `["NONE"]` {
+		t.Fatalf("Healthcheck test %s, expected [\"NONE\"]", res)
+	}
+
+	for dockerfile, expected := range map[string]string{
+		"FROM busybox\nHEALTHCHECK --interval=0s CMD ls": "Duration on flag --interval must be positive",
+		"FROM busybox\nHEALTHCHECK --retries=3 CMD ls":   "Unknown flag for HEALTHCHECK: --retries",
+		"FROM busybox\nHEALTHCHECK CMD":                  "HEALTHCHECK CMD requires a command",
+		"FROM busybox\nHEALTHCHECK RUN ls":               "Unknown type \"RUN\" in HEALTHCHECK",
+	} {
+		if _, out, err := buildImageWithOut(name, dockerfile, true); err == nil || !strings.Contains(out, expected) {
+			t.Fatalf("Expected %q to fail with %q, got %s", dockerfile, expected, out)
+		}
+	}
+
+	logDone("build - HEALTHCHECK")
+}
+
+func TestBuildSquash(t *testing.T) {
+	name := "testbuildsquash"
+	defer deleteImages(name)
+	ctx, err := fakeContext(`FROM busybox
+		RUN echo a > /a
+		RUN echo b > /b && rm /a
+		ENV SQUASHED yes`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	buildCmd := exec.Command(dockerBinary, "build", "--squash", "-t", name, ".")
+	buildCmd.Dir = ctx.Dir
+	if out, exitCode, err := runCommandWithOutput(buildCmd); err != nil || exitCode != 0 {
+		t.Fatalf("failed to build the image: %s", out)
+	}
+
+	// The image is a single layer on top of the base image
+	busyboxID, err := getIDByName("busybox")
+	if err != nil {
+		t.Fatal(err)
+	}
+	parent, err := inspectField(name, "Parent")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parent != busyboxID {
+		t.Fatalf("Expected the parent of the squashed image to be busybox (%s), got %s", busyboxID, parent)
+	}
+	comment, err := inspectField(name, "Comment")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(comment, "RUN echo a > /a") || !strings.Contains(comment, "ENV SQUASHED yes") {
+		t.Fatalf("Expected the comment to list the squashed instructions, got %q", comment)
+	}
+
+	// It holds the changes of every layer and keeps the last config
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--rm", name, "sh", "-c", "[ ! -e /a ] && cat /b && echo $SQUASHED"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if out != "b\nyes\n" {
+		t.Fatalf("Expected the squashed layer to hold /b only and the last config, got %q", out)
+	}
+
+	logDone("build - --squash")
+}
+
+func TestBuildJSONProgress(t *testing.T) {
+	name := "testbuildjsonprogress"
+	defer deleteImages(name)
+	ctx, err := fakeContext(`FROM busybox
+		RUN echo hello
+		ENV PROGRESS json`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	type buildMessage struct {
+		BuildStep *struct {
+			Step        int
+			Instruction string
+			Cached      bool
+			ImageID     string `json:"imageId"`
+			ContainerID string `json:"containerId"`
+		}
+		BuildSummary *struct {
+			ImageID     string `json:"imageId"`
+			Steps       int
+			CachedSteps int
+		}
+	}
+	build := func() ([]buildMessage, string) {
+		buildCmd := exec.Command(dockerBinary, "build", "--json-progress", "-t", name, ".")
+		buildCmd.Dir = ctx.Dir
+		out, exitCode, err := runCommandWithOutput(buildCmd)
+		if err != nil || exitCode != 0 {
+			t.Fatalf("failed to build the image: %s", out)
+		}
+		var messages []buildMessage
+		for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+			var msg buildMessage
+			if err := json.Unmarshal([]byte(line), &msg); err != nil {
+				t.Fatalf("Expected a JSON message, got %q: %s", line, err)
+			}
+			if msg.BuildStep != nil || msg.BuildSummary != nil {
+				messages = append(messages, msg)
+			}
+		}
+		id, err := getIDByName(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return messages, id
+	}
+
+	for _, cached := range []bool{false, true} {
+		messages, id := build()
+		if len(messages) != 4 {
+			t.Fatalf("Expected 3 steps and a summary, got %+v", messages)
+		}
+		for i, msg := range messages[:3] {
+			step := msg.BuildStep
+			if step == nil || step.Step != i {
+				t.Fatalf("Expected step %d, got %+v", i, msg)
+			}
+			if i > 0 && step.Cached != cached {
+				t.Fatalf("Expected step %d to be cached: %v, got %+v", i, cached, step)
+			}
+			if step.ImageID == "" {
+				t.Fatalf("Expected step %d to have an image, got %+v", i, step)
+			}
+		}
+		if instruction := messages[1].BuildStep.Instruction; instruction != "RUN echo hello" {
+			t.Fatalf("Expected the instruction of the RUN step, got %q", instruction)
+		}
+		if !cached && messages[1].BuildStep.ContainerID == "" {
+			t.Fatalf("Expected the RUN step to have a container, got %+v", messages[1].BuildStep)
+		}
+		summary := messages[3].BuildSummary
+		if summary == nil || summary.Steps != 3 || summary.ImageID != id {
+			t.Fatalf("Expected a summary of 3 steps building %s, got %+v", id, messages[3])
+		}
+		if cached && summary.CachedSteps != 2 {
+			t.Fatalf("Expected 2 steps from the cache, got %+v", summary)
+		}
+	}
+
+	logDone("build - --json-progress")
+}
+
+func TestBuildJSONProgressFailure(t *testing.T) {
+	name := "testbuildjsonprogressfailure"
+	defer deleteImages(name)
+	ctx, err := fakeContext(`FROM busybox
+		RUN exit 1
+		ENV PROGRESS json`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	buildCmd := exec.Command(dockerBinary, "build", "--json-progress", "-t", name, ".")
+	buildCmd.Dir = ctx.Dir
+	out, exitCode, err := runCommandWithOutput(buildCmd)
+	if err == nil || exitCode == 0 {
+		t.Fatalf("Expected the build to fail, got %s", out)
+	}
+
+	type buildMessage struct {
+		BuildStep *struct {
+			Step     int
+			ImageID  string `json:"imageId"`
+			Duration float64
+			Error    string
+		}
+		BuildSummary *struct {
+			ImageID string `json:"imageId"`
+			Steps   int
+			Error   string
+		}
+	}
+	var messages []buildMessage
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		// The error is printed as text at the end of the output too
+		var msg buildMessage
+		if err := json.Unmarshal([]byte(line), &msg); err != nil {
+			continue
+		}
+		if msg.BuildStep != nil || msg.BuildSummary != nil {
+			messages = append(messages, msg)
+		}
+	}
+	if len(messages) != 3 {
+		t.Fatalf("Expected 2 steps and a summary, got %+v", messages)
+	}
+	if step := messages[0].BuildStep; step == nil || step.Step != 0 || step.Error != "" {
+		t.Fatalf("Expected step 0 to be done, got %+v", messages[0])
+	}
+	step := messages[1].BuildStep
+	if step == nil || step.Step != 1 || step.ImageID != "" || step.Duration <= 0 {
+		t.Fatalf("Expected step 1 to fail, got %+v", messages[1])
+	}
+	if !strings.Contains(step.Error, "returned a non-zero code: 1") {
+		t.Fatalf("Expected the error of the RUN step, got %q", step.Error)
+	}
+	summary := messages[2].BuildSummary
+	if summary == nil || summary.Steps != 1 || summary.ImageID != "" || summary.Error != step.Error {
+		t.Fatalf("Expected a summary of the failure after 1 step, got %+v", messages[2])
+	}
+
+	logDone("build - --json-progress on failure")
+}
+
+func TestBuildCheck(t *testing.T) {
+	ctx, err := fakeContext(`RUN echo before from
+FROM busybox
+RUNN echo typo
+CMD ['echo', 'single quotes']
+ADD foo /foo
+COPY http://example.com/bar /bar
+COPY --chown=1 foo /foo
+ONBUILD FROM busybox`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ctx.Close()
+
+	buildCmd := exec.Command(dockerBinary, "build", "--check", ".")
+	buildCmd.Dir = ctx.Dir
+	out, exitCode, err := runCommandWithOutput(buildCmd)
+	if err == nil || exitCode != 1 {
+		t.Fatalf("Expected the check to fail with exit code 1, got %d: %s", exitCode, out)
+	}
+	for _, expected := range []string{
+		"Dockerfile:1: error: The first instruction must be FROM",
+		"Dockerfile:3: error: Unknown instruction: RUNN",
+		"Dockerfile:4: error: Invalid JSON array",
+		"Dockerfile:5: warning: Use COPY to copy local files",
+		"Dockerfile:6: error: COPY cannot download http://example.com/bar",
+		"Dockerfile:7: error: Unknown flag for COPY: --chown",
+		"Dockerfile:8: error: FROM isn't allowed as an ONBUILD trigger",
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("Expected %q in the output, got %s", expected, out)
+		}
+	}
+	if strings.Contains(out, "Step") {
+		t.Fatalf("Expected no step to run, got %s", out)
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(ctx.Dir, "Dockerfile"), []byte("FROM busybox\nADD foo.tar.gz /\nCOPY foo /foo\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	buildCmd = exec.Command(dockerBinary, "build", "--check", ".")
+	buildCmd.Dir = ctx.Dir
+	if out, exitCode, err := runCommandWithOutput(buildCmd); err != nil || exitCode != 0 {
+		t.Fatalf("Expected the check to pass, got %d: %s", exitCode, out)
+	} else if !strings.Contains(out, "Dockerfile: no problems found") {
+		t.Fatalf("Expected no problems, got %s", out)
+	}
+
+	logDone("build - --check")
+}
@@ -122,3 +122,40 @@ func TestImagesFilterWhiteSpaceTrimmingAndLowerCasingWorking(t *testing.T) {
 
 	logDone("images - white space trimming and lower casing")
 }
+
+func TestImagesFilterLabel(t *testing.T) {
+	imageName1 := "images_filter_test1"
+	imageName2 := "images_filter_test2"
+	defer deleteImages(imageName1)
+	defer deleteImages(imageName2)
+
+	image1ID, err := buildImage(imageName1,
+		`FROM scratch
+		 LABEL match me`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	image2ID, err := buildImage(imageName2,
+		`FROM scratch
+		 LABEL match="me too" other=value`, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := dockerCmd(t, "images", "--no-trunc", "-q", "-f", "label=match=me")
+	if strings.TrimSpace(out) != image1ID {
+		t.Fatalf("Expected id %s, got %q for label filter", image1ID, out)
+	}
+
+	out, _, err = dockerCmd(t, "images", "--no-trunc", "-q", "-f", "label=match")
+	if !strings.Contains(out, image1ID) || !strings.Contains(out, image2ID) {
+		t.Fatalf("Expected ids %s and %s for label filter, got %q", image1ID, image2ID, out)
+	}
+
+	out, _, err = dockerCmd(t, "inspect", "--format={{.Config.Labels.other}}", imageName2)
+	if strings.TrimSpace(out) != "value" {
+		t.Fatalf("Expected the label to be set in the image, got %q", out)
+	}
+
+	logDone("images - filter label")
+}
@@ -73,3 +73,41 @@ func TestKillDifferentUserContainer(t *testing.T) {
 
 	logDone("kill - kill container running sleep 10 from a different user")
 }
+
+func TestKillStopSignal(t *testing.T) {
+	name := "testkillstopsignal"
+	defer deleteAllContainers()
+	defer deleteImages(name)
+	if _, err := buildImage(name,
+		`FROM busybox
+		 STOPSIGNAL SIGUSR1`,
+		true); err != nil {
+		t.Fatal(err)
+	}
+
+	runCmd := exec.Command(dockerBinary, "run", "-d", name, "sh", "-c", "trap 'echo got usr1; exit 0' USR1; while true; do sleep 1; done")
+	out, _, err := runCommandWithOutput(runCmd)
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	cleanedContainerID := stripTrailingCharacters(out)
+	if err := waitRun(cleanedContainerID); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without --signal, the stop signal of the image is sent
+	if out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "kill", cleanedContainerID)); err != nil {
+		t.Fatalf("failed to kill container: %s, %v", out, err)
+	}
+	if err := waitInspect(cleanedContainerID, "{{.State.Running}}", "false", 5); err != nil {
+		t.Fatal(err)
+	}
+	if out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "logs", cleanedContainerID)); err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.Contains(out, "got usr1") {
+		t.Fatalf("Expected the container to receive SIGUSR1, got %q", out)
+	}
+
+	logDone("kill - kill sends the STOPSIGNAL of the image by default")
+}
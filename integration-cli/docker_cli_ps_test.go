@@ -476,3 +476,48 @@ func TestPsListContainersFilterExited(t *testing.T) {
 
 	logDone("ps - test ps filter exited")
 }
+
+func TestPsListContainersFilterLabel(t *testing.T) {
+	defer deleteAllContainers()
+
+	runCmd := exec.Command(dockerBinary, "run", "-d", "-l", "match=me", "-l", "second=tag", "busybox")
+	out, _, err := runCommandWithOutput(runCmd)
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	firstID := stripTrailingCharacters(out)
+
+	runCmd = exec.Command(dockerBinary, "run", "-d", "-l", "match=me too", "busybox")
+	if out, _, err = runCommandWithOutput(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+	secondID := stripTrailingCharacters(out)
+
+	runCmd = exec.Command(dockerBinary, "ps", "-a", "-q", "--no-trunc", "--filter=label=match=me")
+	if out, _, err = runCommandWithOutput(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+	if containerOut := strings.TrimSpace(out); containerOut != firstID {
+		t.Fatalf("Expected id %s, got %s for label filter, output: %q", firstID, containerOut, out)
+	}
+
+	// A label without a value matches any value
+	runCmd = exec.Command(dockerBinary, "ps", "-a", "-q", "--no-trunc", "--filter=label=match")
+	if out, _, err = runCommandWithOutput(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+	if !strings.Contains(out, firstID) || !strings.Contains(out, secondID) {
+		t.Fatalf("Expected ids %s and %s for label filter, output: %q", firstID, secondID, out)
+	}
+
+	// All the label filters must match
+	runCmd = exec.Command(dockerBinary, "ps", "-a", "-q", "--no-trunc", "--filter=label=match", "--filter=label=second=tag")
+	if out, _, err = runCommandWithOutput(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+	if containerOut := strings.TrimSpace(out); containerOut != firstID {
+		t.Fatalf("Expected id %s, got %s for label filters, output: %q", firstID, containerOut, out)
+	}
+
+	logDone("ps - test ps filter label")
+}
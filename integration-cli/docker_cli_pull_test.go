@@ -3,6 +3,7 @@ package main
 import (
 	"fmt"
 	"os/exec"
+	"regexp"
 	"strings"
 	"testing"
 )
@@ -91,6 +92,50 @@ func TestPullVerified(t *testing.T) {
 	logDone("pull - pull verified")
 }
 
+// pulling an image by the digest printed on push should give the same image
+func TestPullByDigest(t *testing.T) {
+	defer setupRegistry(t)()
+
+	repoName := fmt.Sprintf("%v/dockercli/busybox", privateRegistryURL)
+	repo := repoName + ":digest"
+	defer deleteImages(repo)
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "tag", "busybox", repo)); err != nil {
+		t.Fatalf("Failed to tag image: error %v, output %q", err, out)
+	}
+	out, err := exec.Command(dockerBinary, "push", repo).CombinedOutput()
+	if err != nil {
+		t.Fatalf("Failed to push image %v: error %v, output %q", repo, err, string(out))
+	}
+	matches := regexp.MustCompile(`digest: (sha256:[a-f0-9]+)`).FindStringSubmatch(string(out))
+	if len(matches) != 2 {
+		t.Fatalf("Expected a digest in the push output, got %q", string(out))
+	}
+	digestRef := repoName + "@" + matches[1]
+	defer deleteImages(digestRef)
+
+	if out, err := exec.Command(dockerBinary, "rmi", repo).CombinedOutput(); err != nil {
+		t.Fatalf("Failed to clean images: error %v, output %q", err, string(out))
+	}
+
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "pull", digestRef)); err != nil {
+		t.Fatalf("Failed to pull %v: error %v, output %q", digestRef, err, out)
+	}
+	if out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "--rm", digestRef, "true")); err != nil {
+		t.Fatalf("Failed to run %v: error %v, output %q", digestRef, err, out)
+	}
+
+	out2, _, err := runCommandWithOutput(exec.Command(dockerBinary, "images", "--digests", repoName))
+	if err != nil {
+		t.Fatalf("Failed to list images: error %v, output %q", err, out2)
+	}
+	if !strings.Contains(out2, matches[1]) {
+		t.Fatalf("Expected %s in the image list, got %q", matches[1], out2)
+	}
+
+	logDone("pull - pull by digest")
+}
+
 // pulling an image from the central registry should work
 func TestPullImageFromCentralRegistry(t *testing.T) {
 	pullCmd := exec.Command(dockerBinary, "pull", "hello-world")
@@ -3148,3 +3148,33 @@ func TestRunVolumesFromRestartAfterRemoved(t *testing.T) {
 
 	logDone("run - can restart a volumes-from container after producer is removed")
 }
+
+func TestRunHealthcheck(t *testing.T) {
+	name := "testrunhealthcheck"
+	defer deleteAllContainers()
+	defer deleteImages(name)
+	if _, err := buildImage(name,
+		`FROM busybox
+		 HEALTHCHECK --interval=1s --timeout=5s CMD cat /healthy`,
+		true); err != nil {
+		t.Fatal(err)
+	}
+
+	out, _, err := runCommandWithOutput(exec.Command(dockerBinary, "run", "-d", "--name", "probed", name, "sh", "-c", "touch /healthy && top"))
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	if err := waitInspect("probed", "{{.State.Health}}", "healthy", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// The container turns unhealthy once the probe fails
+	if out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "exec", "probed", "rm", "/healthy")); err != nil {
+		t.Fatal(out, err)
+	}
+	if err := waitInspect("probed", "{{.State.Health}}", "unhealthy", 10); err != nil {
+		t.Fatal(err)
+	}
+
+	logDone("run - the daemon runs the HEALTHCHECK of the image")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestUpdateRunningContainerMemory(t *testing.T) {
+	runCmd := exec.Command(dockerBinary, "run", "-d", "-m", "32m", "busybox", "top")
+	out, _, err := runCommandWithOutput(runCmd)
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	id := stripTrailingCharacters(out)
+	defer deleteAllContainers()
+
+	runCmd = exec.Command(dockerBinary, "update", "-m", "64m", id)
+	if out, _, err = runCommandWithOutput(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+
+	memory, err := inspectField(id, "Config.Memory")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if memory != "67108864" {
+		t.Fatalf("Expected memory limit 67108864, got %s", memory)
+	}
+
+	runCmd = exec.Command(dockerBinary, "exec", id, "cat", "/sys/fs/cgroup/memory/memory.limit_in_bytes")
+	out, _, err = runCommandWithOutput(runCmd)
+	if err == nil && strings.TrimSpace(out) != "67108864" {
+		t.Fatalf("Expected cgroup memory limit 67108864, got %s", out)
+	}
+
+	logDone("update - memory limit of a running container")
+}
+
+func TestUpdateStoppedContainer(t *testing.T) {
+	runCmd := exec.Command(dockerBinary, "run", "-d", "busybox", "true")
+	out, _, err := runCommandWithOutput(runCmd)
+	if err != nil {
+		t.Fatal(out, err)
+	}
+	id := stripTrailingCharacters(out)
+	defer deleteAllContainers()
+
+	if out, _, err = runCommandWithOutput(exec.Command(dockerBinary, "wait", id)); err != nil {
+		t.Fatal(out, err)
+	}
+
+	runCmd = exec.Command(dockerBinary, "update", "--cpu-shares", "512", id)
+	if out, _, err = runCommandWithOutput(runCmd); err != nil {
+		t.Fatal(out, err)
+	}
+
+	shares, err := inspectField(id, "Config.CpuShares")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if shares != "512" {
+		t.Fatalf("Expected cpu shares 512, got %s", shares)
+	}
+
+	logDone("update - cpu shares of a stopped container")
+}
@@ -437,7 +437,8 @@ func TarWithOptions(srcPath string, options *TarOptions) (io.ReadCloser, error)
 				}
 
 				if skip {
-					if f.IsDir() {
+					// The files of the directory may be included again by an exception
+					if f.IsDir() && !fileutils.HasExceptions(options.ExcludePatterns) {
 						return filepath.SkipDir
 					}
 					return nil
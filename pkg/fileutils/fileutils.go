@@ -1,26 +1,139 @@
 package fileutils
 
 import (
-	log "github.com/Sirupsen/logrus"
 	"path/filepath"
+	"regexp"
+	"strings"
+
+	log "github.com/Sirupsen/logrus"
 )
 
-// Matches returns true if relFilePath matches any of the patterns
+// Matches returns true if relFilePath is excluded by the patterns, which
+// follow the rules of a .dockerignore file. Patterns are matched with
+// filepath.Match, except that "**" matches any number of directories,
+// including none, and a path is also excluded when one of its parent
+// directories is. Patterns starting with "!" are exceptions, which include
+// again the paths they match; the last pattern that matches a path decides.
 func Matches(relFilePath string, patterns []string) (bool, error) {
-	for _, exclude := range patterns {
-		matched, err := filepath.Match(exclude, relFilePath)
+	relFilePath = filepath.Clean(relFilePath)
+	parentPaths := parentDirs(relFilePath)
+
+	matched := false
+	for _, pattern := range patterns {
+		exception := strings.HasPrefix(pattern, "!")
+		if exception {
+			pattern = pattern[1:]
+		}
+		// Only the patterns which can change the outcome need matching
+		if matched != exception {
+			continue
+		}
+
+		match, err := matchPattern(pattern, relFilePath)
 		if err != nil {
-			log.Errorf("Error matching: %s (pattern: %s)", relFilePath, exclude)
+			log.Errorf("Error matching: %s (pattern: %s)", relFilePath, pattern)
 			return false, err
 		}
-		if matched {
-			if filepath.Clean(relFilePath) == "." {
-				log.Errorf("Can't exclude whole path, excluding pattern: %s", exclude)
-				continue
+		for _, parent := range parentPaths {
+			if match {
+				break
+			}
+			if match, err = matchPattern(pattern, parent); err != nil {
+				return false, err
+			}
+		}
+		if !match {
+			continue
+		}
+
+		if relFilePath == "." {
+			log.Errorf("Can't exclude whole path, excluding pattern: %s", pattern)
+			continue
+		}
+		matched = !exception
+	}
+
+	if matched {
+		log.Debugf("Skipping excluded path: %s", relFilePath)
+	}
+	return matched, nil
+}
+
+// HasExceptions returns true if some of the patterns are exceptions, in which
+// case the files of an excluded directory may still be included.
+func HasExceptions(patterns []string) bool {
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "!") {
+			return true
+		}
+	}
+	return false
+}
+
+// parentDirs returns the parent directories of the relative path, from the
+// top one down.
+func parentDirs(relFilePath string) []string {
+	parts := strings.Split(filepath.ToSlash(relFilePath), "/")
+	parents := make([]string, 0, len(parts)-1)
+	for i := 1; i < len(parts); i++ {
+		parents = append(parents, filepath.FromSlash(strings.Join(parts[:i], "/")))
+	}
+	return parents
+}
+
+// matchPattern matches a single pattern, which may contain "**", against a
+// path.
+func matchPattern(pattern, path string) (bool, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Match(pattern, path)
+	}
+
+	// Validate the rest of the pattern the way filepath.Match would
+	if _, err := filepath.Match(strings.Replace(pattern, "**", "*", -1), ""); err != nil {
+		return false, err
+	}
+	expr, err := patternToRegexp(filepath.ToSlash(pattern))
+	if err != nil {
+		return false, err
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return false, filepath.ErrBadPattern
+	}
+	return re.MatchString(filepath.ToSlash(path)), nil
+}
+
+// patternToRegexp translates a pattern with "**" to an anchored regular
+// expression.
+func patternToRegexp(pattern string) (string, error) {
+	re := "^"
+	for i := 0; i < len(pattern); i++ {
+		ch := pattern[i]
+		switch {
+		case strings.HasPrefix(pattern[i:], "**/"):
+			re += "(.*/)?"
+			i += 2
+		case strings.HasPrefix(pattern[i:], "**"):
+			re += ".*"
+			i++
+		case ch == '*':
+			re += "[^/]*"
+		case ch == '?':
+			re += "[^/]"
+		case ch == '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				return "", filepath.ErrBadPattern
 			}
-			log.Debugf("Skipping excluded path: %s", relFilePath)
-			return true, nil
+			// Character classes have the same syntax in both
+			re += pattern[i : i+end+1]
+			i += end
+		case ch == '\\' && i+1 < len(pattern):
+			i++
+			re += regexp.QuoteMeta(string(pattern[i]))
+		default:
+			re += regexp.QuoteMeta(string(ch))
 		}
 	}
-	return false, nil
+	return re + "$", nil
 }
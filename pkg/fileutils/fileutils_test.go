@@ -0,0 +1,59 @@
+package fileutils
+
+import (
+	"testing"
+)
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		path     string
+		patterns []string
+		expected bool
+	}{
+		{"README.md", []string{"*.md"}, true},
+		{"docs/README.md", []string{"*.md"}, false},
+		{"docs/README.md", []string{"**/*.md"}, true},
+		{"README.md", []string{"**/*.md"}, true},
+		{"docs/sources/index.md", []string{"docs/**/*.md"}, true},
+		{"docs/index.md", []string{"docs/**/*.md"}, true},
+		{"docs/index.txt", []string{"docs/**"}, true},
+		{"doc/index.md", []string{"docs/**"}, false},
+		{".git/HEAD", []string{".git"}, true},
+		{"src/_vendor/v.go", []string{"src/_vendor"}, true},
+		{"src/x.go", []string{"src/_vendor"}, false},
+		{"docs/keep.md", []string{"docs", "!docs/keep.md"}, false},
+		{"docs/other.md", []string{"docs", "!docs/keep.md"}, true},
+		{"docs/keep.md", []string{"docs", "!docs/keep.md", "docs/*.md"}, true},
+		{"a.txt", []string{"*", "!*.txt"}, false},
+		{"a.go", []string{"*", "!*.txt"}, true},
+		{"dir/a.go", []string{"*", "!dir"}, false},
+		{"file", []string{"!file"}, false},
+		{".", []string{"*"}, false},
+	}
+	for _, test := range tests {
+		matched, err := Matches(test.path, test.patterns)
+		if err != nil {
+			t.Fatalf("Error matching %s against %v: %s", test.path, test.patterns, err)
+		}
+		if matched != test.expected {
+			t.Errorf("Expected %s matched against %v to be %t, got %t", test.path, test.patterns, test.expected, matched)
+		}
+	}
+}
+
+func TestMatchesBadPattern(t *testing.T) {
+	for _, pattern := range []string{"[", "**/["} {
+		if _, err := Matches("file", []string{pattern}); err == nil {
+			t.Errorf("Expected pattern %q to be invalid", pattern)
+		}
+	}
+}
+
+func TestHasExceptions(t *testing.T) {
+	if HasExceptions([]string{"docs", "*.md"}) {
+		t.Fatal("Expected no exceptions")
+	}
+	if !HasExceptions([]string{"docs", "!docs/README.md"}) {
+		t.Fatal("Expected an exception")
+	}
+}
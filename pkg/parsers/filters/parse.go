@@ -83,3 +83,26 @@ func (filters Args) Match(field, source string) bool {
 	}
 	return false
 }
+
+// MatchKVList returns true if all the key[=value] filters of field match the
+// sources. A filter without a value matches any value of its key.
+//
+//   `docker ps -f 'label=com.example.team=web' -f 'label=com.example.cost'`
+//
+func (filters Args) MatchKVList(field string, sources map[string]string) bool {
+	fieldValues := filters[field]
+
+	//do not filter if there is no filter set or cannot determine filter
+	if len(fieldValues) == 0 {
+		return true
+	}
+
+	for _, name2match := range fieldValues {
+		testKV := strings.SplitN(name2match, "=", 2)
+		value, exists := sources[testKV[0]]
+		if !exists || (len(testKV) == 2 && value != testKV[1]) {
+			return false
+		}
+	}
+	return true
+}
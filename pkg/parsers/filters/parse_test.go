@@ -76,3 +76,28 @@ func TestEmpty(t *testing.T) {
 		t.Errorf("these should both be empty sets")
 	}
 }
+
+func TestMatchKVList(t *testing.T) {
+	sources := map[string]string{
+		"com.example.team": "web",
+		"com.example.cost": "",
+	}
+	matches := map[*Args]bool{
+		&Args{}:                                  true,
+		&Args{"label": {"com.example.team"}}:     true,
+		&Args{"label": {"com.example.team=web"}}: true,
+		&Args{"label": {"com.example.team=web", "com.example.cost"}}:    true,
+		&Args{"label": {"com.example.team=db"}}:                         false,
+		&Args{"label": {"com.example.team=web", "com.example.missing"}}: false,
+		&Args{"label": {"com.example.cost=something"}}:                  false,
+		&Args{"created": {"today"}, "label": {"com.example.cost="}}:     true,
+	}
+	for args, expected := range matches {
+		if got := args.MatchKVList("label", sources); got != expected {
+			t.Errorf("Expected %v for the filters %v, got %v", expected, *args, got)
+		}
+	}
+	if (Args{"label": {"com.example.team"}}).MatchKVList("label", nil) {
+		t.Error("Expected no match without labels")
+	}
+}
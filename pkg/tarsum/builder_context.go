@@ -10,11 +10,13 @@ type BuilderContext interface {
 }
 
 func (bc *tarSum) Remove(filename string) {
-	for i, fis := range bc.sums {
-		if fis.Name() == filename {
-			bc.sums = append(bc.sums[:i], bc.sums[i+1:]...)
-			// Note, we don't just return because there could be
-			// more than one with this name
+	sums := bc.sums[:0]
+	for _, fis := range bc.sums {
+		// Note, we don't stop at the first match because there could be
+		// more than one with this name
+		if fis.Name() != filename {
+			sums = append(sums, fis)
 		}
 	}
+	bc.sums = sums
 }
@@ -10,9 +10,10 @@ var (
 	}
 )
 
-// IsGitURL returns true if the provided str is a git repository URL.
+// IsGitURL returns true if the provided str is a git repository URL. The URL
+// may end with a "#ref:subdir" fragment.
 func IsGitURL(str string) bool {
-	if IsURL(str) && strings.HasSuffix(str, ".git") {
+	if IsURL(str) && strings.HasSuffix(strings.SplitN(str, "#", 2)[0], ".git") {
 		return true
 	}
 	for _, prefix := range validPrefixes {
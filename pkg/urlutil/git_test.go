@@ -9,6 +9,8 @@ var (
 		"git@bitbucket.org:atlassianlabs/atlassian-docker.git",
 		"https://github.com/docker/docker.git",
 		"http://github.com/docker/docker.git",
+		"https://github.com/docker/docker.git#master:docs",
+		"git@github.com:docker/docker.git#v1.5.0",
 	}
 	incompleteGitUrls = []string{
 		"github.com/docker/docker",
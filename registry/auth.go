@@ -35,8 +35,23 @@ type AuthConfig struct {
 }
 
 type ConfigFile struct {
-	Configs  map[string]AuthConfig `json:"configs,omitempty"`
-	rootPath string
+	Configs map[string]AuthConfig `json:"configs,omitempty"`
+	// CredentialHelpers maps the servers whose credentials are kept by a
+	// credential helper rather than in the config file to the helper's name.
+	CredentialHelpers map[string]string `json:"-"`
+	rootPath          string
+
+	// helperEntries are the entries of the config file of the servers with
+	// a credential helper.  Their credentials are only got from the helper
+	// when the server is looked up, see GetAuthConfig.
+	helperEntries map[string]AuthConfig
+	helperFetched map[string]bool
+}
+
+// configEntry is the entry of a server in the config file.
+type configEntry struct {
+	AuthConfig
+	CredentialHelper string `json:"credhelper,omitempty"`
 }
 
 type RequestAuthorization struct {
@@ -153,7 +168,13 @@ func decodeAuth(authStr string) (string, string, error) {
 // load up the auth config information and return values
 // FIXME: use the internal golang config parser
 func LoadConfig(rootPath string) (*ConfigFile, error) {
-	configFile := ConfigFile{Configs: make(map[string]AuthConfig), rootPath: rootPath}
+	configFile := ConfigFile{
+		Configs:           make(map[string]AuthConfig),
+		CredentialHelpers: make(map[string]string),
+		rootPath:          rootPath,
+		helperEntries:     make(map[string]AuthConfig),
+		helperFetched:     make(map[string]bool),
+	}
 	confFile := path.Join(rootPath, CONFIGFILE)
 	if _, err := os.Stat(confFile); err != nil {
 		return &configFile, nil //missing file is not an error
@@ -163,7 +184,8 @@ func LoadConfig(rootPath string) (*ConfigFile, error) {
 		return &configFile, err
 	}
 
-	if err := json.Unmarshal(b, &configFile.Configs); err != nil {
+	entries := make(map[string]configEntry)
+	if err := json.Unmarshal(b, &entries); err != nil {
 		arr := strings.Split(string(b), "\n")
 		if len(arr) < 2 {
 			return &configFile, fmt.Errorf("The Auth config file is empty")
@@ -186,36 +208,103 @@ func LoadConfig(rootPath string) (*ConfigFile, error) {
 		// *TODO: Switch to using IndexServerName() instead?
 		configFile.Configs[IndexServerAddress()] = authConfig
 	} else {
-		for k, authConfig := range configFile.Configs {
+		for k, entry := range entries {
+			authConfig := entry.AuthConfig
+			authConfig.ServerAddress = k
+			if entry.CredentialHelper != "" {
+				authConfig.Auth = ""
+				configFile.CredentialHelpers[k] = entry.CredentialHelper
+				configFile.helperEntries[k] = authConfig
+				continue
+			}
 			authConfig.Username, authConfig.Password, err = decodeAuth(authConfig.Auth)
 			if err != nil {
 				return &configFile, err
 			}
 			authConfig.Auth = ""
-			authConfig.ServerAddress = k
 			configFile.Configs[k] = authConfig
 		}
 	}
 	return &configFile, nil
 }
 
+// GetAuthConfig returns the credentials of serverAddress, and whether there
+// are any.  The credentials kept by a credential helper are got from it the
+// first time the server is looked up, and added to Configs if it holds any.
+func (config *ConfigFile) GetAuthConfig(serverAddress string) (AuthConfig, bool) {
+	if err := config.loadHelperCredentials(serverAddress); err != nil {
+		log.Errorf("%s", err)
+	}
+	authConfig, ok := config.Configs[serverAddress]
+	return authConfig, ok
+}
+
+// LoadAllCredentials gets the credentials of every server with a credential
+// helper, for when the servers which are used are not known in advance.  A
+// failing helper only leaves its server without credentials.
+func (config *ConfigFile) LoadAllCredentials() error {
+	var firstErr error
+	for serverAddress := range config.helperEntries {
+		if err := config.loadHelperCredentials(serverAddress); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// loadHelperCredentials gets the credentials of serverAddress from its
+// credential helper, if it has one and they were not got yet.
+func (config *ConfigFile) loadHelperCredentials(serverAddress string) error {
+	entry, ok := config.helperEntries[serverAddress]
+	if !ok || config.helperFetched[serverAddress] {
+		return nil
+	}
+	if _, loggedIn := config.Configs[serverAddress]; loggedIn {
+		return nil
+	}
+	config.helperFetched[serverAddress] = true
+	if err := getHelperCredentials(config.CredentialHelpers[serverAddress], serverAddress, &entry); err != nil {
+		return err
+	}
+	if entry.Username != "" || entry.Password != "" {
+		config.Configs[serverAddress] = entry
+	}
+	return nil
+}
+
 // save the auth config
 func SaveConfig(configFile *ConfigFile) error {
 	confFile := path.Join(configFile.rootPath, CONFIGFILE)
-	if len(configFile.Configs) == 0 {
+	if len(configFile.Configs) == 0 && len(configFile.CredentialHelpers) == 0 {
 		os.Remove(confFile)
 		return nil
 	}
 
-	configs := make(map[string]AuthConfig, len(configFile.Configs))
+	configs := make(map[string]configEntry, len(configFile.Configs))
 	for k, authConfig := range configFile.Configs {
 		authCopy := authConfig
 
-		authCopy.Auth = encodeAuth(&authCopy)
+		// The credentials of the servers with a credential helper are
+		// stored with it on login, see StoreCredentials
+		if _, ok := configFile.CredentialHelpers[k]; ok {
+			authCopy.Auth = ""
+		} else {
+			authCopy.Auth = encodeAuth(&authCopy)
+		}
 		authCopy.Username = ""
 		authCopy.Password = ""
 		authCopy.ServerAddress = ""
-		configs[k] = authCopy
+		configs[k] = configEntry{AuthConfig: authCopy, CredentialHelper: configFile.CredentialHelpers[k]}
+	}
+	// Keep the entries of the servers with a helper whose credentials were
+	// not looked up, or which are logged out, so that the next login stores
+	// its credentials with the same helper.
+	for k, helper := range configFile.CredentialHelpers {
+		if _, ok := configs[k]; !ok {
+			entry := configFile.helperEntries[k]
+			entry.ServerAddress = ""
+			configs[k] = configEntry{AuthConfig: entry, CredentialHelper: helper}
+		}
 	}
 
 	b, err := json.MarshalIndent(configs, "", "\t")
@@ -451,7 +540,7 @@ func tryV2TokenAuthLogin(authConfig *AuthConfig, params map[string]string, regis
 func (config *ConfigFile) ResolveAuthConfig(index *IndexInfo) AuthConfig {
 	configKey := index.GetAuthConfigKey()
 	// First try the happy case
-	if c, found := config.Configs[configKey]; found || index.Official {
+	if c, found := config.GetAuthConfig(configKey); found || index.Official {
 		return c
 	}
 
@@ -470,6 +559,13 @@ func (config *ConfigFile) ResolveAuthConfig(index *IndexInfo) AuthConfig {
 
 	// Maybe they have a legacy config file, we will iterate the keys converting
 	// them to the new format and testing
+	for registry := range config.CredentialHelpers {
+		if configKey == convertToHostname(registry) {
+			if c, found := config.GetAuthConfig(registry); found {
+				return c
+			}
+		}
+	}
 	for registry, config := range config.Configs {
 		if configKey == convertToHostname(registry) {
 			return config
@@ -3,6 +3,8 @@ package registry
 import (
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -74,6 +76,254 @@ func TestSameAuthDataPostSave(t *testing.T) {
 	}
 }
 
+// fakeCredentialHelper is a credential helper which keeps the credentials of
+// a single server in the file "store" next to it, and logs the actions it is
+// run with in the file "calls".
+const fakeCredentialHelper = `#!/bin/sh
+dir="$(dirname "$0")"
+store="$dir/store"
+echo "$1" >> "$dir/calls"
+case "$1" in
+get)
+	if [ ! -f "$store" ]; then
+		echo "credentials not found in native keychain"
+		exit 1
+	fi
+	cat "$store"
+	;;
+store)
+	cat > "$store"
+	;;
+erase)
+	rm -f "$store"
+	;;
+esac
+`
+
+// setupCredentialHelper installs fakeCredentialHelper as the helper "fake"
+// in the PATH, and returns the directory it keeps its files in, along with a
+// function which restores the PATH.
+func setupCredentialHelper(t *testing.T, root string) (string, func()) {
+	binDir := filepath.Join(root, "bin")
+	if err := os.Mkdir(binDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(binDir, "docker-credential-fake"), []byte(fakeCredentialHelper), 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", binDir+string(os.PathListSeparator)+path)
+	return binDir, func() { os.Setenv("PATH", path) }
+}
+
+// helperCalls returns the actions the fake helper was run with, and forgets
+// them.
+func helperCalls(t *testing.T, binDir string) string {
+	b, err := ioutil.ReadFile(filepath.Join(binDir, "calls"))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	os.Remove(filepath.Join(binDir, "calls"))
+	return strings.Join(strings.Fields(string(b)), " ")
+}
+
+func TestCredentialHelper(t *testing.T) {
+	configFile, err := setupTempConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configFile.rootPath)
+	binDir, restore := setupCredentialHelper(t, configFile.rootPath)
+	defer restore()
+
+	configFile.CredentialHelpers = map[string]string{"testIndex": "fake"}
+	if err := configFile.StoreCredentials("testIndex"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+	if calls := helperCalls(t, binDir); calls != "store" {
+		t.Fatalf("Expected the helper to store the credentials once, got %q", calls)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(configFile.rootPath, CONFIGFILE))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Count(string(b), encodeAuth(&AuthConfig{Username: "docker-user", Password: "docker-pass"})) != 1 {
+		t.Fatalf("Expected only the credentials without a helper in the config file, got %s", b)
+	}
+
+	loaded, err := LoadConfig(configFile.rootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.CredentialHelpers["testIndex"] != "fake" {
+		t.Fatalf("Expected the helper of testIndex to be loaded, got %v", loaded.CredentialHelpers)
+	}
+	if calls := helperCalls(t, binDir); calls != "" {
+		t.Fatalf("Expected the helper not to run before the server is looked up, got %q", calls)
+	}
+	if _, ok := loaded.GetAuthConfig(IndexServerAddress()); !ok {
+		t.Fatal("Expected the credentials of the config file")
+	}
+	authConfig, ok := loaded.GetAuthConfig("testIndex")
+	if !ok || authConfig.Username != "docker-user" || authConfig.Password != "docker-pass" || authConfig.Email != "docker@docker.io" {
+		t.Fatalf("Unexpected credentials from the helper: %+v", authConfig)
+	}
+	loaded.GetAuthConfig("testIndex")
+	if calls := helperCalls(t, binDir); calls != "get" {
+		t.Fatalf("Expected the helper to be asked for the credentials once, got %q", calls)
+	}
+
+	delete(loaded.Configs, "testIndex")
+	if err := loaded.EraseCredentials("testIndex"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveConfig(loaded); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err = LoadConfig(configFile.rootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.CredentialHelpers["testIndex"] != "fake" {
+		t.Fatal("Expected the helper to be kept after erasing the credentials")
+	}
+	if authConfig, ok := loaded.GetAuthConfig("testIndex"); ok {
+		t.Fatalf("Expected the credentials to be erased, got %+v", authConfig)
+	}
+	if _, ok := loaded.Configs["testIndex"]; ok {
+		t.Fatal("Expected no entry for a server whose helper holds no credentials")
+	}
+}
+
+func TestCredentialHelperNotOverwritten(t *testing.T) {
+	configFile, err := setupTempConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configFile.rootPath)
+	binDir, restore := setupCredentialHelper(t, configFile.rootPath)
+	defer restore()
+
+	configFile.CredentialHelpers = map[string]string{"testIndex": "fake"}
+	if err := configFile.StoreCredentials("testIndex"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+	helperCalls(t, binDir)
+	stored, err := ioutil.ReadFile(filepath.Join(binDir, "store"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logging in to another server saves the config file, which leaves the
+	// credentials of the helper alone, whether they were looked up or not
+	for _, lookup := range []bool{false, true} {
+		loaded, err := LoadConfig(configFile.rootPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if lookup {
+			loaded.GetAuthConfig("testIndex")
+		}
+		loaded.Configs["other"] = AuthConfig{Username: "other-user", Password: "other-pass"}
+		if err := loaded.StoreCredentials("other"); err != nil {
+			t.Fatal(err)
+		}
+		if err := SaveConfig(loaded); err != nil {
+			t.Fatal(err)
+		}
+		if calls := helperCalls(t, binDir); strings.Contains(calls, "store") {
+			t.Fatalf("Expected the helper not to store anything, got %q", calls)
+		}
+		if b, err := ioutil.ReadFile(filepath.Join(binDir, "store")); err != nil || string(b) != string(stored) {
+			t.Fatalf("Expected the credentials of the helper to be unchanged, got %s (%v)", b, err)
+		}
+		reloaded, err := LoadConfig(configFile.rootPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if authConfig, ok := reloaded.GetAuthConfig("testIndex"); !ok || authConfig.Email != "docker@docker.io" {
+			t.Fatalf("Expected the entry of testIndex to be kept, got %+v", authConfig)
+		}
+		helperCalls(t, binDir)
+	}
+
+	// Empty credentials are never stored
+	configFile.Configs["testIndex"] = AuthConfig{}
+	if err := configFile.StoreCredentials("testIndex"); err == nil {
+		t.Fatal("Expected an error storing empty credentials")
+	}
+	if calls := helperCalls(t, binDir); calls != "" {
+		t.Fatalf("Expected the helper not to run, got %q", calls)
+	}
+}
+
+func TestCredentialHelperInvalidName(t *testing.T) {
+	configFile, err := setupTempConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configFile.rootPath)
+	binDir, restore := setupCredentialHelper(t, configFile.rootPath)
+	defer restore()
+
+	for _, helper := range []string{"", ".", "..", "../bin/docker-credential-fake", "bin/fake", "fake/"} {
+		configFile.CredentialHelpers = map[string]string{"testIndex": helper}
+		if err := configFile.StoreCredentials("testIndex"); err == nil || !strings.Contains(err.Error(), "Invalid credential helper name") {
+			t.Fatalf("Expected the helper %q to be refused, got %v", helper, err)
+		}
+	}
+	if calls := helperCalls(t, binDir); calls != "" {
+		t.Fatalf("Expected no helper to run, got %q", calls)
+	}
+	for _, helper := range []string{"fake", "osxkeychain", "secret-service"} {
+		if err := validateCredentialHelper(helper); err != nil {
+			t.Fatalf("Expected the helper %q to be valid, got %s", helper, err)
+		}
+	}
+}
+
+func TestResolveAuthConfigCredentialHelper(t *testing.T) {
+	configFile, err := setupTempConfigFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(configFile.rootPath)
+	binDir, restore := setupCredentialHelper(t, configFile.rootPath)
+	defer restore()
+
+	configFile.Configs["https://registry.example.com/v1/"] = AuthConfig{Username: "helper-user", Password: "helper-pass"}
+	configFile.CredentialHelpers = map[string]string{
+		"https://registry.example.com/v1/": "fake",
+		"unused.example.com":               "fake",
+	}
+	if err := configFile.StoreCredentials("https://registry.example.com/v1/"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SaveConfig(configFile); err != nil {
+		t.Fatal(err)
+	}
+	helperCalls(t, binDir)
+
+	loaded, err := LoadConfig(configFile.rootPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	authConfig := loaded.ResolveAuthConfig(&IndexInfo{Name: "registry.example.com"})
+	if authConfig.Username != "helper-user" || authConfig.Password != "helper-pass" {
+		t.Fatalf("Expected the credentials of the helper, got %+v", authConfig)
+	}
+	if calls := helperCalls(t, binDir); calls != "get" {
+		t.Fatalf("Expected the helper to be run for the server which is used only, got %q", calls)
+	}
+}
+
 func TestResolveAuthConfigIndexServer(t *testing.T) {
 	configFile, err := setupTempConfigFile()
 	if err != nil {
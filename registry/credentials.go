@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// credentialHelperPrefix is prepended to the name of a credential helper to
+// get the executable which is run.
+const credentialHelperPrefix = "docker-credential-"
+
+// errCredentialsNotFound is the message printed by a credential helper when
+// it holds no credentials for a server.
+const errCredentialsNotFound = "credentials not found in native keychain"
+
+// helperCredentials is the message exchanged with a credential helper.
+type helperCredentials struct {
+	ServerURL string
+	Username  string `json:",omitempty"`
+	Secret    string `json:",omitempty"`
+}
+
+// A credential helper is an external executable named
+// docker-credential-<name> which keeps the credentials of a registry in a
+// native store, such as the OS keyring, instead of the config file.  It is
+// run with one of the actions "get", "store" or "erase" as argument, reads a
+// JSON helperCredentials on its standard input and, for "get", writes the
+// credentials it holds as JSON on its standard output.  A helper reports
+// errors by exiting with a non-zero status and printing the message on its
+// standard output.
+func runCredentialHelper(helper, action string, in helperCredentials) ([]byte, error) {
+	if err := validateCredentialHelper(helper); err != nil {
+		return nil, err
+	}
+	input, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(credentialHelperPrefix+helper, action)
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(stdout.String())
+		if msg == "" {
+			msg = strings.TrimSpace(stderr.String())
+		}
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("%s", msg)
+	}
+	return stdout.Bytes(), nil
+}
+
+// validateCredentialHelper checks that the name of a credential helper from
+// the config file only names an executable in the PATH.
+func validateCredentialHelper(helper string) error {
+	if helper == "" || helper == "." || helper == ".." || strings.ContainsAny(helper, "/"+string(os.PathSeparator)) {
+		return fmt.Errorf("Invalid credential helper name: %q", helper)
+	}
+	return nil
+}
+
+// getHelperCredentials fills the username and password of authConfig from
+// the credential helper.  The credentials are left empty when the helper
+// holds none for the server.
+func getHelperCredentials(helper, serverAddress string, authConfig *AuthConfig) error {
+	out, err := runCredentialHelper(helper, "get", helperCredentials{ServerURL: serverAddress})
+	if err != nil {
+		if err.Error() == errCredentialsNotFound {
+			return nil
+		}
+		return fmt.Errorf("Error getting credentials for %s from %s%s: %s", serverAddress, credentialHelperPrefix, helper, err)
+	}
+	var creds helperCredentials
+	if err := json.Unmarshal(out, &creds); err != nil {
+		return fmt.Errorf("Invalid output of %s%s: %s", credentialHelperPrefix, helper, err)
+	}
+	authConfig.Username = creds.Username
+	authConfig.Password = creds.Secret
+	return nil
+}
+
+// storeHelperCredentials saves the username and password of authConfig with
+// the credential helper.  Empty credentials are never stored, so that they
+// cannot replace those the helper holds.
+func storeHelperCredentials(helper, serverAddress string, authConfig AuthConfig) error {
+	if authConfig.Username == "" && authConfig.Password == "" {
+		return fmt.Errorf("Error storing credentials for %s in %s%s: no credentials to store", serverAddress, credentialHelperPrefix, helper)
+	}
+	_, err := runCredentialHelper(helper, "store", helperCredentials{
+		ServerURL: serverAddress,
+		Username:  authConfig.Username,
+		Secret:    authConfig.Password,
+	})
+	if err != nil {
+		return fmt.Errorf("Error storing credentials for %s in %s%s: %s", serverAddress, credentialHelperPrefix, helper, err)
+	}
+	return nil
+}
+
+// StoreCredentials saves the credentials of a server with its credential
+// helper, if it has one, when it is logged in to.  SaveConfig only writes
+// the config file, and leaves the credentials of the helpers unchanged.
+func (config *ConfigFile) StoreCredentials(serverAddress string) error {
+	helper, ok := config.CredentialHelpers[serverAddress]
+	if !ok {
+		return nil
+	}
+	return storeHelperCredentials(helper, serverAddress, config.Configs[serverAddress])
+}
+
+// EraseCredentials removes the credentials of a server from its credential
+// helper, if it has one.  The config file itself is left unchanged.
+func (config *ConfigFile) EraseCredentials(serverAddress string) error {
+	helper, ok := config.CredentialHelpers[serverAddress]
+	if !ok {
+		return nil
+	}
+	if _, err := runCredentialHelper(helper, "erase", helperCredentials{ServerURL: serverAddress}); err != nil && err.Error() != errCredentialsNotFound {
+		return fmt.Errorf("Error erasing credentials for %s from %s%s: %s", serverAddress, credentialHelperPrefix, helper, err)
+	}
+	return nil
+}
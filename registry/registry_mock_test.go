@@ -83,12 +83,22 @@ var (
 			"latest": "42d718c941f5c532ac049bf0b0ab53f0062f09a03afd4aa4a02c098e46032b9d",
 		},
 	}
-	mockHosts = map[string][]net.IP{
+	testSearchResults = []SearchResult{
+		{Name: "fakeimage", StarCount: 42},
+		{Name: "library/fakeofficial", StarCount: 120, IsOfficial: true},
+		{Name: "foo42/fakeautomated", StarCount: 3, IsAutomated: true},
+		{Name: "foo42/faketrusted", StarCount: 7, IsTrusted: true},
+	}
+	testCatalog = []string{"foo42/bar", "foo42/baz", "foo43/bar", "library/ubuntu"}
+	mockHosts   = map[string][]net.IP{
 		"":            {net.ParseIP("0.0.0.0")},
 		"localhost":   {net.ParseIP("127.0.0.1"), net.ParseIP("::1")},
 		"example.com": {net.ParseIP("42.42.42.42")},
 		"other.com":   {net.ParseIP("43.43.43.43")},
 	}
+
+	// endlessSearchPages counts the pages requested from the endless search
+	endlessSearchPages int
 )
 
 func init() {
@@ -108,6 +118,7 @@ func init() {
 
 	// /v2/
 	r.HandleFunc("/v2/version", handlerGetPing).Methods("GET")
+	r.HandleFunc("/v2/_catalog", handlerCatalog).Methods("GET")
 
 	testHTTPServer = httptest.NewServer(handlerAccessLog(r))
 	testHTTPSServer = httptest.NewTLSServer(handlerAccessLog(r))
@@ -310,6 +321,11 @@ func handlerGetImage(w http.ResponseWriter, r *http.Request) {
 	writeHeaders(w)
 	layerSize := len(layer["layer"])
 	w.Header().Add("X-Docker-Size", strconv.Itoa(layerSize))
+	if vars["action"] == "layer" {
+		// Serve layers with support for byte ranges
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(layer["layer"]))
+		return
+	}
 	io.WriteString(w, layer[vars["action"]])
 }
 
@@ -445,12 +461,71 @@ func handlerAuth(w http.ResponseWriter, r *http.Request) {
 }
 
 func handlerSearch(w http.ResponseWriter, r *http.Request) {
-	result := &SearchResults{
-		Query:      "fakequery",
-		NumResults: 1,
-		Results:    []SearchResult{{Name: "fakeimage", StarCount: 42}},
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	if page == 0 {
+		result := &SearchResults{
+			Query:      "fakequery",
+			NumResults: 1,
+			Results:    []SearchResult{{Name: "fakeimage", StarCount: 42}},
+		}
+		writeResponse(w, result, 200)
+		return
+	}
+	if r.URL.Query().Get("q") == "endless" {
+		// An index which always has another page of unstarred results
+		endlessSearchPages++
+		writeResponse(w, &SearchResults{
+			Query:    "endless",
+			NumPages: page + 1,
+			Page:     page,
+			Results:  []SearchResult{{Name: "foo42/endless"}},
+		}, 200)
+		return
+	}
+	// Paged searches are served from testSearchResults, two results at a time
+	var (
+		n        = 2
+		start    = (page - 1) * n
+		numPages = (len(testSearchResults) + n - 1) / n
+		results  = []SearchResult{}
+	)
+	if start < len(testSearchResults) {
+		end := start + n
+		if end > len(testSearchResults) {
+			end = len(testSearchResults)
+		}
+		results = testSearchResults[start:end]
+	}
+	writeResponse(w, &SearchResults{
+		Query:      r.URL.Query().Get("q"),
+		NumResults: len(testSearchResults),
+		NumPages:   numPages,
+		Page:       page,
+		Results:    results,
+	}, 200)
+}
+
+func handlerCatalog(w http.ResponseWriter, r *http.Request) {
+	var (
+		last     = r.URL.Query().Get("last")
+		n, _     = strconv.Atoi(r.URL.Query().Get("n"))
+		repos    = []string{}
+		trailing bool
+	)
+	for _, name := range testCatalog {
+		if name <= last {
+			continue
+		}
+		if n > 0 && len(repos) == n {
+			trailing = true
+			break
+		}
+		repos = append(repos, name)
+	}
+	if trailing {
+		w.Header().Set("Link", fmt.Sprintf("</v2/_catalog?last=%s&n=%d>; rel=\"next\"", url.QueryEscape(repos[len(repos)-1]), n))
 	}
-	writeResponse(w, result, 200)
+	writeResponse(w, map[string][]string{"repositories": repos}, 200)
 }
 
 func TestPing(t *testing.T) {
@@ -2,11 +2,13 @@ package registry
 
 import (
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
 	"testing"
 
+	"github.com/docker/docker/engine"
 	"github.com/docker/docker/utils"
 )
 
@@ -207,7 +209,7 @@ func TestGetRemoteImageJSON(t *testing.T) {
 
 func TestGetRemoteImageLayer(t *testing.T) {
 	r := spawnTestRegistrySession(t)
-	data, err := r.GetRemoteImageLayer(imageID, makeURL("/v1/"), token, 0)
+	data, err := r.GetRemoteImageLayer(imageID, makeURL("/v1/"), token, 0, 0)
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -215,12 +217,30 @@ func TestGetRemoteImageLayer(t *testing.T) {
 		t.Fatal("Expected non-nil data result")
 	}
 
-	_, err = r.GetRemoteImageLayer("abcdef", makeURL("/v1/"), token, 0)
+	_, err = r.GetRemoteImageLayer("abcdef", makeURL("/v1/"), token, 0, 0)
 	if err == nil {
 		t.Fatal("Expected image not found error")
 	}
 }
 
+func TestGetRemoteImageLayerOffset(t *testing.T) {
+	r := spawnTestRegistrySession(t)
+	layer := testLayers[imageID]["layer"]
+	offset := int64(len(layer) / 2)
+	data, err := r.GetRemoteImageLayer(imageID, makeURL("/v1/"), token, int64(len(layer)), offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer data.Close()
+	rest, err := ioutil.ReadAll(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(rest) != layer[offset:] {
+		t.Fatalf("Expected the layer from offset %d, got %d bytes", offset, len(rest))
+	}
+}
+
 func TestGetRemoteTags(t *testing.T) {
 	r := spawnTestRegistrySession(t)
 	tags, err := r.GetRemoteTags([]string{makeURL("/v1/")}, REPO, token)
@@ -738,6 +758,103 @@ func TestSearchRepositories(t *testing.T) {
 	assertEqual(t, results.Results[0].StarCount, 42, "Expected 'fakeimage' a ot hae 42 stars")
 }
 
+func TestSearchLimit(t *testing.T) {
+	eng := engine.New()
+	if err := NewService(nil).Install(eng); err != nil {
+		t.Fatal(err)
+	}
+	for _, limit := range []string{"0", "-1", "101", "many"} {
+		job := eng.Job("search", "fakequery")
+		job.Setenv("limit", limit)
+		if err := job.Run(); err == nil || !strings.Contains(err.Error(), "outside the range of [1, 100]") {
+			t.Fatalf("Expected limit %s to be rejected, got %v", limit, err)
+		}
+	}
+}
+
+func TestSearchFilters(t *testing.T) {
+	r := spawnTestRegistrySession(t)
+
+	f, err := parseSearchFilters(`{"stars":["5"],"is-automated":["true"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := searchIndex(r, "fake", 0, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Automated builds include those still flagged as trusted
+	assertEqual(t, len(results), 1, "Expected 1 search result")
+	assertEqual(t, results[0].Name, "foo42/faketrusted", "Expected the trusted build")
+
+	f, err = parseSearchFilters(`{"stars":["5"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = searchIndex(r, "fake", 2, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(results), 2, "Expected the results to be limited to 2")
+
+	f, err = parseSearchFilters(`{"is-official":["false"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err = searchIndex(r, "fake", 0, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(results), 3, "Expected 3 unofficial search results")
+
+	// Filtered searches stop after maxSearchPages pages
+	f, err = parseSearchFilters(`{"stars":["1"]}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	endlessSearchPages = 0
+	results, err = searchIndex(r, "endless", 0, f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(results), 0, "Expected no search results")
+	assertEqual(t, endlessSearchPages, maxSearchPages, "Expected the search to stop after maxSearchPages pages")
+
+	for _, param := range []string{
+		`{"label":["foo"]}`,
+		`{"stars":["many"]}`,
+		`{"is-official":["maybe"]}`,
+	} {
+		if _, err := parseSearchFilters(param); err == nil {
+			t.Errorf("Expected an error for the filters %s", param)
+		}
+	}
+}
+
+func TestGetV2Catalog(t *testing.T) {
+	r := spawnTestRegistrySession(t)
+	u, err := url.Parse(makeURL(""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ep := &Endpoint{URL: u, Version: APIVersion2}
+	auth := NewRequestAuthorization(&AuthConfig{}, ep, "registry", "catalog", []string{"*"})
+
+	names, more, err := r.GetV2Catalog(ep, 3, "", auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(names), 3, "Expected 3 repositories")
+	assertEqual(t, more, true, "Expected more repositories")
+	names, more, err = r.GetV2Catalog(ep, 3, names[2], auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertEqual(t, len(names), 1, "Expected 1 repository")
+	assertEqual(t, names[0], "library/ubuntu", "Expected the last repository")
+	assertEqual(t, more, false, "Expected no more repositories")
+}
+
 func TestValidRemoteName(t *testing.T) {
 	validRepositoryNames := []string{
 		// Sanity check.
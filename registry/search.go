@@ -0,0 +1,171 @@
+package registry
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/docker/docker/pkg/parsers/filters"
+)
+
+const (
+	// maxSearchLimit is the largest number of results a search returns.
+	maxSearchLimit = 100
+	// searchPageSize is the number of results requested at once when the
+	// results are filtered or listed from a catalog.
+	searchPageSize = 100
+	// defaultSearchLimit is the number of results a filtered or catalog
+	// search returns when no limit is set.
+	defaultSearchLimit = 25
+	// maxSearchPages is the largest number of pages a filtered or catalog
+	// search requests.
+	maxSearchPages = 10
+)
+
+var acceptedSearchFilterTags = map[string]struct{}{
+	"stars":        {},
+	"is-official":  {},
+	"is-automated": {},
+}
+
+// searchFilters are the conditions the results of a search must meet.
+type searchFilters struct {
+	stars       int
+	isOfficial  *bool
+	isAutomated *bool
+}
+
+func parseSearchFilters(param string) (*searchFilters, error) {
+	args, err := filters.FromParam(param)
+	if err != nil {
+		return nil, err
+	}
+	f := &searchFilters{}
+	for name, values := range args {
+		if _, ok := acceptedSearchFilterTags[name]; !ok {
+			return nil, fmt.Errorf("Invalid filter '%s'", name)
+		}
+		for _, value := range values {
+			switch name {
+			case "stars":
+				stars, err := strconv.Atoi(value)
+				if err != nil || stars < 0 {
+					return nil, fmt.Errorf("Invalid filter 'stars=%s'", value)
+				}
+				if stars > f.stars {
+					f.stars = stars
+				}
+			case "is-official", "is-automated":
+				b, err := strconv.ParseBool(value)
+				if err != nil {
+					return nil, fmt.Errorf("Invalid filter '%s=%s'", name, value)
+				}
+				if name == "is-official" {
+					f.isOfficial = &b
+				} else {
+					f.isAutomated = &b
+				}
+			}
+		}
+	}
+	return f, nil
+}
+
+func (f *searchFilters) empty() bool {
+	return f.stars == 0 && f.isOfficial == nil && f.isAutomated == nil
+}
+
+func (f *searchFilters) match(result SearchResult) bool {
+	if result.StarCount < f.stars {
+		return false
+	}
+	if f.isOfficial != nil && result.IsOfficial != *f.isOfficial {
+		return false
+	}
+	// Automated builds used to be called trusted builds
+	if f.isAutomated != nil && (result.IsAutomated || result.IsTrusted) != *f.isAutomated {
+		return false
+	}
+	return true
+}
+
+// searchIndex returns up to limit results of the search for term on the v1
+// index of the session, or all the results of the first page when limit is 0.
+// Filtered results are requested page by page until limit results match, or
+// defaultSearchLimit when limit is 0, for up to maxSearchPages pages.
+func searchIndex(r *Session, term string, limit int, f *searchFilters) ([]SearchResult, error) {
+	if f.empty() {
+		results, err := r.SearchRepositoriesPage(term, limit, 0)
+		if err != nil {
+			return nil, err
+		}
+		if limit > 0 && len(results.Results) > limit {
+			return results.Results[:limit], nil
+		}
+		return results.Results, nil
+	}
+
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	matches := []SearchResult{}
+	for page := 1; page <= maxSearchPages; page++ {
+		results, err := r.SearchRepositoriesPage(term, searchPageSize, page)
+		if err != nil {
+			return nil, err
+		}
+		for _, result := range results.Results {
+			if !f.match(result) {
+				continue
+			}
+			matches = append(matches, result)
+			if len(matches) == limit {
+				return matches, nil
+			}
+		}
+		// Indexes which do not page their results return them all at once
+		if page >= results.NumPages || len(results.Results) == 0 {
+			break
+		}
+	}
+	return matches, nil
+}
+
+// searchCatalog searches for term in the names of the repositories of the
+// v2 registry of index.  The catalog only lists names, so its results have
+// no description and no stars, and are neither official nor automated.  Like
+// filtered searches, it returns up to limit results, or defaultSearchLimit
+// when limit is 0, from up to maxSearchPages pages.
+func searchCatalog(r *Session, index *IndexInfo, term string, limit int, f *searchFilters) ([]SearchResult, error) {
+	ep, err := r.V2RegistryEndpoint(index)
+	if err != nil {
+		return nil, err
+	}
+	auth := NewRequestAuthorization(r.GetAuthConfig(true), ep, "registry", "catalog", []string{"*"})
+
+	if limit == 0 {
+		limit = defaultSearchLimit
+	}
+	matches := []SearchResult{}
+	for page, last := 1, ""; page <= maxSearchPages; page++ {
+		names, more, err := r.GetV2Catalog(ep, searchPageSize, last, auth)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range names {
+			result := SearchResult{Name: name}
+			if !strings.Contains(name, term) || !f.match(result) {
+				continue
+			}
+			matches = append(matches, result)
+			if len(matches) == limit {
+				return matches, nil
+			}
+		}
+		if !more || len(names) == 0 {
+			break
+		}
+		last = names[len(names)-1]
+	}
+	return matches, nil
+}
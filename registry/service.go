@@ -89,22 +89,38 @@ func (s *Service) Auth(job *engine.Job) engine.Status {
 //	'metaHeaders': extra HTTP headers to include in the request to the registry.
 //		The headers should be passed as a json-encoded dictionary.
 //
+//	'limit': maximum number of results to return, between 1 and 100. Without a
+//		limit, the results of the first page of the index are returned.
+//
+//	'filters': json-encoded filters the results must match: 'stars',
+//		'is-official' and 'is-automated'.
+//
 // Output:
 //	Results are sent as a collection of structured messages (using engine.Table).
 //	Each result is sent as a separate message.
 //	Results are ordered by number of stars on the public registry.
+//	Registries without a search fall back to listing their v2 catalog.
 func (s *Service) Search(job *engine.Job) engine.Status {
 	if n := len(job.Args); n != 1 {
 		return job.Errorf("Usage: %s TERM", job.Name)
 	}
 	var (
 		term        = job.Args[0]
+		limit       = job.GetenvInt("limit")
 		metaHeaders = map[string][]string{}
 		authConfig  = &AuthConfig{}
 	)
 	job.GetenvJson("authConfig", authConfig)
 	job.GetenvJson("metaHeaders", metaHeaders)
 
+	if job.Getenv("limit") != "" && (limit < 1 || limit > maxSearchLimit) {
+		return job.Errorf("Limit %d is outside the range of [1, %d]", limit, maxSearchLimit)
+	}
+	searchFilters, err := parseSearchFilters(job.Getenv("filters"))
+	if err != nil {
+		return job.Error(err)
+	}
+
 	repoInfo, err := ResolveRepositoryInfo(job, term)
 	if err != nil {
 		return job.Error(err)
@@ -118,12 +134,21 @@ func (s *Service) Search(job *engine.Job) engine.Status {
 	if err != nil {
 		return job.Error(err)
 	}
-	results, err := r.SearchRepositories(repoInfo.GetSearchTerm())
+	results, err := searchIndex(r, repoInfo.GetSearchTerm(), limit, searchFilters)
+	if err != nil && !repoInfo.Index.Official {
+		// Private v2 registries have no search, but list their repositories
+		log.Debugf("Search failed on %s, falling back to its catalog: %s", repoInfo.Index.Name, err)
+		if catalogResults, catalogErr := searchCatalog(r, repoInfo.Index, repoInfo.GetSearchTerm(), limit, searchFilters); catalogErr == nil {
+			results, err = catalogResults, nil
+		} else {
+			log.Debugf("Error listing the catalog of %s: %s", repoInfo.Index.Name, catalogErr)
+		}
+	}
 	if err != nil {
 		return job.Error(err)
 	}
 	outs := engine.NewTable("star_count", 0)
-	for _, result := range results.Results {
+	for _, result := range results {
 		out := &engine.Env{}
 		out.Import(result)
 		outs.Add(out)
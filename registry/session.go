@@ -151,7 +151,10 @@ func (r *Session) GetRemoteImageJSON(imgID, registry string, token []string) ([]
 	return jsonString, imageSize, nil
 }
 
-func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, imgSize int64) (io.ReadCloser, error) {
+// GetRemoteImageLayer returns the content of a layer starting at offset.  A
+// request from the start of a layer is transparently resumed after transient
+// errors when the registry supports byte ranges.
+func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, imgSize, offset int64) (io.ReadCloser, error) {
 	var (
 		retries    = 5
 		statusCode = 0
@@ -165,6 +168,9 @@ func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, im
 		return nil, fmt.Errorf("Error while getting from the server: %s\n", err)
 	}
 	setTokenAuth(req, token)
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 	for i := 1; i <= retries; i++ {
 		statusCode = 0
 		res, client, err = r.doRequest(req)
@@ -186,6 +192,10 @@ func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, im
 		break
 	}
 
+	if offset > 0 {
+		return rangeBody(res, offset, imgID)
+	}
+
 	if res.StatusCode != 200 {
 		res.Body.Close()
 		return nil, fmt.Errorf("Server error: Status %d while fetching image layer (%s)",
@@ -200,6 +210,24 @@ func (r *Session) GetRemoteImageLayer(imgID, registry string, token []string, im
 	return res.Body, nil
 }
 
+// rangeBody returns the body of a response to a request for the content of a
+// layer from offset.  Registries which ignore the Range header send the whole
+// layer, in which case the bytes before offset are skipped.
+func rangeBody(res *http.Response, offset int64, layer string) (io.ReadCloser, error) {
+	switch res.StatusCode {
+	case 206:
+		return res.Body, nil
+	case 200:
+		if _, err := io.CopyN(ioutil.Discard, res.Body, offset); err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+		return res.Body, nil
+	}
+	res.Body.Close()
+	return nil, fmt.Errorf("Server error: Status %d while resuming %s at offset %d", res.StatusCode, layer, offset)
+}
+
 func (r *Session) GetRemoteTags(registries []string, repository string, token []string) (map[string]string, error) {
 	if strings.Count(repository, "/") == 0 {
 		// This will be removed once the Registry supports auto-resolution on
@@ -568,8 +596,21 @@ func shouldRedirect(response *http.Response) bool {
 }
 
 func (r *Session) SearchRepositories(term string) (*SearchResults, error) {
+	return r.SearchRepositoriesPage(term, 0, 0)
+}
+
+// SearchRepositoriesPage returns a page of n results of the search for term.
+// The index chooses the page size when n is 0, and returns the first page
+// when page is 0.
+func (r *Session) SearchRepositoriesPage(term string, n, page int) (*SearchResults, error) {
 	log.Debugf("Index server: %s", r.indexEndpoint)
 	u := r.indexEndpoint.VersionString(1) + "search?q=" + url.QueryEscape(term)
+	if n > 0 {
+		u += "&n=" + strconv.Itoa(n)
+	}
+	if page > 0 {
+		u += "&page=" + strconv.Itoa(page)
+	}
 	req, err := r.reqFactory.NewRequest("GET", u, nil)
 	if err != nil {
 		return nil, err
@@ -1,15 +1,19 @@
 package registry
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net/url"
 	"strconv"
+	"strings"
 
 	log "github.com/Sirupsen/logrus"
 	"github.com/docker/docker/registry/v2"
 	"github.com/docker/docker/utils"
+	"github.com/docker/libtrust"
 )
 
 func getV2Builder(e *Endpoint) *v2.URLBuilder {
@@ -56,17 +60,14 @@ func (r *Session) GetV2Authorization(ep *Endpoint, imageName string, readOnly bo
 	return NewRequestAuthorization(r.GetAuthConfig(true), ep, "repository", imageName, scopes), nil
 }
 
-//
-// 1) Check if TarSum of each layer exists /v2/
-//  1.a) if 200, continue
-//  1.b) if 300, then push the
-//  1.c) if anything else, err
-// 2) PUT the created/signed manifest
-//
-func (r *Session) GetV2ImageManifest(ep *Endpoint, imageName, tagName string, auth *RequestAuthorization) ([]byte, error) {
+// GetV2ImageManifest fetches the manifest for tagName, which may be either a
+// tag or a content digest.  The digest of the manifest is returned along with
+// its content.  When the manifest is fetched by digest, its content is
+// verified against the requested digest.
+func (r *Session) GetV2ImageManifest(ep *Endpoint, imageName, tagName string, auth *RequestAuthorization) ([]byte, string, error) {
 	routeURL, err := getV2Builder(ep).BuildManifestURL(imageName, tagName)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	method := "GET"
@@ -74,30 +75,53 @@ func (r *Session) GetV2ImageManifest(ep *Endpoint, imageName, tagName string, au
 
 	req, err := r.reqFactory.NewRequest(method, routeURL, nil)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	if err := auth.Authorize(req); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	res, _, err := r.doRequest(req)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 	defer res.Body.Close()
 	if res.StatusCode != 200 {
 		if res.StatusCode == 401 {
-			return nil, errLoginRequired
+			return nil, "", errLoginRequired
 		} else if res.StatusCode == 404 {
-			return nil, ErrDoesNotExist
+			return nil, "", ErrDoesNotExist
 		}
-		return nil, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to fetch for %s:%s", res.StatusCode, imageName, tagName), res)
+		return nil, "", utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to fetch for %s:%s", res.StatusCode, imageName, tagName), res)
 	}
 
 	buf, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("Error while reading the http response: %s", err)
+		return nil, "", fmt.Errorf("Error while reading the http response: %s", err)
+	}
+
+	digest, err := ManifestDigest(buf)
+	if err != nil {
+		return nil, "", err
+	}
+	if utils.DigestReference(tagName) && !strings.EqualFold(digest, tagName) {
+		return nil, "", fmt.Errorf("manifest verification failed for %s@%s: digest mismatch - got %s", imageName, tagName, digest)
+	}
+	return buf, digest, nil
+}
+
+// ManifestDigest returns the content digest of a signed manifest.  The digest
+// is computed over the manifest payload with its signatures removed, so that
+// re-signing a manifest does not change its digest.
+func ManifestDigest(manifestBytes []byte) (string, error) {
+	sig, err := libtrust.ParsePrettySignature(manifestBytes, "signatures")
+	if err != nil {
+		return "", fmt.Errorf("error parsing manifest: %s", err)
+	}
+	payload, err := sig.Payload()
+	if err != nil {
+		return "", fmt.Errorf("error retrieving manifest payload: %s", err)
 	}
-	return buf, nil
+	return utils.HashData(bytes.NewReader(payload))
 }
 
 // - Succeeded to head image blob (already exists)
@@ -169,7 +193,9 @@ func (r *Session) GetV2ImageBlob(ep *Endpoint, imageName, sumType, sum string, b
 	return err
 }
 
-func (r *Session) GetV2ImageBlobReader(ep *Endpoint, imageName, sumType, sum string, auth *RequestAuthorization) (io.ReadCloser, int64, error) {
+// GetV2ImageBlobReader returns the content of a blob starting at offset along
+// with the total size of the blob.
+func (r *Session) GetV2ImageBlobReader(ep *Endpoint, imageName, sumType, sum string, offset int64, auth *RequestAuthorization) (io.ReadCloser, int64, error) {
 	routeURL, err := getV2Builder(ep).BuildBlobURL(imageName, sumType+":"+sum)
 	if err != nil {
 		return nil, 0, err
@@ -184,11 +210,15 @@ func (r *Session) GetV2ImageBlobReader(ep *Endpoint, imageName, sumType, sum str
 	if err := auth.Authorize(req); err != nil {
 		return nil, 0, err
 	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
 	res, _, err := r.doRequest(req)
 	if err != nil {
 		return nil, 0, err
 	}
-	if res.StatusCode != 200 {
+	if res.StatusCode != 200 && res.StatusCode != 206 {
+		res.Body.Close()
 		if res.StatusCode == 401 {
 			return nil, 0, errLoginRequired
 		}
@@ -197,10 +227,22 @@ func (r *Session) GetV2ImageBlobReader(ep *Endpoint, imageName, sumType, sum str
 	lenStr := res.Header.Get("Content-Length")
 	l, err := strconv.ParseInt(lenStr, 10, 64)
 	if err != nil {
+		res.Body.Close()
 		return nil, 0, err
 	}
+	if offset == 0 {
+		return res.Body, l, nil
+	}
 
-	return res.Body, l, err
+	body, err := rangeBody(res, offset, sumType+":"+sum)
+	if err != nil {
+		return nil, 0, err
+	}
+	if res.StatusCode == 206 {
+		// The length of a partial response only covers the bytes from offset
+		l += offset
+	}
+	return body, l, nil
 }
 
 // Push the image to the server for storage.
@@ -342,3 +384,54 @@ func (r *Session) GetV2RemoteTags(ep *Endpoint, imageName string, auth *RequestA
 	}
 	return remote.tags, nil
 }
+
+type catalog struct {
+	Repositories []string `json:"repositories"`
+}
+
+// GetV2Catalog returns up to n names of the repositories of the registry,
+// starting after last, and whether there are more names after them.
+func (r *Session) GetV2Catalog(ep *Endpoint, n int, last string, auth *RequestAuthorization) ([]string, bool, error) {
+	values := url.Values{}
+	if n > 0 {
+		values.Set("n", strconv.Itoa(n))
+	}
+	if last != "" {
+		values.Set("last", last)
+	}
+	routeURL, err := getV2Builder(ep).BuildCatalogURL(values)
+	if err != nil {
+		return nil, false, err
+	}
+
+	method := "GET"
+	log.Debugf("[registry] Calling %q %s", method, routeURL)
+
+	req, err := r.reqFactory.NewRequest(method, routeURL, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if err := auth.Authorize(req); err != nil {
+		return nil, false, err
+	}
+	res, _, err := r.doRequest(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != 200 {
+		if res.StatusCode == 401 {
+			return nil, false, errLoginRequired
+		} else if res.StatusCode == 404 {
+			return nil, false, ErrDoesNotExist
+		}
+		return nil, false, utils.NewHTTPRequestError(fmt.Sprintf("Server error: %d trying to fetch the catalog", res.StatusCode), res)
+	}
+
+	var c catalog
+	if err := json.NewDecoder(res.Body).Decode(&c); err != nil {
+		return nil, false, fmt.Errorf("Error while decoding the http response: %s", err)
+	}
+	// The registry links to the next page when there is one
+	return c.Repositories, res.Header.Get("Link") != "", nil
+}
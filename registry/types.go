@@ -5,12 +5,15 @@ type SearchResult struct {
 	IsOfficial  bool   `json:"is_official"`
 	Name        string `json:"name"`
 	IsTrusted   bool   `json:"is_trusted"`
+	IsAutomated bool   `json:"is_automated"`
 	Description string `json:"description"`
 }
 
 type SearchResults struct {
 	Query      string         `json:"query"`
 	NumResults int            `json:"num_results"`
+	NumPages   int            `json:"num_pages,omitempty"`
+	Page       int            `json:"page,omitempty"`
 	Results    []SearchResult `json:"results"`
 }
 
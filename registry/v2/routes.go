@@ -6,6 +6,7 @@ import "github.com/gorilla/mux"
 // registered. These symbols can be used to look up a route based on the name.
 const (
 	RouteNameBase            = "base"
+	RouteNameCatalog         = "catalog"
 	RouteNameManifest        = "manifest"
 	RouteNameTags            = "tags"
 	RouteNameBlob            = "blob"
@@ -14,6 +15,7 @@ const (
 )
 
 var allEndpoints = []string{
+	RouteNameCatalog,
 	RouteNameManifest,
 	RouteNameTags,
 	RouteNameBlob,
@@ -33,11 +35,16 @@ func Router() *mux.Router {
 		Path("/v2/").
 		Name(RouteNameBase)
 
-	// GET      /v2/<name>/manifest/<tag>	Image Manifest	Fetch the image manifest identified by name and tag.
+	// GET	/v2/_catalog	Catalog	List the repositories of the registry, paginated with n and last.
+	router.
+		Path("/v2/_catalog").
+		Name(RouteNameCatalog)
+
+	// GET      /v2/<name>/manifest/<tag>	Image Manifest	Fetch the image manifest identified by name and tag or digest.
 	// PUT      /v2/<name>/manifest/<tag>	Image Manifest	Upload the image manifest identified by name and tag.
 	// DELETE   /v2/<name>/manifest/<tag>	Image Manifest	Delete the image identified by name and tag.
 	router.
-		Path("/v2/{name:" + RepositoryNameRegexp.String() + "}/manifests/{tag:" + TagNameRegexp.String() + "}").
+		Path("/v2/{name:" + RepositoryNameRegexp.String() + "}/manifests/{tag:" + TagNameRegexp.String() + "|" + DigestRegexp.String() + "}").
 		Name(RouteNameManifest)
 
 	// GET	/v2/<name>/tags/list	Tags	Fetch the tags under the repository identified by name.
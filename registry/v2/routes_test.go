@@ -59,6 +59,19 @@ func TestRouter(t *testing.T) {
 				"tag":  "tag",
 			},
 		},
+		{
+			RouteName:  RouteNameManifest,
+			RequestURI: "/v2/foo/bar/manifests/sha256:abcdef0123456789",
+			Vars: map[string]string{
+				"name": "foo/bar",
+				"tag":  "sha256:abcdef0123456789",
+			},
+		},
+		{
+			RouteName:  RouteNameCatalog,
+			RequestURI: "/v2/_catalog",
+			Vars:       map[string]string{},
+		},
 		{
 			RouteName:  RouteNameTags,
 			RequestURI: "/v2/foo/bar/tags/list",
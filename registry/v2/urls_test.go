@@ -27,6 +27,16 @@ func TestURLBuilder(t *testing.T) {
 				return urlBuilder.BuildBaseURL()
 			},
 		},
+		{
+			description:  "test catalog url",
+			expectedPath: "/v2/_catalog?last=foo%2Fbar&n=10",
+			build: func() (string, error) {
+				return urlBuilder.BuildCatalogURL(url.Values{
+					"n":    []string{"10"},
+					"last": []string{"foo/bar"},
+				})
+			},
+		},
 		{
 			description:  "test tags url",
 			expectedPath: "/v2/foo/bar/tags/list",
@@ -41,6 +51,13 @@ func TestURLBuilder(t *testing.T) {
 				return urlBuilder.BuildManifestURL("foo/bar", "tag")
 			},
 		},
+		{
+			description:  "test manifest url by digest",
+			expectedPath: "/v2/foo/bar/manifests/sha256:abcdef0123456789",
+			build: func() (string, error) {
+				return urlBuilder.BuildManifestURL("foo/bar", "sha256:abcdef0123456789")
+			},
+		},
 		{
 			description:  "build blob url",
 			expectedPath: "/v2/foo/bar/blobs/tarsum.v1+sha256:abcdef0123456789",
@@ -1,6 +1,8 @@
 package runconfig
 
 import (
+	"time"
+
 	"github.com/docker/docker/engine"
 	"github.com/docker/docker/nat"
 )
@@ -33,6 +35,20 @@ type Config struct {
 	NetworkDisabled bool
 	MacAddress      string
 	OnBuild         []string
+	Labels          map[string]string
+	StopSignal      string        // Signal to stop the container with, SIGTERM if empty
+	Healthcheck     *HealthConfig // How to check that the container is healthy
+}
+
+// HealthConfig holds the probe run by the daemon to check the health of a
+// container.
+type HealthConfig struct {
+	// Test is the probe to run: {} inherits the one of the image, {"NONE"}
+	// disables it, {"CMD", args...} execs the arguments directly and
+	// {"CMD-SHELL", command} runs the command with "/bin/sh -c".
+	Test     []string
+	Interval time.Duration // Time to wait between two probes
+	Timeout  time.Duration // Time after which a probe is considered hung
 }
 
 func ContainerConfigFromJob(job *engine.Job) *Config {
@@ -54,9 +70,12 @@ func ContainerConfigFromJob(job *engine.Job) *Config {
 		WorkingDir:      job.Getenv("WorkingDir"),
 		NetworkDisabled: job.GetenvBool("NetworkDisabled"),
 		MacAddress:      job.Getenv("MacAddress"),
+		StopSignal:      job.Getenv("StopSignal"),
 	}
 	job.GetenvJson("ExposedPorts", &config.ExposedPorts)
 	job.GetenvJson("Volumes", &config.Volumes)
+	job.GetenvJson("Labels", &config.Labels)
+	job.GetenvJson("Healthcheck", &config.Healthcheck)
 	if PortSpecs := job.GetenvList("PortSpecs"); PortSpecs != nil {
 		config.PortSpecs = PortSpecs
 	}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/docker/docker/nat"
 )
@@ -262,3 +263,37 @@ func TestMerge(t *testing.T) {
 	}
 
 }
+
+func TestMergeStopSignalAndHealthcheck(t *testing.T) {
+	configImage := &Config{
+		StopSignal: "SIGUSR1",
+		Healthcheck: &HealthConfig{
+			Test:     []string{"CMD", "true"},
+			Interval: 5 * time.Second,
+		},
+	}
+	configUser := &Config{}
+	if err := Merge(configUser, configImage); err != nil {
+		t.Fatal(err)
+	}
+	if configUser.StopSignal != "SIGUSR1" {
+		t.Fatalf("Expected the stop signal of the image, found %q", configUser.StopSignal)
+	}
+	if configUser.Healthcheck == nil || len(configUser.Healthcheck.Test) != 2 {
+		t.Fatalf("Expected the healthcheck of the image, found %v", configUser.Healthcheck)
+	}
+
+	configUser = &Config{
+		StopSignal:  "SIGINT",
+		Healthcheck: &HealthConfig{Test: []string{"NONE"}},
+	}
+	if err := Merge(configUser, configImage); err != nil {
+		t.Fatal(err)
+	}
+	if configUser.StopSignal != "SIGINT" {
+		t.Fatalf("Expected the stop signal of the user, found %q", configUser.StopSignal)
+	}
+	if configUser.Healthcheck.Test[0] != "NONE" {
+		t.Fatalf("Expected the healthcheck to be disabled, found %v", configUser.Healthcheck.Test)
+	}
+}
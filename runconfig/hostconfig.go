@@ -96,6 +96,9 @@ type DeviceMapping struct {
 type RestartPolicy struct {
 	Name              string
 	MaximumRetryCount int
+	// UnhealthyThreshold is the number of consecutive failed health probes
+	// after which an "on-unhealthy" container is killed and restarted
+	UnhealthyThreshold int
 }
 
 type HostConfig struct {
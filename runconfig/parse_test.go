@@ -57,3 +57,47 @@ func TestNetHostname(t *testing.T) {
 		t.Fatalf("Expected error ErrConflictNetworkHostname, got: %s", err)
 	}
 }
+
+func TestParseRestartPolicyOnUnhealthy(t *testing.T) {
+	p, err := parseRestartPolicy("on-unhealthy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Name != "on-unhealthy" || p.MaximumRetryCount != 0 || p.UnhealthyThreshold != 0 {
+		t.Fatalf("Unexpected restart policy: %+v", p)
+	}
+
+	p, err = parseRestartPolicy("on-unhealthy:5:2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.MaximumRetryCount != 5 || p.UnhealthyThreshold != 2 {
+		t.Fatalf("Unexpected restart policy: %+v", p)
+	}
+
+	for _, invalid := range []string{"on-unhealthy:5:0", "on-unhealthy:x", "on-unhealthy:1:2:3"} {
+		if _, err := parseRestartPolicy(invalid); err == nil {
+			t.Fatalf("Expected error parsing %q", invalid)
+		}
+	}
+}
+
+func TestParseLabels(t *testing.T) {
+	config, _, _, err := parseRun([]string{"-l", "com.example.team=web", "--label", "com.example.key", "--label=cost=a=b", "img", "cmd"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]string{"com.example.team": "web", "com.example.key": "", "cost": "a=b"}
+	if len(config.Labels) != len(expected) {
+		t.Fatalf("Expected labels %v, got %v", expected, config.Labels)
+	}
+	for k, v := range expected {
+		if config.Labels[k] != v {
+			t.Fatalf("Expected labels %v, got %v", expected, config.Labels)
+		}
+	}
+
+	if _, _, _, err := parseRun([]string{"--label", "=value", "img", "cmd"}); err == nil {
+		t.Fatal("Expected an error for a label without a key")
+	}
+}
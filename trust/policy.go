@@ -0,0 +1,69 @@
+package trust
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// Policy lists the keys allowed to sign the images of each repository
+// namespace.  A namespace is covered by the policy entry of its longest
+// prefix, so that "/myteam" also applies to "/myteam/app".  Images of a
+// covered namespace must be signed by one of the listed keys or by a key the
+// trust graph grants for the namespace; an empty list only accepts the keys
+// of the trust graph.
+//
+// The policy file is a JSON object mapping namespaces to key IDs:
+//
+//	{
+//		"/library": [],
+//		"/myteam": ["ABCD:EFGH:IJKL:MNOP:QRST:UVWX:YZ23:4567:ABCD:EFGH:IJKL:MNOP"]
+//	}
+type Policy map[string][]string
+
+// LoadPolicy reads the policy file at filename.
+func LoadPolicy(filename string) (Policy, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	var raw Policy
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return nil, fmt.Errorf("Invalid trust policy %s: %s", filename, err)
+	}
+	policy := make(Policy, len(raw))
+	for namespace, keys := range raw {
+		policy[normalizeNamespace(namespace)] = keys
+	}
+	return policy, nil
+}
+
+// Keys returns the keys listed for namespace and whether the namespace is
+// covered by the policy at all.
+func (p Policy) Keys(namespace string) ([]string, bool) {
+	for namespace = normalizeNamespace(namespace); ; namespace = path.Dir(namespace) {
+		if keys, exists := p[namespace]; exists {
+			return keys, true
+		}
+		if namespace == "/" {
+			return nil, false
+		}
+	}
+}
+
+// Allows returns true if the policy lists the key for namespace.
+func (p Policy) Allows(namespace, keyID string) bool {
+	keys, _ := p.Keys(namespace)
+	for _, k := range keys {
+		if k == keyID {
+			return true
+		}
+	}
+	return false
+}
+
+func normalizeNamespace(namespace string) string {
+	return path.Clean("/" + strings.TrimSpace(namespace))
+}
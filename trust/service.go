@@ -11,8 +11,9 @@ import (
 
 func (t *TrustStore) Install(eng *engine.Engine) error {
 	for name, handler := range map[string]engine.Handler{
-		"trust_key_check":   t.CmdCheckKey,
-		"trust_update_base": t.CmdUpdateBase,
+		"trust_key_check":    t.CmdCheckKey,
+		"trust_policy_check": t.CmdCheckPolicy,
+		"trust_update_base":  t.CmdUpdateBase,
 	} {
 		if err := eng.Register(name, handler); err != nil {
 			return fmt.Errorf("Could not register %q: %v", name, err)
@@ -45,6 +46,10 @@ func (t *TrustStore) CmdCheckKey(job *engine.Job) engine.Status {
 
 	t.RLock()
 	defer t.RUnlock()
+	if t.policy.Allows(namespace, pk.KeyID()) {
+		job.Stdout.Write([]byte("verified"))
+		return engine.StatusOK
+	}
 	if t.graph == nil {
 		job.Stdout.Write([]byte("no graph"))
 		return engine.StatusOK
@@ -67,6 +72,23 @@ func (t *TrustStore) CmdCheckKey(job *engine.Job) engine.Status {
 	return engine.StatusOK
 }
 
+// CmdCheckPolicy prints "enforced" if the trust policy requires the images of
+// the namespace to be signed by an allowed key, and "not enforced" otherwise.
+func (t *TrustStore) CmdCheckPolicy(job *engine.Job) engine.Status {
+	if n := len(job.Args); n != 1 {
+		return job.Errorf("Usage: %s NAMESPACE", job.Name)
+	}
+
+	t.RLock()
+	defer t.RUnlock()
+	if _, enforced := t.policy.Keys(job.Args[0]); enforced {
+		job.Stdout.Write([]byte("enforced"))
+	} else {
+		job.Stdout.Write([]byte("not enforced"))
+	}
+	return engine.StatusOK
+}
+
 func (t *TrustStore) CmdUpdateBase(job *engine.Job) engine.Status {
 	t.fetch()
 
@@ -26,6 +26,7 @@ type TrustStore struct {
 	autofetch     bool
 	httpClient    *http.Client
 	baseEndpoints map[string]*url.URL
+	policy        Policy
 
 	sync.RWMutex
 }
@@ -70,6 +71,13 @@ func NewTrustStore(path string) (*TrustStore, error) {
 	return t, nil
 }
 
+// SetPolicy sets the policy enforced on the signatures of images.
+func (t *TrustStore) SetPolicy(policy Policy) {
+	t.Lock()
+	defer t.Unlock()
+	t.policy = policy
+}
+
 func (t *TrustStore) reload() error {
 	t.Lock()
 	defer t.Unlock()
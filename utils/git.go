@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/docker/docker/pkg/symlink"
+	"github.com/docker/docker/pkg/urlutil"
+)
+
+// GitClone clones the git repository of remoteURL into a temporary directory,
+// and returns it along with the directory of the build context within it.
+// The caller removes the temporary directory.
+//
+// The URL may end with a fragment of the form "#ref:subdir", in which case
+// only the given branch, tag or commit is fetched, and the build context is
+// the given subdirectory of the repository. Both parts are optional, so
+// "repo.git#v1.0" and "repo.git#:docs" are valid too.
+func GitClone(remoteURL string) (root, contextDir string, err error) {
+	repo, ref, subdir := parseGitRemoteURL(remoteURL)
+	// A ref starting with a dash would be parsed as an option by git
+	if strings.HasPrefix(ref, "-") {
+		return "", "", fmt.Errorf("Invalid git ref %q: refs cannot start with a dash", ref)
+	}
+	if !urlutil.IsGitTransport(repo) && !strings.Contains(repo, "://") {
+		repo = "https://" + repo
+	}
+
+	if root, err = ioutil.TempDir("", "docker-build-git"); err != nil {
+		return "", "", err
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(root)
+		}
+	}()
+
+	if ref == "" {
+		if err := git(root, "clone", "--recursive", "--", repo, root); err != nil {
+			return "", "", err
+		}
+	} else if err := gitFetchRef(root, repo, ref); err != nil {
+		return "", "", err
+	}
+
+	contextDir = root
+	if subdir != "" {
+		if contextDir, err = symlink.FollowSymlinkInScope(filepath.Join(root, subdir), root); err != nil {
+			return "", "", fmt.Errorf("Error setting git context, %q not within git root: %s", subdir, err)
+		}
+		fi, err := os.Stat(contextDir)
+		if err != nil {
+			return "", "", fmt.Errorf("Error setting git context, %q does not exist in the repository", subdir)
+		}
+		if !fi.IsDir() {
+			return "", "", fmt.Errorf("Error setting git context, %q is not a directory", subdir)
+		}
+	}
+	return root, contextDir, nil
+}
+
+// parseGitRemoteURL splits a git remote URL into the URL of the repository,
+// and the ref and subdirectory of its "#ref:subdir" fragment.
+func parseGitRemoteURL(remoteURL string) (repo, ref, subdir string) {
+	parts := strings.SplitN(remoteURL, "#", 2)
+	repo = parts[0]
+	if len(parts) == 2 {
+		refAndDir := strings.SplitN(parts[1], ":", 2)
+		ref = refAndDir[0]
+		if len(refAndDir) == 2 {
+			subdir = refAndDir[1]
+		}
+	}
+	return repo, ref, subdir
+}
+
+// gitFetchRef checks out ref of the repository in dir. It fetches the ref
+// alone first, with no history, which servers may refuse for commits which
+// are not the head of a branch or a tag, and the whole repository otherwise.
+func gitFetchRef(dir, repo, ref string) error {
+	if err := git(dir, "init"); err != nil {
+		return err
+	}
+	if err := git(dir, "remote", "add", "--", "origin", repo); err != nil {
+		return err
+	}
+
+	if err := git(dir, "fetch", "--depth", "1", "--", "origin", ref); err == nil {
+		ref = "FETCH_HEAD"
+	} else if err := git(dir, "fetch", "--tags", "--", "origin"); err != nil {
+		return err
+	} else if err := git(dir, "rev-parse", "--verify", "origin/"+ref); err == nil {
+		ref = "origin/" + ref
+	}
+
+	if err := git(dir, "checkout", "-q", ref, "--"); err != nil {
+		return err
+	}
+	return git(dir, "submodule", "update", "--init", "--recursive")
+}
+
+// git runs a git command in dir.
+func git(dir string, args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("Error trying to use git: %s (%s)", err, output)
+	}
+	return nil
+}
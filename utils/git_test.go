@@ -0,0 +1,112 @@
+package utils
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseGitRemoteURL(t *testing.T) {
+	for remoteURL, expected := range map[string][3]string{
+		"git@github.com:docker/docker.git":                  {"git@github.com:docker/docker.git", "", ""},
+		"git@github.com:docker/docker.git#v1.5.0":           {"git@github.com:docker/docker.git", "v1.5.0", ""},
+		"https://github.com/docker/docker.git#master:docs":  {"https://github.com/docker/docker.git", "master", "docs"},
+		"https://github.com/docker/docker.git#:docs/source": {"https://github.com/docker/docker.git", "", "docs/source"},
+	} {
+		repo, ref, subdir := parseGitRemoteURL(remoteURL)
+		if repo != expected[0] || ref != expected[1] || subdir != expected[2] {
+			t.Fatalf("%s: expected %v, got [%s %s %s]", remoteURL, expected, repo, ref, subdir)
+		}
+	}
+}
+
+func TestGitClone(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	repoDir, err := ioutil.TempDir("", "docker-test-git-repo")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(repoDir)
+
+	gitCommit := func(file, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(repoDir, file)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(repoDir, file), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := git(repoDir, "add", file); err != nil {
+			t.Fatal(err)
+		}
+		if err := git(repoDir, "-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "-q", "-m", file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := git(repoDir, "init", "-q"); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit("Dockerfile", "FROM scratch")
+	if err := git(repoDir, "checkout", "-q", "-b", "test"); err != nil {
+		t.Fatal(err)
+	}
+	gitCommit("sub/Dockerfile", "FROM busybox")
+	if err := git(repoDir, "checkout", "-q", "-"); err != nil {
+		t.Fatal(err)
+	}
+
+	for fragment, expected := range map[string]string{
+		"":          "Dockerfile: FROM scratch",
+		"#test":     "Dockerfile: FROM scratch",
+		"#test:sub": "Dockerfile: FROM busybox",
+	} {
+		root, contextDir, err := GitClone("file://" + repoDir + fragment)
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := ioutil.ReadFile(filepath.Join(contextDir, "Dockerfile"))
+		os.RemoveAll(root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if "Dockerfile: "+string(content) != expected {
+			t.Fatalf("%q: expected %q, got %q", fragment, expected, content)
+		}
+	}
+
+	for _, fragment := range []string{"#test:missing", "#test:sub/Dockerfile", "#test:../..", "#nosuchref"} {
+		root, _, err := GitClone("file://" + repoDir + fragment)
+		if err == nil {
+			os.RemoveAll(root)
+			t.Fatalf("%q: expected an error", fragment)
+		}
+	}
+}
+
+func TestGitCloneOptionRef(t *testing.T) {
+	dir, err := ioutil.TempDir("", "docker-test-git-option")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	marker := filepath.Join(dir, "marker")
+	for _, fragment := range []string{"#--upload-pack=touch " + marker, "#-q:sub"} {
+		root, _, err := GitClone("file://" + dir + fragment)
+		if err == nil {
+			os.RemoveAll(root)
+			t.Fatalf("%q: expected an error", fragment)
+		}
+		if !strings.Contains(err.Error(), "cannot start with a dash") {
+			t.Fatalf("%q: expected the ref to be rejected, got %s", fragment, err)
+		}
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Fatal("Expected the ref not to be passed to git as an option")
+	}
+}
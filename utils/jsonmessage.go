@@ -76,16 +76,62 @@ func (p *JSONProgress) String() string {
 	return pbBox + numbersBox + timeLeftBox
 }
 
+// JSONAggregate is the progress of a whole image across its layers, as
+// reported by structured pull and push progress.
+type JSONAggregate struct {
+	Current  int64 `json:"current"`
+	Total    int64 `json:"total"`
+	Percent  int   `json:"percent"`
+	Layers   int   `json:"layers"`
+	Complete int   `json:"complete"`
+}
+
+// JSONSummary sums up a pull or push at the end of its structured progress.
+type JSONSummary struct {
+	BytesTransferred  int64   `json:"bytesTransferred"`
+	LayersTransferred int     `json:"layersTransferred"`
+	LayersReused      int     `json:"layersReused"`
+	Duration          float64 `json:"duration"`
+}
+
+// JSONBuildStep describes a step of a build once it is done, or failed with
+// Error, in the structured output of the build.
+type JSONBuildStep struct {
+	Step        int     `json:"step"`
+	Instruction string  `json:"instruction"`
+	Cached      bool    `json:"cached"`
+	ImageID     string  `json:"imageId,omitempty"`
+	ContainerID string  `json:"containerId,omitempty"`
+	Duration    float64 `json:"duration"`
+	Error       string  `json:"error,omitempty"`
+}
+
+// JSONBuildSummary sums up a build at the end of its structured output.
+// Failed builds have an Error and no ImageID, and count the steps which
+// were done before the failure.
+type JSONBuildSummary struct {
+	ImageID     string  `json:"imageId"`
+	Steps       int     `json:"steps"`
+	CachedSteps int     `json:"cachedSteps"`
+	Duration    float64 `json:"duration"`
+	Error       string  `json:"error,omitempty"`
+}
+
 type JSONMessage struct {
-	Stream          string        `json:"stream,omitempty"`
-	Status          string        `json:"status,omitempty"`
-	Progress        *JSONProgress `json:"progressDetail,omitempty"`
-	ProgressMessage string        `json:"progress,omitempty"` //deprecated
-	ID              string        `json:"id,omitempty"`
-	From            string        `json:"from,omitempty"`
-	Time            int64         `json:"time,omitempty"`
-	Error           *JSONError    `json:"errorDetail,omitempty"`
-	ErrorMessage    string        `json:"error,omitempty"` //deprecated
+	Stream          string            `json:"stream,omitempty"`
+	Status          string            `json:"status,omitempty"`
+	Progress        *JSONProgress     `json:"progressDetail,omitempty"`
+	ProgressMessage string            `json:"progress,omitempty"` //deprecated
+	ID              string            `json:"id,omitempty"`
+	From            string            `json:"from,omitempty"`
+	Time            int64             `json:"time,omitempty"`
+	Error           *JSONError        `json:"errorDetail,omitempty"`
+	ErrorMessage    string            `json:"error,omitempty"` //deprecated
+	Phase           string            `json:"phase,omitempty"`
+	Aggregate       *JSONAggregate    `json:"aggregate,omitempty"`
+	Summary         *JSONSummary      `json:"summary,omitempty"`
+	BuildStep       *JSONBuildStep    `json:"buildStep,omitempty"`
+	BuildSummary    *JSONBuildSummary `json:"buildSummary,omitempty"`
 }
 
 func (jm *JSONMessage) Display(out io.Writer, isTerminal bool) error {
@@ -170,3 +216,30 @@ func DisplayJSONMessagesStream(in io.Reader, out io.Writer, terminalFd uintptr,
 	}
 	return nil
 }
+
+// CopyJSONMessagesStream copies the JSON messages of a stream to out, one per
+// line, instead of displaying them.  It stops at the first error message and
+// returns it.
+func CopyJSONMessagesStream(in io.Reader, out io.Writer) error {
+	dec := json.NewDecoder(in)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return err
+		}
+		if _, err := fmt.Fprintf(out, "%s\n", raw); err != nil {
+			return err
+		}
+		var jm JSONMessage
+		if err := json.Unmarshal(raw, &jm); err != nil {
+			return err
+		}
+		if jm.Error != nil {
+			return jm.Error
+		}
+	}
+	return nil
+}
@@ -53,3 +53,12 @@ func ProgressReader(r io.ReadCloser, size int, output io.Writer, sf *StreamForma
 		newLine:  newline,
 	}
 }
+
+// ResumedProgressReader returns a progress reader for a download which was
+// resumed after its first start bytes.
+func ResumedProgressReader(r io.ReadCloser, size, start int, output io.Writer, sf *StreamFormatter, newline bool, ID, action string) *progressReader {
+	reader := ProgressReader(r, size, output, sf, newline, ID, action)
+	reader.progress.Current = start
+	reader.lastUpdate = start
+	return reader
+}
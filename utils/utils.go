@@ -253,6 +253,21 @@ func HashData(src io.Reader) (string, error) {
 	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
 }
 
+// DigestReference returns true if ref is a content digest rather than a tag.
+// Tags cannot contain a colon, so any reference containing one is a digest.
+func DigestReference(ref string) bool {
+	return strings.Contains(ref, ":")
+}
+
+// ImageReference joins a repository name with a tag or a digest using the
+// separator which matches the kind of reference.
+func ImageReference(repo, ref string) string {
+	if DigestReference(ref) {
+		return repo + "@" + ref
+	}
+	return repo + ":" + ref
+}
+
 type WriteFlusher struct {
 	sync.Mutex
 	w       io.Writer
@@ -473,7 +488,8 @@ func ValidateContextDirectory(srcPath string, excludes []string) error {
 		} else if skip, err := fileutils.Matches(relFilePath, excludes); err != nil {
 			return err
 		} else if skip {
-			if f.IsDir() {
+			// The files of the directory may be included again by an exception
+			if f.IsDir() && !fileutils.HasExceptions(excludes) {
 				return filepath.SkipDir
 			}
 			return nil
@@ -518,6 +534,8 @@ func StringsContainsNoCase(slice []string, s string) bool {
 // Reads a .dockerignore file and returns the list of file patterns
 // to ignore. Note this will trim whitespace from each line as well
 // as use GO's "clean" func to get the shortest/cleanest path for each.
+// Patterns are relative to the root of the context, so a leading "/" is
+// dropped, and the "!" of exceptions is kept in front of the cleaned path.
 func ReadDockerIgnore(path string) ([]string, error) {
 	// Note that a missing .dockerignore file isn't treated as an error
 	reader, err := os.Open(path)
@@ -537,8 +555,15 @@ func ReadDockerIgnore(path string) ([]string, error) {
 		if pattern == "" {
 			continue
 		}
-		pattern = filepath.Clean(pattern)
-		excludes = append(excludes, pattern)
+		exception := ""
+		if strings.HasPrefix(pattern, "!") {
+			exception, pattern = "!", pattern[1:]
+		}
+		pattern = strings.TrimPrefix(filepath.Clean("/"+pattern), "/")
+		if pattern == "" {
+			pattern = "."
+		}
+		excludes = append(excludes, exception+pattern)
 	}
 	if err = scanner.Err(); err != nil {
 		return nil, fmt.Errorf("Error reading '%s': %v", path, err)
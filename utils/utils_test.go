@@ -1,7 +1,10 @@
 package utils
 
 import (
+	"io/ioutil"
 	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 )
 
@@ -97,3 +100,39 @@ func TestReadSymlinkedDirectoryToFile(t *testing.T) {
 		t.Errorf("failed to remove symlink: %s", err)
 	}
 }
+
+func TestImageReference(t *testing.T) {
+	if ref := ImageReference("busybox", "latest"); ref != "busybox:latest" {
+		t.Fatalf("Expected busybox:latest, got %s", ref)
+	}
+	if ref := ImageReference("busybox", "sha256:abcdef"); ref != "busybox@sha256:abcdef" {
+		t.Fatalf("Expected busybox@sha256:abcdef, got %s", ref)
+	}
+}
+
+func TestReadDockerIgnore(t *testing.T) {
+	tmpDir, err := ioutil.TempDir("", "dockerignore-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	diName := filepath.Join(tmpDir, ".dockerignore")
+	content := "test1\n  /test2  \n\n./test3/../test4\n!test5/./keep\n!/test6\n"
+	if err := ioutil.WriteFile(diName, []byte(content), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	excludes, err := ReadDockerIgnore(diName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"test1", "test2", "test4", "!test5/keep", "!test6"}
+	if !reflect.DeepEqual(excludes, expected) {
+		t.Fatalf("Expected %v, got %v", expected, excludes)
+	}
+
+	if excludes, err := ReadDockerIgnore(filepath.Join(tmpDir, "missing")); err != nil || excludes != nil {
+		t.Fatalf("Expected a missing .dockerignore to be ignored, got %v, %v", excludes, err)
+	}
+}